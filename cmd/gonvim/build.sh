@@ -12,6 +12,8 @@ rm -f $DIR/deploy/darwin/gonvim.app/Info.plist
 rm -f $DIR/deploy/darwin/gonvim.app/gonvim.icns
 cp $DIR/darwin/Info.plist $DIR/deploy/darwin/gonvim.app/Contents/
 cp $DIR/darwin/gonvim.icns $DIR/deploy/darwin/gonvim.app/Contents/Resources/
+# KaTeX and Mermaid for the markdown preview
+cp -r $DIR/markdown $DIR/deploy/darwin/gonvim.app/Contents/Resources/
 cd $DIR/deploy/darwin/
 rm -f ~/Downloads/gonvim-macos.zip
 zip -r ~/Downloads/gonvim-macos.zip gonvim.app
@@ -25,6 +27,7 @@ rm -rf /tmp/gonvim
 cp -r ~/Downloads/nvim-win64 /tmp/gonvim
 rsync -r $DIR/deploy/windows/ /tmp/gonvim/bin/
 rm -f /tmp/gonvim/bin/nvim-qt.exe
+cp -r $DIR/markdown /tmp/gonvim/bin/
 cd /tmp
 rm -f ~/Downloads/gonvim-win64.zip
 zip -r ~/Downloads/gonvim-win64.zip gonvim
@@ -37,5 +40,6 @@ qtdeploy -docker build linux
 cd $DIR/deploy/
 rm -rf gonvim
 cp -r linux gonvim
+cp -r $DIR/markdown gonvim/
 rm -f ~/Downloads/gonvim-linux.zip
 zip -r ~/Downloads/gonvim-linux.zip gonvim
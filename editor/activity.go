@@ -11,10 +11,12 @@ import (
 
 // Activity is the Activity bar
 type Activity struct {
-	widget   *widgets.QWidget
-	layout   *widgets.QVBoxLayout
-	editItem *ActivityItem
-	deinItem *ActivityItem
+	widget       *widgets.QWidget
+	layout       *widgets.QVBoxLayout
+	editItem     *ActivityItem
+	deinItem     *ActivityItem
+	gitItem      *ActivityItem
+	problemsItem *ActivityItem
 	//sideArea *widgets.QScrollArea
 	sideArea *widgets.QStackedWidget
 }
@@ -38,49 +40,16 @@ func newActivity() *Activity {
 	activitySubLayout.SetContentsMargins(0, 0, 0, 0)
 	activitySubLayout.SetSpacing(15)
 
-	// fg := editor.fgcolor
-
-	editLayout := widgets.NewQVBoxLayout()
-	editLayout.SetContentsMargins(12, 5, 12, 5)
-	editLayout.SetSpacing(1)
-	editIcon := svg.NewQSvgWidget(nil)
-	editIcon.SetFixedWidth((editor.iconSize - 2) * 2)
-	editIcon.SetFixedHeight((editor.iconSize - 2) * 2)
-	svgContent := editor.getSvg("activityedit", newRGBA(255, 255, 255, 1))
-	editIcon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
-	editLayout.AddWidget(editIcon, 0, 0)
-	editWidget := widgets.NewQWidget(nil, 0)
-	editWidget.SetLayout(editLayout)
-	editItem := &ActivityItem{
-		widget: editWidget,
-		text:   "activityedit",
-		icon:   editIcon,
-		active: editor.config.SideBar.Visible,
-		id:     1,
-	}
-
-	// bg := editor.bgcolor
-
-	deinLayout := widgets.NewQVBoxLayout()
-	deinLayout.SetContentsMargins(12, 5, 12, 5)
-	deinLayout.SetSpacing(1)
-	deinIcon := svg.NewQSvgWidget(nil)
-	deinIcon.SetFixedWidth((editor.iconSize - 2) * 2)
-	deinIcon.SetFixedHeight((editor.iconSize - 2) * 2)
-	svgDeinContent := editor.getSvg("activitydein", newRGBA(255, 255, 255, 1))
-	deinIcon.Load2(core.NewQByteArray2(svgDeinContent, len(svgDeinContent)))
-	deinLayout.AddWidget(deinIcon, 0, 0)
-	deinWidget := widgets.NewQWidget(nil, 0)
-	deinWidget.SetLayout(deinLayout)
-	deinItem := &ActivityItem{
-		widget: deinWidget,
-		text:   "activitydein",
-		icon:   deinIcon,
-		id:     2,
-	}
+	editItem := newActivityItem("activityedit", 1)
+	editItem.active = editor.config.SideBar.Visible
+	deinItem := newActivityItem("activitydein", 2)
+	gitItem := newActivityItem("activitygit", 3)
+	problemsItem := newActivityItem("activityproblems", 4)
 
-	activitySubLayout.AddWidget(editWidget, 0, 0)
-	activitySubLayout.AddWidget(deinWidget, 0, 0)
+	activitySubLayout.AddWidget(editItem.widget, 0, 0)
+	activitySubLayout.AddWidget(deinItem.widget, 0, 0)
+	activitySubLayout.AddWidget(gitItem.widget, 0, 0)
+	activitySubLayout.AddWidget(problemsItem.widget, 0, 0)
 	activitySubWidget := widgets.NewQWidget(nil, 0)
 	activitySubWidget.SetLayout(activitySubLayout)
 
@@ -90,22 +59,49 @@ func newActivity() *Activity {
 	stackedWidget := widgets.NewQStackedWidget(nil)
 
 	activity := &Activity{
-		layout:   activityLayout,
-		editItem: editItem,
-		deinItem: deinItem,
-		sideArea: stackedWidget,
+		layout:       activityLayout,
+		editItem:     editItem,
+		deinItem:     deinItem,
+		gitItem:      gitItem,
+		problemsItem: problemsItem,
+		sideArea:     stackedWidget,
 	}
 
-	activity.editItem.widget.ConnectEnterEvent(editItem.enterEvent)
-	activity.editItem.widget.ConnectLeaveEvent(editItem.leaveEvent)
-	activity.editItem.widget.ConnectMousePressEvent(editItem.mouseEvent)
-	activity.deinItem.widget.ConnectEnterEvent(deinItem.enterEvent)
-	activity.deinItem.widget.ConnectLeaveEvent(deinItem.leaveEvent)
-	activity.deinItem.widget.ConnectMousePressEvent(deinItem.mouseEvent)
+	for _, item := range activity.items() {
+		item.widget.ConnectEnterEvent(item.enterEvent)
+		item.widget.ConnectLeaveEvent(item.leaveEvent)
+		item.widget.ConnectMousePressEvent(item.mouseEvent)
+	}
 
 	return activity
 }
 
+func newActivityItem(text string, id int) *ActivityItem {
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(12, 5, 12, 5)
+	layout.SetSpacing(1)
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedWidth((editor.iconSize - 2) * 2)
+	icon.SetFixedHeight((editor.iconSize - 2) * 2)
+	svgContent := editor.getSvg(text, newRGBA(255, 255, 255, 1))
+	icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	layout.AddWidget(icon, 0, 0)
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetLayout(layout)
+
+	return &ActivityItem{
+		widget: widget,
+		text:   text,
+		icon:   icon,
+		id:     id,
+	}
+}
+
+// items returns the items in the activity bar
+func (a *Activity) items() []*ActivityItem {
+	return []*ActivityItem{a.editItem, a.deinItem, a.gitItem, a.problemsItem}
+}
+
 func (n *ActivityItem) enterEvent(event *core.QEvent) {
 	cursor := gui.NewQCursor()
 	cursor.SetShape(core.Qt__PointingHandCursor)
@@ -137,10 +133,14 @@ func (n *ActivityItem) mouseEvent(event *gui.QMouseEvent) {
 		n.active = false
 		return
 	}
+	n.activate()
+}
+
+// activate shows the side bar of the item
+func (n *ActivityItem) activate() {
 	editor.activity.sideArea.Show()
 
-	items := []*ActivityItem{editor.activity.editItem, editor.activity.deinItem}
-	for _, item := range items {
+	for _, item := range editor.activity.items() {
 		item.active = false
 	}
 	n.active = true
@@ -175,6 +175,22 @@ func (n *ActivityItem) mouseEvent(event *gui.QMouseEvent) {
 		}
 		editor.activity.sideArea.SetCurrentWidget(editor.deinSide.scrollarea)
 
+	case "activitygit":
+		if editor.gitSide == nil {
+			editor.gitSide = newGitSide()
+			editor.activity.sideArea.AddWidget(editor.gitSide.scrollarea)
+		}
+		editor.activity.sideArea.SetCurrentWidget(editor.gitSide.scrollarea)
+		editor.gitSide.update()
+
+	case "activityproblems":
+		if editor.problemsSide == nil {
+			editor.problemsSide = newProblemsSide()
+			editor.activity.sideArea.AddWidget(editor.problemsSide.scrollarea)
+		}
+		editor.activity.sideArea.SetCurrentWidget(editor.problemsSide.scrollarea)
+		editor.problemsSide.update()
+
 	case "activityedit":
 		editor.activity.sideArea.SetCurrentWidget(editor.wsSide.scrollarea)
 		editor.workspaces[editor.active].nvim.Command(`call rpcnotify(0, "Gui", "gonvim_workspace_cwd", getcwd())`)
@@ -227,8 +243,7 @@ func setActivityItemColor() {
 	fg := editor.fgcolor
 	bg := editor.bgcolor
 	var svgContent string
-	items := []*ActivityItem{editor.activity.editItem, editor.activity.deinItem}
-	for _, item := range items {
+	for _, item := range editor.activity.items() {
 		if item.active == true {
 			item.widget.SetStyleSheet(fmt.Sprintf(" * { color: rgba(%d, %d, %d, 1); } ", warpColor(fg, 15).R, warpColor(fg, 15).G, warpColor(fg, 15).B))
 			svgContent = editor.getSvg(item.text, newRGBA(warpColor(fg, 15).R, warpColor(fg, 15).G, warpColor(fg, 15).B, 1))
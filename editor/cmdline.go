@@ -2,6 +2,7 @@ package editor
 
 import (
 	"fmt"
+	"html"
 	"strings"
 )
 
@@ -11,19 +12,30 @@ type CmdContent struct {
 	firstc  string
 	prompt  string
 	content string
+	chunks  []*CmdChunk
+}
+
+// CmdChunk is a highlighted chunk of the content of the cmdline
+type CmdChunk struct {
+	attrs map[string]interface{}
+	text  string
 }
 
 // Cmdline is the cmdline
 type Cmdline struct {
-	ws            *Workspace
-	pos           int
-	content       *CmdContent
-	preContent    *CmdContent
-	function      []*CmdContent
-	inFunction    bool
+	ws         *Workspace
+	pos        int
+	content    *CmdContent
+	preContent *CmdContent
+	function   []*CmdContent
+	inFunction bool
+	// block is whether the lines of the function are of cmdline_block_show
+	block         bool
 	rawItems      []interface{}
 	wildmenuShown bool
 	top           int
+	// secret is whether the content is masked, as in inputsecret()
+	secret bool
 }
 
 func initCmdline() *Cmdline {
@@ -33,31 +45,160 @@ func initCmdline() *Cmdline {
 	}
 }
 
-func (c *CmdContent) getText() string {
-	indentStr := ""
-	for i := 0; i < c.indent; i++ {
-		indentStr += " "
+// parseCmdChunks returns the text and the chunks of the [attrs, text] list
+// of the cmdline and the message events
+func parseCmdChunks(s *Screen, arg interface{}) (string, []*CmdChunk) {
+	items, _ := arg.([]interface{})
+	text := ""
+	chunks := []*CmdChunk{}
+	for _, item := range items {
+		chunk, ok := item.([]interface{})
+		if !ok || len(chunk) < 2 {
+			continue
+		}
+		attrs := s.chunkAttrs(chunk[0])
+		t, _ := chunk[1].(string)
+		text += t
+		chunks = append(chunks, &CmdChunk{attrs: attrs, text: t})
+	}
+	return text, chunks
+}
+
+// formatCmdChunks returns the chunks in html with their highlight attributes
+func formatCmdChunks(chunks []*CmdChunk) string {
+	formatted := ""
+	for _, chunk := range chunks {
+		style := cmdChunkStyle(chunk.attrs)
+		if style == "" {
+			formatted += html.EscapeString(chunk.text)
+			continue
+		}
+		formatted += fmt.Sprintf("<span style=\"%s\">%s</span>", style, html.EscapeString(chunk.text))
 	}
-	return fmt.Sprintf("%s%s", indentStr, c.content)
+	return formatted
 }
 
-func (c *Cmdline) getText(ch string) string {
-	indentStr := ""
-	for i := 0; i < c.content.indent; i++ {
-		indentStr += " "
+// cmdChunkStyle returns the css of the highlight attributes of a chunk
+func cmdChunkStyle(attrs map[string]interface{}) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	var fg, bg *RGBA
+	if v, ok := attrs["foreground"]; ok {
+		fg = calcColor(reflectToInt(v))
+	}
+	if v, ok := attrs["background"]; ok {
+		bg = calcColor(reflectToInt(v))
+	}
+	if isTrue(attrs["reverse"]) {
+		if fg == nil {
+			fg = editor.fgcolor
+		}
+		if bg == nil {
+			bg = editor.bgcolor
+		}
+		fg, bg = bg, fg
+	}
+	style := ""
+	if fg != nil {
+		style += fmt.Sprintf("color: %s; ", fg.Hex())
+	}
+	if bg != nil {
+		style += fmt.Sprintf("background-color: %s; ", bg.Hex())
+	}
+	if isTrue(attrs["bold"]) {
+		style += "font-weight: bold; "
 	}
-	if c.pos > len(c.content.content) {
-		c.pos = len(c.content.content) - 1
+	if isTrue(attrs["italic"]) {
+		style += "font-style: italic; "
+	}
+	if isTrue(attrs["underline"]) || isTrue(attrs["undercurl"]) {
+		style += "text-decoration: underline; "
+	}
+	return strings.TrimSpace(style)
+}
+
+func (c *CmdContent) getText() string {
+	return strings.Repeat(" ", c.indent) + c.content
+}
+
+// getFormattedText returns the content in html with the indent
+func (c *CmdContent) getFormattedText() string {
+	return preformatted(strings.Repeat(" ", c.indent) + formatCmdChunks(c.chunks))
+}
+
+// preformatted keeps the spaces of the html, which is also not mistaken for
+// plain text by QLabel
+func preformatted(formatted string) string {
+	return fmt.Sprintf("<span style=\"white-space: pre;\">%s</span>", formatted)
+}
+
+// promptLine returns the last line of the prompt, which is shown before the
+// content like Vim does
+func (c *CmdContent) promptLine() string {
+	lines := strings.Split(c.prompt, "\n")
+	return lines[len(lines)-1]
+}
+
+// getText returns the text of the cmdline with ch at the cursor, and the
+// same text in html with the highlight of the content
+func (c *Cmdline) getText(ch string) (string, string) {
+	content := c.content
+	if c.pos > len(content.content) {
+		c.pos = len(content.content)
 	}
-	if len(c.content.content) == 0 {
+	if c.pos < 0 {
 		c.pos = 0
 	}
-	return fmt.Sprintf("%s%s%s", c.content.firstc, indentStr, c.content.content[:c.pos]+ch+c.content.content[c.pos:])
+	if c.secret && ch != "" {
+		ch = "*"
+	}
+	prefix := content.firstc + content.promptLine() + strings.Repeat(" ", content.indent)
+	text := prefix + content.content[:c.pos] + ch + content.content[c.pos:]
+
+	formatted := ""
+	if ch == "" {
+		formatted = formatCmdChunks(content.chunks)
+	} else {
+		// The special character is shown at the cursor until the next
+		// cmdline_show, so the chunks are split at the cursor
+		before, after := splitCmdChunks(content.chunks, c.pos)
+		formatted = formatCmdChunks(before) + html.EscapeString(ch) + formatCmdChunks(after)
+	}
+	if prompt := content.promptLine(); prompt != "" {
+		style := ""
+		if editor.fgcolor != nil {
+			style = fmt.Sprintf("color: %s;", gradColor(editor.fgcolor).Hex())
+		}
+		formatted = fmt.Sprintf("<span style=\"%s\">%s</span>%s", style, html.EscapeString(prompt), formatted)
+	}
+	formatted = preformatted(html.EscapeString(content.firstc) + strings.Repeat(" ", content.indent) + formatted)
+	return text, formatted
+}
+
+// splitCmdChunks splits the chunks at the byte offset of the content
+func splitCmdChunks(chunks []*CmdChunk, pos int) ([]*CmdChunk, []*CmdChunk) {
+	before := []*CmdChunk{}
+	after := []*CmdChunk{}
+	n := 0
+	for _, chunk := range chunks {
+		switch {
+		case n+len(chunk.text) <= pos:
+			before = append(before, chunk)
+		case n >= pos:
+			after = append(after, chunk)
+		default:
+			before = append(before, &CmdChunk{attrs: chunk.attrs, text: chunk.text[:pos-n]})
+			after = append(after, &CmdChunk{attrs: chunk.attrs, text: chunk.text[pos-n:]})
+		}
+		n += len(chunk.text)
+	}
+	return before, after
 }
 
 func (c *Cmdline) show(args []interface{}) {
 	arg := args[0].([]interface{})
-	content := arg[0].([]interface{})[0].([]interface{})[1].(string)
+	content, chunks := parseCmdChunks(c.ws.screen, arg[0])
 	pos := reflectToInt(arg[1])
 	firstc := arg[2].(string)
 	prompt := arg[3].(string)
@@ -65,15 +206,20 @@ func (c *Cmdline) show(args []interface{}) {
 	// level := reflectToInt(arg[5])
 	// fmt.Println("cmdline show", content, pos, firstc, prompt, indent, level)
 
+	// Neovim masks the content of inputsecret() with "*", though the
+	// position is still the one in the secret
+	c.secret = firstc == "" && content != "" && strings.Trim(content, "*") == ""
+
 	c.pos = pos
 	c.content.firstc = firstc
-	isResize := c.content.content != content
+	isResize := c.content.content != content || c.content.prompt != prompt
 	c.content.content = content
+	c.content.chunks = chunks
 	c.content.indent = indent
 	c.content.prompt = prompt
-	text := c.getText("")
+	text, formatted := c.getText("")
 	palette := c.ws.palette
-	palette.setPattern(text)
+	palette.setFormattedPattern(text, formatted)
 	c.cursorMove()
 	if isResize {
 		palette.resize()
@@ -93,12 +239,13 @@ func (c *Cmdline) showAddition() {
 			resultItem.hide()
 			continue
 		}
-		resultItem.setItem(lines[i], "", []int{})
+		resultItem.setFormattedItem(lines[i])
 		resultItem.setSelected(false)
 		resultItem.show()
 	}
 }
 
+// getPromptLines returns the lines of the prompt but the last one in html
 func (c *Cmdline) getPromptLines() []string {
 	result := []string{}
 	if c.content.prompt == "" {
@@ -106,15 +253,16 @@ func (c *Cmdline) getPromptLines() []string {
 	}
 
 	lines := strings.Split(c.content.prompt, "\n")
-	for _, line := range lines {
+	for _, line := range lines[:len(lines)-1] {
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
-		result = append(result, line)
+		result = append(result, preformatted(html.EscapeString(line)))
 	}
 	return result
 }
 
+// getFunctionLines returns the lines of the block in html
 func (c *Cmdline) getFunctionLines() []string {
 	result := []string{}
 	if !c.inFunction {
@@ -122,23 +270,29 @@ func (c *Cmdline) getFunctionLines() []string {
 	}
 
 	for _, content := range c.function {
-		result = append(result, content.getText())
+		result = append(result, content.getFormattedText())
 	}
 	return result
 }
 
 func (c *Cmdline) cursorMove() {
-	c.ws.palette.cursorMove(c.pos + len(c.content.firstc) + c.content.indent)
+	pos := c.pos
+	if c.secret {
+		// The masked content has a "*" per character of the secret
+		pos = len(c.content.content)
+	}
+	c.ws.palette.cursorMove(pos + len(c.content.firstc) + len(c.content.promptLine()) + c.content.indent)
 }
 
 func (c *Cmdline) hide(args []interface{}) {
 	palette := c.ws.palette
 	palette.hide()
-	if c.inFunction {
+	if c.inFunction && !c.block {
 		c.function = append(c.function, c.content)
 	}
 	c.preContent = c.content
 	c.content = &CmdContent{}
+	c.secret = false
 }
 
 func (c *Cmdline) functionShow() {
@@ -150,6 +304,32 @@ func (c *Cmdline) functionHide() {
 	c.inFunction = false
 }
 
+// blockShow shows the lines of a block like :function or :lua << EOF
+func (c *Cmdline) blockShow(args []interface{}) {
+	arg := args[0].([]interface{})
+	lines, _ := arg[0].([]interface{})
+	c.inFunction = true
+	c.block = true
+	c.function = []*CmdContent{}
+	for _, line := range lines {
+		content, chunks := parseCmdChunks(c.ws.screen, line)
+		c.function = append(c.function, &CmdContent{content: content, chunks: chunks})
+	}
+}
+
+// blockAppend appends a line to the block
+func (c *Cmdline) blockAppend(args []interface{}) {
+	arg := args[0].([]interface{})
+	content, chunks := parseCmdChunks(c.ws.screen, arg[0])
+	c.function = append(c.function, &CmdContent{content: content, chunks: chunks})
+}
+
+func (c *Cmdline) blockHide() {
+	c.inFunction = false
+	c.block = false
+	c.function = nil
+}
+
 func (c *Cmdline) changePos(args []interface{}) {
 	args = args[0].([]interface{})
 	pos := reflectToInt(args[0])
@@ -165,9 +345,9 @@ func (c *Cmdline) putChar(args []interface{}) {
 	// shift := reflectToInt(args[1])
 	// level := reflectToInt(args[2])
 	// fmt.Println("putChar", ch, shift, level)
-	text := c.getText(ch)
+	text, formatted := c.getText(ch)
 	palette := c.ws.palette
-	palette.setPattern(text)
+	palette.setFormattedPattern(text, formatted)
 }
 
 func (c *Cmdline) wildmenuShow(args []interface{}) {
@@ -0,0 +1,127 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CompletionKind is a kind of completion items, one of CompletionItemKind of LSP
+type CompletionKind struct {
+	name    string
+	icon    string
+	hlGroup string
+}
+
+// completionKinds are the CompletionItemKind of LSP in the order of their values
+var completionKinds = []*CompletionKind{
+	{"Text", "kindtext", "String"},
+	{"Method", "kindmethod", "Function"},
+	{"Function", "kindfunction", "Function"},
+	{"Constructor", "kindconstructor", "Special"},
+	{"Field", "kindfield", "Identifier"},
+	{"Variable", "kindvariable", "Identifier"},
+	{"Class", "kindclass", "Type"},
+	{"Interface", "kindinterface", "Type"},
+	{"Module", "kindmodule", "Include"},
+	{"Property", "kindproperty", "Identifier"},
+	{"Unit", "kindunit", "Number"},
+	{"Value", "kindvalue", "Number"},
+	{"Enum", "kindenum", "Type"},
+	{"Keyword", "kindkeyword", "Keyword"},
+	{"Snippet", "kindsnippet", "Special"},
+	{"Color", "kindcolor", "Constant"},
+	{"File", "kindfile", "Directory"},
+	{"Reference", "kindreference", "Identifier"},
+	{"Folder", "kindfolder", "Directory"},
+	{"EnumMember", "kindenummember", "Constant"},
+	{"Constant", "kindconstant", "Constant"},
+	{"Struct", "kindstruct", "Structure"},
+	{"Event", "kindevent", "Special"},
+	{"Operator", "kindoperator", "Operator"},
+	{"TypeParameter", "kindtypeparameter", "Type"},
+}
+
+// completionKindAliases are the kinds of the items of Vim and the completion
+// plugins which are not the names of the LSP kinds
+var completionKindAliases = map[string]string{
+	"f":          "Function",
+	"func":       "Function",
+	"m":          "Field",
+	"member":     "Field",
+	"v":          "Variable",
+	"var":        "Variable",
+	"statement":  "Variable",
+	"instance":   "Variable",
+	"param":      "Variable",
+	"parameter":  "Variable",
+	"t":          "TypeParameter",
+	"type":       "TypeParameter",
+	"typedef":    "TypeParameter",
+	"d":          "Constant",
+	"define":     "Constant",
+	"macro":      "Constant",
+	"const":      "Constant",
+	"import":     "Module",
+	"package":    "Module",
+	"namespace":  "Module",
+	"k":          "Keyword",
+	"snip":       "Snippet",
+	"prop":       "Property",
+	"attribute":  "Property",
+	"enummember": "EnumMember",
+	"dir":        "Folder",
+	"directory":  "Folder",
+	"buffer":     "File",
+	"ref":        "Reference",
+	"op":         "Operator",
+}
+
+// lookupCompletionKind returns the LSP kind of the kind of a completion item,
+// which may be a name of the LSP kinds, an alias or a user kind in the config,
+// and the override of the kind in the config
+func lookupCompletionKind(kind string) (*CompletionKind, *popupMenuKindConfig) {
+	// Completion plugins may prefix the kinds with icons, like " Function"
+	fields := strings.Fields(kind)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	name := strings.ToLower(fields[len(fields)-1])
+	override := completionKindOverride(name)
+	if override != nil && override.Kind != "" {
+		name = strings.ToLower(override.Kind)
+	}
+	if alias, ok := completionKindAliases[name]; ok {
+		name = strings.ToLower(alias)
+	}
+	for _, k := range completionKinds {
+		if strings.ToLower(k.name) != name {
+			continue
+		}
+		// The override of the LSP kind applies to its aliases too
+		if override == nil {
+			override = completionKindOverride(name)
+		}
+		return k, override
+	}
+	return nil, override
+}
+
+// completionKindOverride returns the override of the kind in the config
+func completionKindOverride(name string) *popupMenuKindConfig {
+	for key, c := range editor.config.PopupMenu.Kinds {
+		if strings.EqualFold(key, name) {
+			return &c
+		}
+	}
+	return nil
+}
+
+// completionKindLetter returns the first letter of an unknown kind
+func completionKindLetter(kind string) string {
+	for _, r := range strings.TrimSpace(kind) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return string(unicode.ToLower(r))
+		}
+	}
+	return ""
+}
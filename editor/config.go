@@ -24,6 +24,9 @@ import (
 // cursorBlink = true
 // disableIMEinNormal = true
 // startFullScreen = true
+// # Leave vim.notify to the plugins like nvim-notify, except for the
+// # notifications with buttons
+// keepVimNotify = true
 // ginitvim = '''
 //   set guifont=FuraCode\ Nerd\ Font\ Mono:h14
 //   if g:gonvim_running == 1
@@ -41,6 +44,18 @@ import (
 // replaceModeColor = "#123456"
 // visualModeColor = "#123456"
 // termnalModeColor = "#123456"
+// # Segments from the left edge and to the right edge, in the display order.
+// # Built-in segments are mode, path, git, filetype, fileformat, encoding,
+// # position, lint and notification.
+// left = ["mode", "path"]
+// right = ["lint", "position", "encoding", "fileformat", "filetype", "git", "notification"]
+// # Segments which are not displayed
+// hide = ["encoding"]
+// # User segments show the value of the Vim expression, evaluated on the events.
+// # They are hidden unless they are also listed in left or right.
+// [statusLine.segments.branch]
+// expr = "FugitiveHead()"
+// events = ["BufEnter"]
 //
 // [tabline]
 // visible = true
@@ -58,6 +73,25 @@ import (
 // [miniMap]
 // visible = true
 //
+// [popupMenu]
+// # Override the icons and the colors of the completion item kinds, keyed by
+// # the LSP kinds or the kinds of the items. kind maps the kind of the items
+// # of a completion plugin to an LSP kind.
+// [popupMenu.kinds.function]
+// color = "#61afef"
+// [popupMenu.kinds.snip]
+// kind = "Snippet"
+// icon = "kindsnippet"
+//
+// [preview]
+// # Show the filetypes in the split of :GonvimMarkdown with the external
+// # converters, which read the buffer from stdin and write html, svg or text
+// # to stdout. rst, asciidoc and dot are converted with rst2html5, asciidoctor
+// # and dot unless they are overridden.
+// [preview.renderers.plantuml]
+// command = "plantuml -tsvg -pipe"
+// output = "svg"
+//
 // [sideBar]
 // visible = false
 // dropshadow = true
@@ -84,6 +118,8 @@ type gonvimConfig struct {
 	ScrollBar   scrollBarConfig
 	ActivityBar activityBarConfig
 	MiniMap     miniMapConfig
+	PopupMenu   popupMenuConfig
+	Preview     previewConfig
 	SideBar     sideBarConfig
 	Workspace   workspaceConfig
 	Dein        deinConfig
@@ -100,6 +136,7 @@ type editorConfig struct {
 	DisableImeInNormal bool
 	GinitVim           string
 	StartFullscreen    bool
+	KeepVimNotify      bool
 }
 
 type statusLineConfig struct {
@@ -111,6 +148,15 @@ type statusLineConfig struct {
 	ReplaceModeColor  string
 	VisualModeColor   string
 	TerminalModeColor string
+	Left              []string
+	Right             []string
+	Hide              []string
+	Segments          map[string]statusLineSegmentConfig
+}
+
+type statusLineSegmentConfig struct {
+	Expr   string
+	Events []string
 }
 
 type tabLineConfig struct {
@@ -125,6 +171,25 @@ type miniMapConfig struct {
 	Visible bool
 }
 
+type popupMenuConfig struct {
+	Kinds map[string]popupMenuKindConfig
+}
+
+type popupMenuKindConfig struct {
+	Kind  string
+	Icon  string
+	Color string
+}
+
+type previewConfig struct {
+	Renderers map[string]previewRendererConfig
+}
+
+type previewRendererConfig struct {
+	Command string
+	Output  string
+}
+
 type scrollBarConfig struct {
 	Visible bool
 }
@@ -217,6 +282,13 @@ func newGonvimConfig(home string) gonvimConfig {
 		config.Statusline.TerminalModeColor = newRGBA(119, 136, 153, 1).Hex()
 	}
 
+	if config.Statusline.Left == nil {
+		config.Statusline.Left = []string{"mode", "path"}
+	}
+	if config.Statusline.Right == nil {
+		config.Statusline.Right = []string{"lint", "position", "encoding", "fileformat", "filetype", "git", "notification"}
+	}
+
 	if config.SideBar.Width == 0 {
 		config.SideBar.Width = 300
 	}
@@ -54,7 +54,7 @@ func (c *Cursor) blink() {
 
 func (c *Cursor) move() {
 	c.widget.Move2(c.x, c.y+int(float64(c.ws.font.lineSpace)/2))
-	c.ws.loc.widget.Move2(c.x, c.y+c.ws.font.lineHeight)
+	c.ws.loc.setPosition(c.x, c.y)
 }
 
 func (c *Cursor) updateShape() {
@@ -144,6 +144,32 @@ func readDeinCache() (map[interface{}]interface{}, error) {
 	return m, nil
 }
 
+// deinPluginPath returns the install path of the plugin of the repo, which
+// is empty unless it is installed
+func deinPluginPath(repo string) string {
+	m, err := readDeinCache()
+	if err != nil {
+		return ""
+	}
+	for _, item := range m {
+		s, _ := item.(map[interface{}]interface{})
+		r, _ := s["repo"].(string)
+		path, _ := s["path"].(string)
+		if path != "" && normalizeRepo(r) == normalizeRepo(repo) {
+			return path
+		}
+	}
+	return ""
+}
+
+// normalizeRepo returns the owner/name of the repo, which may be a url
+func normalizeRepo(repo string) string {
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	repo = strings.TrimPrefix(repo, "https://github.com/")
+	repo = strings.TrimPrefix(repo, "git@github.com:")
+	return strings.ToLower(repo)
+}
+
 func detectTomlFile(s string) {
 	filesString := strings.Replace(s, `['`, "", 1)
 	filesString = strings.Replace(filesString, `']`, "", 1)
@@ -468,6 +494,7 @@ func loadDeinCashe() []*DeinPluginItem {
 
 		i.widget.ConnectEnterEvent(i.enterWidget)
 		i.widget.ConnectLeaveEvent(i.leaveWidget)
+		i.widget.ConnectMousePressEvent(i.pressWidget)
 
 		installedPlugins = append(installedPlugins, i)
 	}
@@ -1213,6 +1240,10 @@ func (d *DeinPluginItem) leaveWidget(event *core.QEvent) {
 	gui.QGuiApplication_RestoreOverrideCursor()
 }
 
+func (d *DeinPluginItem) pressWidget(event *gui.QMouseEvent) {
+	go editor.workspaces[editor.active].markdown.openReadme(d.repo, "")
+}
+
 func (p *Plugin) enterWidget(event *core.QEvent) {
 	cursor := gui.NewQCursor()
 	cursor.SetShape(core.Qt__PointingHandCursor)
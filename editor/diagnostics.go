@@ -0,0 +1,214 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severities of diagnostics, the same values as vim.diagnostic.severity
+const (
+	SeverityError = 1
+	SeverityWarn  = 2
+	SeverityInfo  = 3
+	SeverityHint  = 4
+)
+
+// Diagnostic is a diagnostic message at a position of a buffer
+type Diagnostic struct {
+	bufnr    int
+	file     string
+	lnum     int // 1-based
+	col      int // 1-based
+	endLnum  int
+	endCol   int // inclusive
+	severity int
+	message  string
+	source   string
+	code     string
+}
+
+// diagnosticsLua returns the diagnostics of vim.diagnostic.get() with
+// 1-based positions, or nil when vim.diagnostic is not available
+const diagnosticsLua = `(function()
+  if vim.diagnostic == nil then
+    return nil
+  end
+  local items = {}
+  for _, d in ipairs(vim.diagnostic.get()) do
+    table.insert(items, {
+      bufnr = d.bufnr,
+      file = vim.api.nvim_buf_get_name(d.bufnr),
+      lnum = d.lnum + 1,
+      col = d.col + 1,
+      end_lnum = (d.end_lnum or d.lnum) + 1,
+      end_col = d.end_col or d.col,
+      severity = d.severity,
+      text = d.message,
+      source = d.source or '',
+      code = d.code and tostring(d.code) or '',
+    })
+  end
+  return items
+end)()`
+
+// diagnosticsVim returns the items of the lists, with the full path of the
+// buffers
+const diagnosticsVim = `map(%s, {_, v -> extend(v, {'file': fnamemodify(bufname(v.bufnr), ':p')})})`
+
+// getDiagnostics returns the diagnostics from vim.diagnostic, or from the
+// location lists of all windows and the quickfix list when vim.diagnostic is
+// not available or has no diagnostics
+func (w *Workspace) getDiagnostics() ([]*Diagnostic, error) {
+	var items []map[string]interface{}
+	err := w.nvim.Call("luaeval", &items, diagnosticsLua)
+	if err == nil && len(items) > 0 {
+		diagnostics := []*Diagnostic{}
+		for _, item := range items {
+			diagnostics = append(diagnostics, newDiagnostic(item, reflectToInt(item["severity"])))
+		}
+		sortDiagnostics(diagnostics)
+		return diagnostics, nil
+	}
+
+	var winids []int
+	err = w.nvim.Eval(`map(getwininfo(), 'v:val.winid')`, &winids)
+	if err != nil {
+		return nil, err
+	}
+	lists := []string{"getqflist()"}
+	for _, winid := range winids {
+		lists = append(lists, fmt.Sprintf("getloclist(%d)", winid))
+	}
+	items = nil
+	err = w.nvim.Eval(fmt.Sprintf(diagnosticsVim, strings.Join(lists, " + ")), &items)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := []*Diagnostic{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		// Items without a type are not diagnostics, e.g. the results of :grep
+		typ, _ := item["type"].(string)
+		severity := locTypeSeverity(typ)
+		if severity == 0 || !isTrue(item["valid"]) || reflectToInt(item["bufnr"]) == 0 {
+			continue
+		}
+		d := newDiagnostic(item, severity)
+		key := fmt.Sprintf("%d:%d:%d:%s", d.bufnr, d.lnum, d.col, d.message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		diagnostics = append(diagnostics, d)
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics, nil
+}
+
+func newDiagnostic(item map[string]interface{}, severity int) *Diagnostic {
+	d := &Diagnostic{
+		bufnr:    reflectToInt(item["bufnr"]),
+		lnum:     reflectToInt(item["lnum"]),
+		col:      reflectToInt(item["col"]),
+		endLnum:  reflectToInt(item["end_lnum"]),
+		endCol:   reflectToInt(item["end_col"]),
+		severity: severity,
+	}
+	d.file, _ = item["file"].(string)
+	d.message, _ = item["text"].(string)
+	d.source, _ = item["source"].(string)
+	d.code, _ = item["code"].(string)
+	if d.col < 1 {
+		d.col = 1
+	}
+	if d.endLnum < d.lnum {
+		d.endLnum = d.lnum
+	}
+	if d.endLnum == d.lnum && d.endCol < d.col {
+		d.endCol = d.col
+	}
+	return d
+}
+
+// locTypeSeverity returns the severity of the type of a location list item
+func locTypeSeverity(typ string) int {
+	switch typ {
+	case "E", "e":
+		return SeverityError
+	case "W", "w":
+		return SeverityWarn
+	case "I", "i":
+		return SeverityInfo
+	case "N", "n", "H", "h":
+		return SeverityHint
+	}
+	return 0
+}
+
+// sortDiagnostics sorts the diagnostics by buffer, position and severity
+func sortDiagnostics(diagnostics []*Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.bufnr != b.bufnr {
+			return a.bufnr < b.bufnr
+		}
+		if a.lnum != b.lnum {
+			return a.lnum < b.lnum
+		}
+		if a.col != b.col {
+			return a.col < b.col
+		}
+		return a.severity < b.severity
+	})
+}
+
+// countDiagnostics returns the number of the diagnostics of the buffer per
+// severity, indexed by severity-1
+func countDiagnostics(diagnostics []*Diagnostic, bufnr int) [4]int {
+	counts := [4]int{}
+	for _, d := range diagnostics {
+		if d.bufnr != bufnr || d.severity < SeverityError || d.severity > SeverityHint {
+			continue
+		}
+		counts[d.severity-1]++
+	}
+	return counts
+}
+
+// severityName returns the name of the severity
+func severityName(severity int) string {
+	switch severity {
+	case SeverityError:
+		return "Error"
+	case SeverityWarn:
+		return "Warning"
+	case SeverityInfo:
+		return "Info"
+	default:
+		return "Hint"
+	}
+}
+
+// diagnosticDetail returns the source and the code of the diagnostic, like "gopls [unusedvar]"
+func diagnosticDetail(d *Diagnostic) string {
+	detail := d.source
+	if d.code != "" {
+		detail = strings.TrimSpace(fmt.Sprintf("%s [%s]", detail, d.code))
+	}
+	return detail
+}
+
+// diagnosticIcon returns the svg name and the color of the severity
+func diagnosticIcon(severity int) (string, *RGBA) {
+	switch severity {
+	case SeverityError:
+		return "linterr", newRGBA(204, 62, 68, 1)
+	case SeverityWarn:
+		return "lintwrn", newRGBA(204, 203, 65, 1)
+	case SeverityInfo:
+		return "lintinf", newRGBA(27, 161, 226, 1)
+	default:
+		return "linthint", newRGBA(141, 193, 73, 1)
+	}
+}
@@ -70,6 +70,9 @@ type Editor struct {
 	wsWidget   *widgets.QWidget
 	wsSide     *WorkspaceSide
 	deinSide   *DeinSide
+	gitSide    *GitSide
+
+	problemsSide *ProblemsSide
 
 	statuslineHeight int
 	width            int
@@ -96,12 +99,14 @@ type Editor struct {
 	keyAlt          core.Qt__Key
 	keyShift        core.Qt__Key
 
-	config               gonvimConfig
-	notifications        []*Notification
-	displayNotifications bool
+	config             gonvimConfig
+	notifications      []*Notification
+	notificationCenter *NotificationCenter
 
 	svgs     map[string]*SvgXML
 	svgsOnce sync.Once
+
+	gitStatuses *GitStatusCache
 }
 
 type editorSignal struct {
@@ -137,6 +142,8 @@ func InitEditor() {
 		fgcolor: nil,
 		stop:    make(chan struct{}),
 		guiInit: make(chan bool, 1),
+
+		gitStatuses: newGitStatusCache(),
 	}
 	e := editor
 	e.config = newGonvimConfig(home)
@@ -148,11 +155,22 @@ func InitEditor() {
 		if notify.message == "" {
 			return
 		}
-		if notify.buttons == nil {
-			e.popupNotification(notify.level, notify.period, notify.message)
-		} else {
+		notify.message = collapseRepeatedLines(notify.message)
+		e.notificationCenter.add(notify)
+		if e.notificationCenter.doNotDisturb {
+			return
+		}
+		// The notifications with buttons ask each action, so they are never
+		// collapsed into the same one
+		if notify.buttons != nil {
 			e.popupNotification(notify.level, notify.period, notify.message, notifyOptionArg(notify.buttons))
+			return
 		}
+		if n := e.findNotification(notify.level, notify.message); n != nil {
+			n.repeat()
+			return
+		}
+		e.popupNotification(notify.level, notify.period, notify.message)
 	})
 	e.app = widgets.NewQApplication(0, nil)
 	e.app.ConnectAboutToQuit(func() {
@@ -174,6 +192,9 @@ func InitEditor() {
 	e.window.SetStyleSheet(" * {background-color: rgba(0, 0, 0, 0);}")
 	e.window.SetWindowOpacity(0.0)
 
+	e.notificationCenter = newNotificationCenter()
+	e.notificationCenter.widget.SetParent(e.window)
+
 	e.initSpecialKeys()
 	e.window.ConnectKeyPressEvent(e.keyPress)
 	e.window.SetAcceptDrops(true)
@@ -461,6 +482,12 @@ func (e *Editor) workspaceUpdate() {
 	for i := len(e.workspaces); i < len(e.wsSide.items); i++ {
 		e.wsSide.items[i].hide()
 	}
+	if e.gitSide != nil {
+		e.gitSide.update()
+	}
+	if e.problemsSide != nil {
+		e.problemsSide.update()
+	}
 }
 
 func (e *Editor) keyPress(event *gui.QKeyEvent) {
@@ -471,6 +498,10 @@ func (e *Editor) keyPress(event *gui.QKeyEvent) {
 	if input != "" {
 		if input == "<Esc>" {
 			e.unfocusGonvimUI()
+			e.workspaces[e.active].loc.dismiss()
+			e.workspaces[e.active].hover.hide()
+			e.workspaces[e.active].message.history.hide()
+			e.notificationCenter.hide()
 		}
 		e.workspaces[e.active].nvim.Input(input)
 		e.workspaces[e.active].detectTerminalMode()
@@ -483,6 +514,16 @@ func (e *Editor) unfocusGonvimUI() {
 		e.deinSide.widget.ClearFocus()
 		e.deinSide.scrollarea.ClearFocus()
 	}
+	if e.activity.gitItem.active {
+		e.gitSide.message.ClearFocus()
+		e.gitSide.widget.ClearFocus()
+		e.gitSide.scrollarea.ClearFocus()
+	}
+	if e.activity.problemsItem.active {
+		e.problemsSide.filter.ClearFocus()
+		e.problemsSide.widget.ClearFocus()
+		e.problemsSide.scrollarea.ClearFocus()
+	}
 	if e.activity.editItem.active {
 		e.wsSide.widget.ClearFocus()
 		e.wsSide.widget.ClearFocus()
@@ -34,6 +34,7 @@ type Fileitem struct {
 	fileModified   *svg.QSvgWidget
 	isOpened       bool
 	isModified     bool
+	gitStatus      string
 }
 
 func newFilelistwidget(path string) *Filelist {
@@ -155,6 +156,12 @@ func newFilelistwidget(path string) *Filelist {
 	filelist.widget = filelistwidget
 	filelist.Fileitems = fileitems
 
+	if status := editor.gitStatuses.cached(path); status != nil {
+		filelist.setGitStatus(status)
+	} else if root, _ := findGitRoot(path); root != "" {
+		go editor.gitStatuses.get(root)
+	}
+
 	editor.wsSide.scrollarea.ConnectResizeEvent(func(*gui.QResizeEvent) {
 		if editor.activity.editItem.active == false {
 			return
@@ -185,6 +192,28 @@ func newFilelistwidget(path string) *Filelist {
 	return filelist
 }
 
+func (f *Filelist) setGitStatus(status *GitStatus) {
+	if root, _ := findGitRoot(f.cwdpath); root != status.root {
+		return
+	}
+	for _, fileitem := range f.Fileitems {
+		fileitem.updateGitStatus(status.fileStatus(fileitem.path))
+	}
+}
+
+func (f *Fileitem) updateGitStatus(fileStatus string) {
+	if f.gitStatus == fileStatus {
+		return
+	}
+	f.gitStatus = fileStatus
+	color := gitFileColor(fileStatus)
+	if color == nil {
+		f.file.SetStyleSheet("")
+		return
+	}
+	f.file.SetStyleSheet(fmt.Sprintf("color: %s;", color.print()))
+}
+
 func (f *Fileitem) setFilename(length float64) {
 	metrics := gui.NewQFontMetricsF(gui.NewQFont())
 	elidedfilename := metrics.ElidedText(f.fileText, core.Qt__ElideRight, length, 0)
@@ -2,6 +2,7 @@ package editor
 
 import (
 	"fmt"
+	"html"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -17,6 +18,7 @@ func initFinder() *Finder {
 }
 
 func (f *Finder) hide() {
+	f.ws.palette.setFooter("")
 	f.ws.palette.hide()
 }
 
@@ -30,6 +32,27 @@ func (f *Finder) selectResult(args []interface{}) {
 	f.ws.palette.showSelected(selected)
 }
 
+func (f *Finder) showStatus(args []interface{}) {
+	if len(args) < 3 {
+		return
+	}
+	palette := f.ws.palette
+	loading, _ := args[0].(bool)
+	loaded := reflectToInt(args[1])
+	errText, _ := args[2].(string)
+
+	text := ""
+	if loading {
+		text = fmt.Sprintf("loading… %d items", loaded)
+	}
+	if errText != "" {
+		errText = strings.Replace(html.EscapeString(errText), "\n", "<br>", -1)
+		text = fmt.Sprintf("<font color='%s'>%s</font>", newRGBA(204, 62, 68, 1).Hex(), errText)
+		palette.show()
+	}
+	palette.setFooter(text)
+}
+
 func (f *Finder) showPattern(args []interface{}) {
 	palette := f.ws.palette
 	p := args[0].(string)
@@ -62,6 +85,7 @@ func (f *Finder) showResult(args []interface{}) {
 	lastFile := ""
 	itemTypes := []string{}
 	itemMatches := [][]int{}
+	prefixes := []int{}
 	for i, item := range rawItems {
 		text := item.(string)
 		if resultType == "file_line" {
@@ -101,6 +125,7 @@ func (f *Finder) showResult(args []interface{}) {
 				text = text[n+1:]
 			}
 			results = append(results, text)
+			prefixes = append(prefixes, n+1)
 		} else {
 			results = append(results, text)
 		}
@@ -120,11 +145,13 @@ func (f *Finder) showResult(args []interface{}) {
 			// because deleting buffer number prefix in "[n] bufname" format
 			bufmatch := []int{}
 			for _, matchIdx := range match[i] {
-				bufmatch = append(bufmatch, matchIdx-3)
+				if matchIdx >= prefixes[i] {
+					bufmatch = append(bufmatch, matchIdx-prefixes[i])
+				}
 			}
 			resultItem.setItem(text, "file", bufmatch)
-		} else if resultType == "dir" {
-			resultItem.setItem(text, "dir", match[i])
+		} else if resultType == "dir" || resultType == "tag" || resultType == "help" || resultType == "commit" || resultType == "mark" {
+			resultItem.setItem(text, resultType, match[i])
 		} else if resultType == "file_line" {
 			resultItem.setItem(text, itemTypes[i], itemMatches[i])
 		} else {
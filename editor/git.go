@@ -0,0 +1,525 @@
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akiyosi/gonvim/osdepend"
+	"github.com/fsnotify/fsnotify"
+)
+
+// GitStatus is the status of a git repository
+type GitStatus struct {
+	root       string
+	gitDir     string
+	branch     string
+	oid        string
+	detached   bool
+	upstream   string
+	ahead      int
+	behind     int
+	staged     int
+	unstaged   int
+	untracked  int
+	conflicted int
+	state      string
+	// files is the status of the changed files keyed by the slash separated
+	// path relative to root, and dirs is the one of the directories containing them
+	files map[string]string
+	dirs  map[string]string
+	// changes is the XY status code of the changed files, "??" for untracked ones
+	changes map[string]string
+}
+
+// GitStatusCache caches the git status per repository root, and keeps it
+// up to date by watching the git directory
+type GitStatusCache struct {
+	mu       sync.Mutex
+	statuses map[string]*GitStatus
+	watchers map[string]*fsnotify.Watcher
+	index    map[string]*gitIndexFile
+	// subscribers are the workspaces receiving the updated statuses, which
+	// are published from the goroutines running git
+	subscribers []*Workspace
+}
+
+const gitWatchDebounce = 300 * time.Millisecond
+
+func newGitStatusCache() *GitStatusCache {
+	return &GitStatusCache{
+		statuses: make(map[string]*GitStatus),
+		watchers: make(map[string]*fsnotify.Watcher),
+		index:    make(map[string]*gitIndexFile),
+	}
+}
+
+// get returns the cached status of the repository, running git only when
+// the repository is not cached yet
+func (c *GitStatusCache) get(root string) (*GitStatus, error) {
+	c.mu.Lock()
+	status, ok := c.statuses[root]
+	c.mu.Unlock()
+	if ok {
+		return status, nil
+	}
+	status, err := c.refresh(root)
+	if err != nil {
+		return nil, err
+	}
+	c.watch(root, status.gitDir)
+	return status, nil
+}
+
+// cached returns the cached status of the repository containing path
+// without running git
+func (c *GitStatusCache) cached(path string) *GitStatus {
+	root, _ := findGitRoot(path)
+	if root == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statuses[root]
+}
+
+// refresh runs git, updates the cached status of the repository and
+// publishes it to the workspaces
+func (c *GitStatusCache) refresh(root string) (*GitStatus, error) {
+	status, err := getGitStatus(root)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.statuses, root)
+		watcher, ok := c.watchers[root]
+		delete(c.watchers, root)
+		c.mu.Unlock()
+		if ok {
+			watcher.Close()
+		}
+		return nil, err
+	}
+	c.mu.Lock()
+	c.statuses[root] = status
+	c.mu.Unlock()
+	c.clearIndexLines(root)
+	c.publish(status)
+	return status, nil
+}
+
+// watch starts watching HEAD, the index and the refs of the repository
+func (c *GitStatusCache) watch(root, gitDir string) {
+	if gitDir == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.watchers[root]; ok {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+	err = watcher.Add(gitDir)
+	if err != nil {
+		watcher.Close()
+		return
+	}
+	refsDir := gitDir
+	// The refs of linked worktrees are in the common directory of the main
+	// repository, which the commondir file points
+	commonDir := gitCommonDir(gitDir)
+	if commonDir != gitDir && watcher.Add(commonDir) == nil {
+		refsDir = commonDir
+	}
+	filepath.Walk(filepath.Join(refsDir, "refs"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			watcher.Add(path)
+		}
+		return nil
+	})
+	c.watchers[root] = watcher
+	go c.watchLoop(root, watcher)
+}
+
+func (c *GitStatusCache) watchLoop(root string, watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	defer func() {
+		// The repository is not refreshed after the watcher is closed
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !isGitStateFile(event.Name) {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				// Branches containing slashes create directories under refs
+				info, err := os.Stat(event.Name)
+				if err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+				}
+			}
+			if timer == nil {
+				timer = time.AfterFunc(gitWatchDebounce, func() {
+					c.refresh(root)
+				})
+			} else {
+				timer.Reset(gitWatchDebounce)
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// release closes the watchers of the repositories which none of the
+// workspaces is in, called in the GUI thread when a workspace stops
+func (c *GitStatusCache) release(workspaces []*Workspace) {
+	used := make(map[string]bool)
+	for _, ws := range workspaces {
+		for _, path := range []string{ws.cwd, ws.filepath} {
+			if path == "" {
+				continue
+			}
+			root, _ := findGitRoot(path)
+			if root != "" {
+				used[root] = true
+			}
+		}
+	}
+	c.mu.Lock()
+	released := make(map[string]*fsnotify.Watcher)
+	for root, watcher := range c.watchers {
+		if used[root] {
+			continue
+		}
+		released[root] = watcher
+		delete(c.watchers, root)
+		delete(c.statuses, root)
+	}
+	c.mu.Unlock()
+	for root, watcher := range released {
+		watcher.Close()
+		c.clearIndexLines(root)
+	}
+}
+
+// gitCommonDir returns the common directory of the git directory, which
+// differs from it in linked worktrees
+func gitCommonDir(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if commonDir == "" {
+		return gitDir
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// isGitStateFile reports whether the file in the git directory affects the status
+func isGitStateFile(path string) bool {
+	base := filepath.Base(path)
+	if strings.HasSuffix(base, ".lock") {
+		return false
+	}
+	switch base {
+	case "HEAD", "index", "packed-refs", "FETCH_HEAD", "ORIG_HEAD", "MERGE_HEAD",
+		"CHERRY_PICK_HEAD", "REVERT_HEAD", "BISECT_LOG", "rebase-merge", "rebase-apply":
+		return true
+	}
+	return strings.Contains(filepath.ToSlash(path), "/refs/")
+}
+
+// subscribe starts sending the updated statuses to the workspace
+func (c *GitStatusCache) subscribe(w *Workspace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, w)
+}
+
+// unsubscribe stops sending the updated statuses to the workspace
+func (c *GitStatusCache) unsubscribe(w *Workspace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	subscribers := []*Workspace{}
+	for _, ws := range c.subscribers {
+		if ws != w {
+			subscribers = append(subscribers, ws)
+		}
+	}
+	c.subscribers = subscribers
+}
+
+// publish sends the status to the subscribed workspaces
+func (c *GitStatusCache) publish(status *GitStatus) {
+	c.mu.Lock()
+	subscribers := c.subscribers
+	c.mu.Unlock()
+	for _, ws := range subscribers {
+		ws.gitStatusUpdates <- status
+		ws.signal.GitStatusSignal()
+	}
+}
+
+// findGitRoot returns the work tree root and the git directory
+// containing the path, without running git
+func findGitRoot(path string) (string, string) {
+	dir := path
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		dotgit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotgit)
+		if err == nil {
+			if info.IsDir() {
+				return dir, dotgit
+			}
+			// .git is a file pointing the git directory in worktrees and submodules
+			content, err := ioutil.ReadFile(dotgit)
+			if err == nil && bytes.HasPrefix(content, []byte("gitdir: ")) {
+				gitDir := strings.TrimSpace(string(content[len("gitdir: "):]))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+				return dir, gitDir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+func getGitStatus(root string) (*GitStatus, error) {
+	// --no-optional-locks keeps git from rewriting the watched index
+	cmd := exec.Command("git", "--no-optional-locks", "-C", root, "status", "--porcelain=v2", "--branch")
+	osdepend.PrepareRunProc(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	status := parseGitStatus(out)
+	status.root = root
+	_, status.gitDir = findGitRoot(root)
+	status.state = gitState(status.gitDir)
+	return status, nil
+}
+
+// parseGitStatus parses the output of "git status --porcelain=v2 --branch"
+func parseGitStatus(out []byte) *GitStatus {
+	status := &GitStatus{
+		files:   make(map[string]string),
+		dirs:    make(map[string]string),
+		changes: make(map[string]string),
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 2 {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				status.oid = fields[2]
+			case "branch.head":
+				if fields[2] == "(detached)" {
+					status.detached = true
+				} else {
+					status.branch = fields[2]
+				}
+			case "branch.upstream":
+				status.upstream = fields[2]
+			case "branch.ab":
+				if len(fields) < 4 {
+					continue
+				}
+				status.ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				status.behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+			}
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			fields := strings.SplitN(line, " ", 9)
+			if len(fields) < 9 {
+				continue
+			}
+			status.addFile(fields[8], fields[1])
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path<tab>origPath
+			fields := strings.SplitN(line, " ", 10)
+			if len(fields) < 10 {
+				continue
+			}
+			status.addFile(strings.SplitN(fields[9], "\t", 2)[0], fields[1])
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			fields := strings.SplitN(line, " ", 11)
+			if len(fields) < 11 {
+				continue
+			}
+			status.conflicted++
+			status.changes[fields[10]] = fields[1]
+			status.setFile(fields[10], "conflicted")
+		case '?':
+			status.untracked++
+			status.changes[line[2:]] = "??"
+			status.setFile(line[2:], "untracked")
+		}
+	}
+	return status
+}
+
+func (g *GitStatus) addFile(path, xy string) {
+	if len(xy) < 2 {
+		return
+	}
+	g.changes[path] = xy
+	if xy[0] != '.' {
+		g.staged++
+	}
+	if xy[1] != '.' {
+		g.unstaged++
+	}
+	switch {
+	case xy[1] != '.':
+		g.setFile(path, "modified")
+	case xy[0] == 'A':
+		g.setFile(path, "added")
+	default:
+		g.setFile(path, "staged")
+	}
+}
+
+func (g *GitStatus) setFile(path, fileStatus string) {
+	path = strings.TrimSuffix(path, "/")
+	g.files[path] = fileStatus
+	dirStatus := "modified"
+	if fileStatus == "conflicted" {
+		dirStatus = fileStatus
+	}
+	for dir := filepath.Dir(filepath.FromSlash(path)); dir != "."; dir = filepath.Dir(dir) {
+		key := filepath.ToSlash(dir)
+		if g.dirs[key] != "conflicted" {
+			g.dirs[key] = dirStatus
+		}
+	}
+}
+
+// fileStatus returns the status of the file or the directory at the absolute path
+func (g *GitStatus) fileStatus(path string) string {
+	rel, err := filepath.Rel(g.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if fileStatus, ok := g.files[rel]; ok {
+		return fileStatus
+	}
+	return g.dirs[rel]
+}
+
+// gitFileColor returns the color of the file with the status
+func gitFileColor(fileStatus string) *RGBA {
+	switch fileStatus {
+	case "modified", "staged":
+		return newRGBA(203, 203, 65, 1)
+	case "added", "untracked":
+		return newRGBA(141, 193, 73, 1)
+	case "conflicted":
+		return newRGBA(204, 62, 68, 1)
+	}
+	return nil
+}
+
+// gitState returns the operation in progress in the repository
+func gitState(gitDir string) string {
+	if gitDir == "" {
+		return ""
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
+	}
+	switch {
+	case exists("rebase-merge"), exists("rebase-apply"):
+		return "rebase"
+	case exists("MERGE_HEAD"):
+		return "merge"
+	case exists("CHERRY_PICK_HEAD"):
+		return "cherry-pick"
+	case exists("REVERT_HEAD"):
+		return "revert"
+	case exists("BISECT_LOG"):
+		return "bisect"
+	}
+	return ""
+}
+
+// head returns the branch name, or the short SHA for detached HEAD
+func (g *GitStatus) head() string {
+	if !g.detached {
+		return g.branch
+	}
+	if len(g.oid) > 7 {
+		return g.oid[:7]
+	}
+	return g.oid
+}
+
+// String returns the status in the short form for the statusline
+func (g *GitStatus) String() string {
+	text := g.head()
+	if g.ahead > 0 {
+		text += fmt.Sprintf(" ↑%d", g.ahead)
+	}
+	if g.behind > 0 {
+		text += fmt.Sprintf(" ↓%d", g.behind)
+	}
+	if g.staged > 0 {
+		text += fmt.Sprintf(" +%d", g.staged)
+	}
+	if g.unstaged > 0 {
+		text += fmt.Sprintf(" ~%d", g.unstaged)
+	}
+	if g.untracked > 0 {
+		text += fmt.Sprintf(" ?%d", g.untracked)
+	}
+	if g.conflicted > 0 {
+		text += fmt.Sprintf(" !%d", g.conflicted)
+	}
+	if g.state != "" {
+		text += " " + strings.ToUpper(g.state)
+	}
+	return text
+}
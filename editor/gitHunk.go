@@ -0,0 +1,290 @@
+package editor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akiyosi/gonvim/osdepend"
+)
+
+// GitHunk is a changed region of the buffer compared with the git index
+type GitHunk struct {
+	kind  string // "add", "change" or "delete"
+	start int    // first line in the buffer, 1-based
+	count int    // number of lines in the buffer, 0 for "delete"
+}
+
+// gitIndexFile is the content of a file in the git index, cached with the
+// modification time of the index it was read from
+type gitIndexFile struct {
+	lines   []string
+	modTime time.Time
+}
+
+// maxDiffCost limits the edit distance computed by diffLines, which keeps
+// the trace of the diagonals growing quadratically with it
+const maxDiffCost = 300
+
+// end returns the last line of the hunk in the buffer
+func (h *GitHunk) end() int {
+	if h.count == 0 {
+		return h.start
+	}
+	return h.start + h.count - 1
+}
+
+// gitHunkColor returns the color of the marker of the hunk
+func gitHunkColor(kind string) *RGBA {
+	switch kind {
+	case "add":
+		return newRGBA(141, 193, 73, 1)
+	case "change":
+		return newRGBA(27, 161, 226, 1)
+	case "delete":
+		return newRGBA(204, 62, 68, 1)
+	}
+	return nil
+}
+
+// updateGitHunks computes the hunks of the current buffer against the index
+// and sends them to the GUI thread
+func (w *Workspace) updateGitHunks() {
+	buf, err := w.nvim.CurrentBuffer()
+	if err != nil {
+		return
+	}
+	file, err := w.nvim.BufferName(buf)
+	if err != nil {
+		return
+	}
+	hunks := []*GitHunk{}
+	if file != "" && !strings.HasPrefix(file, "term://") {
+		root, _ := findGitRoot(file)
+		if root != "" {
+			index, err := editor.gitStatuses.indexLines(root, file)
+			if err == nil {
+				lines, err := w.nvim.BufferLines(buf, 0, -1, false)
+				if err != nil {
+					return
+				}
+				current := make([]string, len(lines))
+				for i, line := range lines {
+					current[i] = strings.TrimSuffix(string(line), "\r")
+				}
+				hunks = diffHunks(index, current)
+			}
+		}
+	}
+	w.gitHunkUpdates <- hunks
+	w.signal.GitHunkSignal()
+}
+
+// indexLines returns the lines of the file in the git index, caching them
+// until the status of the repository or the index changes. An untracked file
+// has no lines, so that the whole file is regarded as added.
+func (c *GitStatusCache) indexLines(root, file string) ([]string, error) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	status := c.statuses[root]
+	c.mu.Unlock()
+	if status != nil && status.changes[filepath.ToSlash(rel)] == "??" {
+		return []string{}, nil
+	}
+
+	// The index may be rewritten by the git commands run outside gonvim
+	// before the watcher refreshes the status
+	var modTime time.Time
+	_, gitDir := findGitRoot(root)
+	info, err := os.Stat(filepath.Join(gitDir, "index"))
+	if err == nil {
+		modTime = info.ModTime()
+	}
+	c.mu.Lock()
+	cached, ok := c.index[file]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(modTime) {
+		return cached.lines, nil
+	}
+
+	cmd := exec.Command("git", "--no-optional-locks", "-C", root, "show", ":"+filepath.ToSlash(rel))
+	osdepend.PrepareRunProc(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimSuffix(string(out), "\n")
+	lines := []string{}
+	if content != "" {
+		for _, line := range strings.Split(content, "\n") {
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+	}
+
+	c.mu.Lock()
+	c.index[file] = &gitIndexFile{
+		lines:   lines,
+		modTime: modTime,
+	}
+	c.mu.Unlock()
+	return lines, nil
+}
+
+// clearIndexLines drops the cached index contents of the repository
+func (c *GitStatusCache) clearIndexLines(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for file := range c.index {
+		if strings.HasPrefix(file, root+string(filepath.Separator)) {
+			delete(c.index, file)
+		}
+	}
+}
+
+// diffHunks returns the hunks of b compared with a
+func diffHunks(a, b []string) []*GitHunk {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops := diffLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+
+	hunks := []*GitHunk{}
+	line := prefix
+	for i := 0; i < len(ops); {
+		if ops[i] == '=' {
+			line++
+			i++
+			continue
+		}
+		deleted := 0
+		added := 0
+		for ; i < len(ops) && ops[i] != '='; i++ {
+			if ops[i] == '-' {
+				deleted++
+			} else {
+				added++
+			}
+		}
+		hunk := &GitHunk{
+			start: line + 1,
+			count: added,
+		}
+		switch {
+		case added > 0 && deleted > 0:
+			hunk.kind = "change"
+		case added > 0:
+			hunk.kind = "add"
+		default:
+			hunk.kind = "delete"
+			// Mark the line above the removed lines
+			if line > 0 {
+				hunk.start = line
+			}
+		}
+		hunks = append(hunks, hunk)
+		line += added
+	}
+	return hunks
+}
+
+// diffLines returns the shortest edit script from a to b by the Myers
+// algorithm, as '=' for kept, '-' for deleted and '+' for inserted lines.
+// Beyond maxDiffCost, all the lines are regarded as replaced.
+func diffLines(a, b []string) []byte {
+	n := len(a)
+	m := len(b)
+	max := n + m
+	if max == 0 {
+		return []byte{}
+	}
+	if max > maxDiffCost {
+		max = maxDiffCost
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+	for d := 0; d <= max; d++ {
+		// Only the diagonals from -d-1 to d+1 are read back at the step d
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, n, m)
+			}
+		}
+	}
+
+	// Too different, regard everything as replaced
+	ops := []byte{}
+	for i := 0; i < n; i++ {
+		ops = append(ops, '-')
+	}
+	for i := 0; i < m; i++ {
+		ops = append(ops, '+')
+	}
+	return ops
+}
+
+// backtrackDiff follows the trace of diffLines back from the end, where
+// trace[d] holds the diagonals from -d-1 to d+1
+func backtrackDiff(trace [][]int, x, y int) []byte {
+	ops := []byte{}
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, '=')
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, '+')
+		} else {
+			ops = append(ops, '-')
+		}
+		x = prevX
+		y = prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, '=')
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,622 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akiyosi/gonvim/osdepend"
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+type gitsideSignal struct {
+	core.QObject
+	_ func() `signal:"commitSignal"`
+}
+
+// GitSide is the side bar for the source control of the repository of the
+// active workspace
+type GitSide struct {
+	signal        *gitsideSignal
+	commitUpdates chan error
+
+	widget       *widgets.QWidget
+	layout       *widgets.QVBoxLayout
+	scrollarea   *widgets.QScrollArea
+	branch       *widgets.QLabel
+	message      *widgets.QPlainTextEdit
+	commitButton *widgets.QLabel
+	empty        *widgets.QLabel
+	groups       []*GitSideGroup
+
+	root   string
+	status *GitStatus
+}
+
+// GitSideGroup is the group of the changed files in GitSide
+type GitSideGroup struct {
+	name   string
+	title  string
+	widget *widgets.QWidget
+	layout *widgets.QVBoxLayout
+	header *widgets.QWidget
+	label  *widgets.QLabel
+	items  []*GitSideItem
+}
+
+// GitSideItem is the changed file in GitSide
+type GitSideItem struct {
+	widget *widgets.QWidget
+	group  *GitSideGroup
+	path   string
+	code   byte
+}
+
+// gitSideChange is a changed file in a group of GitSide, with the status
+// letter shown in the group
+type gitSideChange struct {
+	path string
+	code byte
+}
+
+func newGitSide() *GitSide {
+	fg := editor.fgcolor
+	bg := editor.bgcolor
+
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(0)
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetContentsMargins(0, 0, 0, 0)
+	widget.SetLayout(layout)
+
+	headerWidget := widgets.NewQWidget(nil, 0)
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(20, 15, 20, 5)
+	header := widgets.NewQLabel(nil, 0)
+	header.SetContentsMargins(0, 0, 0, 0)
+	header.SetText("SOURCE CONTROL")
+	header.SetStyleSheet(fmt.Sprintf(" .QLabel{ color: %s;} ", fg.print()))
+	header.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	branch := widgets.NewQLabel(nil, 0)
+	branch.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	branch.SetAlignment(core.Qt__AlignRight)
+	headerLayout.AddWidget(header, 0, 0)
+	headerLayout.AddWidget(branch, 1, 0)
+	headerWidget.SetLayout(headerLayout)
+
+	commitWidget := widgets.NewQWidget(nil, 0)
+	commitLayout := widgets.NewQVBoxLayout()
+	commitLayout.SetContentsMargins(20, 5, 20, 5)
+	commitLayout.SetSpacing(5)
+	commitWidget.SetLayout(commitLayout)
+	message := widgets.NewQPlainTextEdit(nil)
+	message.SetPlaceholderText("Message (Ctrl+Enter to commit)")
+	message.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	message.SetFocusPolicy(core.Qt__ClickFocus)
+	message.SetFixedHeight((editor.config.Editor.FontSize + 6) * 4)
+	message.SetStyleSheet(fmt.Sprintf(".QPlainTextEdit { border: 1px solid %s; border-radius: 1px; background: rgba(%d, %d, %d, 1); selection-background-color: rgba(%d, %d, %d, 1); }", editor.config.SideBar.AccentColor, bg.R, bg.G, bg.B, gradColor(bg).R, gradColor(bg).G, gradColor(bg).B))
+	commitButton := widgets.NewQLabel(nil, 0)
+	commitButton.SetText("Commit")
+	commitButton.SetAlignment(core.Qt__AlignCenter)
+	commitButton.SetFixedHeight(28)
+	commitButton.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	commitButton.SetStyleSheet(" .QLabel { color: #ffffff; background: #0e639c; } ")
+	commitLayout.AddWidget(message, 0, 0)
+	commitLayout.AddWidget(commitButton, 0, 0)
+
+	empty := widgets.NewQLabel(nil, 0)
+	empty.SetContentsMargins(20, 10, 20, 10)
+	empty.SetWordWrap(true)
+	empty.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+
+	layout.AddWidget(headerWidget, 0, 0)
+	layout.AddWidget(commitWidget, 0, 0)
+	layout.AddWidget(empty, 0, 0)
+
+	side := &GitSide{
+		signal:        NewGitsideSignal(nil),
+		commitUpdates: make(chan error, 10),
+		widget:        widget,
+		layout:        layout,
+		branch:        branch,
+		message:       message,
+		commitButton:  commitButton,
+		empty:         empty,
+	}
+
+	for _, g := range [][]string{
+		{"staged", "STAGED CHANGES"},
+		{"unstaged", "CHANGES"},
+		{"untracked", "UNTRACKED"},
+	} {
+		group := side.newGroup(g[0], g[1])
+		layout.AddWidget(group.widget, 0, 0)
+		side.groups = append(side.groups, group)
+	}
+	layout.AddStretch(1)
+
+	side.signal.ConnectCommitSignal(func() {
+		err := <-side.commitUpdates
+		if err == nil {
+			side.message.Clear()
+		}
+	})
+	message.ConnectKeyPressEvent(func(event *gui.QKeyEvent) {
+		isReturn := event.Key() == int(core.Qt__Key_Return) || event.Key() == int(core.Qt__Key_Enter)
+		if isReturn && event.Modifiers()&core.Qt__ControlModifier > 0 {
+			side.commit()
+			return
+		}
+		message.KeyPressEventDefault(event)
+	})
+	commitButton.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		side.commit()
+	})
+	commitButton.ConnectEnterEvent(func(event *core.QEvent) {
+		commitButton.SetStyleSheet(" .QLabel { color: #ffffff; background: #1177bb; } ")
+	})
+	commitButton.ConnectLeaveEvent(func(event *core.QEvent) {
+		commitButton.SetStyleSheet(" .QLabel { color: #ffffff; background: #0e639c; } ")
+	})
+
+	sideStyle := fmt.Sprintf("QWidget { color: rgba(%d, %d, %d, 1); border-right: 0px solid; }", gradColor(fg).R, gradColor(fg).G, gradColor(fg).B)
+	side.widget.SetStyleSheet(fmt.Sprintf(".QWidget { padding-top: 5px; background-color: rgba(%d, %d, %d, 1); } ", shiftColor(bg, -5).R, shiftColor(bg, -5).G, shiftColor(bg, -5).B) + sideStyle)
+
+	sideArea := widgets.NewQScrollArea(nil)
+	sideArea.SetWidgetResizable(true)
+	sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	sideArea.SetFocusPolicy(core.Qt__ClickFocus)
+	sideArea.SetWidget(widget)
+	sideArea.SetFrameShape(widgets.QFrame__NoFrame)
+	sideArea.ConnectEnterEvent(func(event *core.QEvent) {
+		sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAsNeeded)
+	})
+	sideArea.ConnectLeaveEvent(func(event *core.QEvent) {
+		sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	})
+	sideArea.SetStyleSheet(fmt.Sprintf(".QScrollBar { border-width: 0px; background-color: %s; width: 5px; margin: 0 0 0 0; } .QScrollBar::handle:vertical {background-color: %s; min-height: 25px;} .QScrollBar::handle:vertical:hover {background-color: %s; min-height: 25px;} .QScrollBar::add-line:vertical, .QScrollBar::sub-line:vertical { border: none; background: none; } .QScrollBar::add-page:vertical, QScrollBar::sub-page:vertical { background: none; }", shiftColor(bg, -5).print(), gradColor(bg).print(), editor.config.SideBar.AccentColor))
+	side.scrollarea = sideArea
+
+	return side
+}
+
+func (side *GitSide) newGroup(name, title string) *GitSideGroup {
+	group := &GitSideGroup{
+		name:  name,
+		title: title,
+	}
+
+	header := widgets.NewQWidget(nil, 0)
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(20, 8, 20, 2)
+	header.SetLayout(headerLayout)
+	label := widgets.NewQLabel(nil, 0)
+	label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	headerLayout.AddWidget(label, 1, 0)
+	// Stage or unstage all files in the group at once
+	switch name {
+	case "staged":
+		headerLayout.AddWidget(side.newAction("−", "Unstage All", func() {
+			side.unstage(group.paths()...)
+		}), 0, 0)
+	default:
+		headerLayout.AddWidget(side.newAction("+", "Stage All", func() {
+			side.stage(group.paths()...)
+		}), 0, 0)
+	}
+
+	widget := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(0)
+	widget.SetLayout(layout)
+	layout.AddWidget(header, 0, 0)
+	widget.Hide()
+
+	group.widget = widget
+	group.layout = layout
+	group.header = header
+	group.label = label
+
+	return group
+}
+
+// newAction returns the clickable label running fn
+func (side *GitSide) newAction(text, tooltip string, fn func()) *widgets.QLabel {
+	action := widgets.NewQLabel(nil, 0)
+	action.SetText(text)
+	action.SetToolTip(tooltip)
+	action.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	action.SetContentsMargins(4, 0, 4, 0)
+	action.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		fn()
+	})
+	action.ConnectEnterEvent(func(event *core.QEvent) {
+		cursor := gui.NewQCursor()
+		cursor.SetShape(core.Qt__PointingHandCursor)
+		gui.QGuiApplication_SetOverrideCursor(cursor)
+		action.SetStyleSheet(fmt.Sprintf(" .QLabel { color: %s; } ", editor.config.SideBar.AccentColor))
+	})
+	action.ConnectLeaveEvent(func(event *core.QEvent) {
+		gui.QGuiApplication_RestoreOverrideCursor()
+		action.SetStyleSheet("")
+	})
+	return action
+}
+
+// update shows the status of the repository containing the cwd of the
+// active workspace
+func (side *GitSide) update() {
+	ws := editor.workspaces[editor.active]
+	root := ""
+	if ws.cwd != "" {
+		root, _ = findGitRoot(ws.cwd)
+	}
+	side.root = root
+	if root == "" {
+		side.setStatus(nil)
+		return
+	}
+	status := editor.gitStatuses.cached(root)
+	if status == nil {
+		// The status arrives at setStatus through Workspace.updateGitStatus
+		go editor.gitStatuses.get(root)
+		return
+	}
+	side.setStatus(status)
+}
+
+// setStatus redraws the changed files when the status is of the shown repository
+func (side *GitSide) setStatus(status *GitStatus) {
+	if status != nil && status.root != side.root {
+		return
+	}
+	side.status = status
+
+	for _, group := range side.groups {
+		for _, item := range group.items {
+			item.widget.DestroyQWidget()
+		}
+		group.items = nil
+	}
+
+	if status == nil {
+		side.branch.SetText("")
+		side.empty.SetText("The folder of the workspace is not a git repository.")
+		side.empty.Show()
+		for _, group := range side.groups {
+			group.widget.Hide()
+		}
+		return
+	}
+	side.branch.SetText(status.head())
+
+	for i, changes := range groupGitChanges(status) {
+		for _, change := range changes {
+			side.groups[i].addItem(side, change.path, change.code)
+		}
+	}
+
+	total := 0
+	for _, group := range side.groups {
+		group.label.SetText(fmt.Sprintf("%s  %d", group.title, len(group.items)))
+		if len(group.items) == 0 {
+			group.widget.Hide()
+		} else {
+			group.widget.Show()
+		}
+		total += len(group.items)
+	}
+	if total == 0 {
+		side.empty.SetText("There are no changes in the working tree.")
+		side.empty.Show()
+	} else {
+		side.empty.Hide()
+	}
+}
+
+// groupGitChanges returns the changed files of the staged, the unstaged and
+// the untracked groups, sorted by the path. A file both staged and modified
+// is in the first two groups.
+func groupGitChanges(status *GitStatus) [3][]gitSideChange {
+	groups := [3][]gitSideChange{}
+	paths := []string{}
+	for path := range status.changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		xy := status.changes[path]
+		switch {
+		case xy == "??":
+			groups[2] = append(groups[2], gitSideChange{path, '?'})
+		case status.files[strings.TrimSuffix(path, "/")] == "conflicted":
+			groups[1] = append(groups[1], gitSideChange{path, 'U'})
+		default:
+			if xy[0] != '.' {
+				groups[0] = append(groups[0], gitSideChange{path, xy[0]})
+			}
+			if xy[1] != '.' {
+				groups[1] = append(groups[1], gitSideChange{path, xy[1]})
+			}
+		}
+	}
+	return groups
+}
+
+func (group *GitSideGroup) addItem(side *GitSide, path string, code byte) {
+	item := &GitSideItem{
+		group: group,
+		path:  path,
+		code:  code,
+	}
+
+	widget := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQHBoxLayout()
+	layout.SetContentsMargins(28, 2, 20, 2)
+	layout.SetSpacing(4)
+	widget.SetLayout(layout)
+
+	name := widgets.NewQLabel(nil, 0)
+	name.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	dir := filepath.Dir(filepath.FromSlash(strings.TrimSuffix(path, "/")))
+	text := filepath.Base(filepath.FromSlash(strings.TrimSuffix(path, "/")))
+	if dir != "." {
+		bg := editor.bgcolor
+		text = fmt.Sprintf("%s <font color='%s'>%s</font>", text, gradColor(bg).print(), dir)
+	}
+	name.SetText(text)
+	name.SetToolTip(path)
+	name.SetSizePolicy2(widgets.QSizePolicy__Ignored, widgets.QSizePolicy__Preferred)
+	layout.AddWidget(name, 1, 0)
+
+	switch group.name {
+	case "staged":
+		layout.AddWidget(side.newAction("−", "Unstage Changes", func() {
+			side.unstage(item.path)
+		}), 0, 0)
+	default:
+		layout.AddWidget(side.newAction("↺", "Discard Changes", func() {
+			side.confirmDiscard(item)
+		}), 0, 0)
+		layout.AddWidget(side.newAction("+", "Stage Changes", func() {
+			side.stage(item.path)
+		}), 0, 0)
+	}
+
+	codeLabel := widgets.NewQLabel(nil, 0)
+	codeLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	codeLabel.SetText(string(code))
+	codeLabel.SetFixedWidth(editor.config.Editor.FontSize + 2)
+	codeLabel.SetAlignment(core.Qt__AlignCenter)
+	if color := gitCodeColor(code); color != nil {
+		codeLabel.SetStyleSheet(fmt.Sprintf(" .QLabel { color: %s; } ", color.print()))
+		name.SetStyleSheet(fmt.Sprintf(" .QLabel { color: %s; } ", color.print()))
+	}
+	layout.AddWidget(codeLabel, 0, 0)
+
+	widget.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		go side.open(item)
+	})
+	widget.ConnectEnterEvent(func(event *core.QEvent) {
+		bg := editor.bgcolor
+		widget.SetStyleSheet(fmt.Sprintf(" .QWidget { background-color: %s; } ", shiftColor(bg, -15).print()))
+	})
+	widget.ConnectLeaveEvent(func(event *core.QEvent) {
+		widget.SetStyleSheet("")
+	})
+
+	item.widget = widget
+	group.layout.AddWidget(widget, 0, 0)
+	group.items = append(group.items, item)
+}
+
+// paths returns the paths of the files in the group
+func (group *GitSideGroup) paths() []string {
+	paths := []string{}
+	for _, item := range group.items {
+		paths = append(paths, item.path)
+	}
+	return paths
+}
+
+// gitCodeColor returns the color of the status letter of the changed file
+func gitCodeColor(code byte) *RGBA {
+	switch code {
+	case 'M', 'R', 'C', 'T':
+		return gitFileColor("modified")
+	case 'A', '?':
+		return gitFileColor("added")
+	case 'D', 'U':
+		return gitFileColor("conflicted")
+	}
+	return nil
+}
+
+func (side *GitSide) stage(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+	root := side.root
+	go side.runGit(root, nil, stageArgs(paths)...)
+}
+
+func (side *GitSide) unstage(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+	root := side.root
+	go func() {
+		side.runGit(root, nil, unstageArgs(root, paths)...)
+	}()
+}
+
+func (side *GitSide) confirmDiscard(item *GitSideItem) {
+	root := side.root
+	path := item.path
+	args := discardArgs(path, item.code)
+	message := fmt.Sprintf("[Gonvim] Are you sure you want to discard the changes in %s?", path)
+	if item.code == '?' {
+		message = fmt.Sprintf("[Gonvim] Are you sure you want to delete %s?", path)
+	}
+
+	opts := []*NotifyButton{}
+	opt1 := &NotifyButton{
+		action: func() {
+			side.runGit(root, nil, args...)
+		},
+		text: "Discard",
+	}
+	opts = append(opts, opt1)
+	opt2 := &NotifyButton{
+		action: func() {},
+		text:   "Cancel",
+	}
+	opts = append(opts, opt2)
+
+	editor.pushNotification(NotifyWarn, 0, message, notifyOptionArg(opts))
+}
+
+func (side *GitSide) commit() {
+	if side.status == nil {
+		return
+	}
+	message := strings.TrimSpace(side.message.ToPlainText())
+	if message == "" {
+		editor.pushNotification(NotifyWarn, -1, "[Gonvim] Please enter a commit message.")
+		return
+	}
+	if side.status.staged == 0 {
+		editor.pushNotification(NotifyWarn, -1, "[Gonvim] There are no staged changes to commit.")
+		return
+	}
+	root := side.root
+	go func() {
+		err := side.runGit(root, strings.NewReader(message+"\n"), "commit", "-q", "-F", "-")
+		side.commitUpdates <- err
+		side.signal.CommitSignal()
+	}()
+}
+
+// stageArgs returns the git arguments to stage the files
+func stageArgs(paths []string) []string {
+	return append([]string{"add", "--"}, paths...)
+}
+
+// unstageArgs returns the git arguments to unstage the files. They are
+// removed from the index when there is no commit to reset them to yet.
+func unstageArgs(root string, paths []string) []string {
+	if gitCommand(root, nil, "rev-parse", "-q", "--verify", "HEAD") != nil {
+		return append([]string{"rm", "--cached", "-r", "-q", "--"}, paths...)
+	}
+	return append([]string{"reset", "-q", "--"}, paths...)
+}
+
+// discardArgs returns the git arguments to discard the changes of the file,
+// which deletes it when it is untracked
+func discardArgs(path string, code byte) []string {
+	if code == '?' {
+		return []string{"clean", "-f", "-d", "-q", "--", path}
+	}
+	return []string{"checkout", "-q", "--", path}
+}
+
+// runGit runs git in the repository, notifies the error if it fails, and
+// refreshes the status
+func (side *GitSide) runGit(root string, stdin io.Reader, args ...string) error {
+	if root == "" {
+		return errors.New("not a git repository")
+	}
+	err := gitCommand(root, stdin, args...)
+	if err != nil {
+		editor.pushNotification(NotifyWarn, -1, fmt.Sprintf("[Gonvim] git %s: %s", args[0], err))
+	}
+	editor.gitStatuses.refresh(root)
+	return err
+}
+
+// gitCommand runs git in the repository, returning the stderr as the error
+// if it fails
+func gitCommand(root string, stdin io.Reader, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+	osdepend.PrepareRunProc(cmd)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		errText := strings.TrimSpace(stderr.String())
+		if errText == "" {
+			return err
+		}
+		return errors.New(errText)
+	}
+	return nil
+}
+
+// open opens the file, with a vertical diff against HEAD when the file
+// exists in HEAD
+func (side *GitSide) open(item *GitSideItem) {
+	root := side.root
+	if root == "" {
+		return
+	}
+	nvim := editor.workspaces[editor.active].nvim
+	path := filepath.Join(root, filepath.FromSlash(item.path))
+	fnameescape := func(name string) string {
+		var escaped string
+		nvim.Call("fnameescape", &escaped, name)
+		return escaped
+	}
+
+	var head []byte
+	var err error
+	if item.code != '?' && item.code != 'A' {
+		cmd := exec.Command("git", "--no-optional-locks", "-C", root, "show", "HEAD:"+strings.TrimSuffix(item.path, "/"))
+		osdepend.PrepareRunProc(cmd)
+		head, err = cmd.Output()
+	}
+	if item.code == '?' || item.code == 'A' || err != nil {
+		nvim.Command("edit " + fnameescape(path))
+		return
+	}
+
+	// Write the HEAD version under its own name to keep the filetype detection
+	dir, err := ioutil.TempDir("", "gonvim-git")
+	if err != nil {
+		return
+	}
+	headPath := filepath.Join(dir, "HEAD."+filepath.Base(path))
+	err = ioutil.WriteFile(headPath, head, 0644)
+	if err != nil {
+		os.RemoveAll(dir)
+		return
+	}
+
+	_, err = os.Stat(path)
+	if err == nil {
+		nvim.Command("edit " + fnameescape(path))
+		nvim.Command("vertical diffsplit " + fnameescape(headPath))
+	} else {
+		// Deleted in the working tree
+		nvim.Command("edit " + fnameescape(headPath))
+	}
+	nvim.Command("setlocal readonly nomodifiable bufhidden=wipe")
+	// The temporary directory goes away with the buffer of the HEAD version
+	nvim.Command(fmt.Sprintf("autocmd BufWipeout <buffer> call delete(%s, 'rf')", vimStringLiteral(dir)))
+	if err == nil {
+		nvim.Command("wincmd p")
+	}
+}
@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestRepository returns a new git repository without commits
+func newTestRepository(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	gitTest(t, root, "init", "-q")
+	gitTest(t, root, "config", "user.name", "gonvim")
+	gitTest(t, root, "config", "user.email", "gonvim@example.com")
+	return root
+}
+
+func gitTest(t *testing.T, root string, args ...string) {
+	t.Helper()
+	err := gitCommand(root, nil, args...)
+	if err != nil {
+		t.Fatalf("git %v: %s", args, err)
+	}
+}
+
+func writeTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testGitGroups returns the changed files of the groups of GitSide
+func testGitGroups(t *testing.T, root string) [3][]gitSideChange {
+	t.Helper()
+	status, err := getGitStatus(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return groupGitChanges(status)
+}
+
+func TestGitSideGroups(t *testing.T) {
+	root := newTestRepository(t)
+	writeTestFile(t, root, "both.txt", "a\n")
+	writeTestFile(t, root, "modified.txt", "a\n")
+	writeTestFile(t, root, "staged.txt", "a\n")
+	gitTest(t, root, "add", ".")
+	gitTest(t, root, "commit", "-q", "-m", "init")
+
+	writeTestFile(t, root, "both.txt", "b\n")
+	gitTest(t, root, "add", "both.txt")
+	writeTestFile(t, root, "both.txt", "c\n")
+	writeTestFile(t, root, "modified.txt", "b\n")
+	writeTestFile(t, root, "staged.txt", "b\n")
+	gitTest(t, root, "add", "staged.txt")
+	writeTestFile(t, root, "new.txt", "a\n")
+
+	got := testGitGroups(t, root)
+	want := [3][]gitSideChange{
+		{{"both.txt", 'M'}, {"staged.txt", 'M'}},
+		{{"both.txt", 'M'}, {"modified.txt", 'M'}},
+		{{"new.txt", '?'}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+}
+
+func TestGitSideStageUnstageDiscard(t *testing.T) {
+	root := newTestRepository(t)
+	writeTestFile(t, root, "a.txt", "a\n")
+
+	// Unstaging works before the first commit too
+	gitTest(t, root, stageArgs([]string{"a.txt"})...)
+	got := testGitGroups(t, root)
+	if want := []gitSideChange{{"a.txt", 'A'}}; !reflect.DeepEqual(got[0], want) {
+		t.Errorf("staged before the first commit: got %v, want %v", got[0], want)
+	}
+	gitTest(t, root, unstageArgs(root, []string{"a.txt"})...)
+	got = testGitGroups(t, root)
+	if want := []gitSideChange{{"a.txt", '?'}}; len(got[0]) != 0 || !reflect.DeepEqual(got[2], want) {
+		t.Errorf("unstaged before the first commit: got %v, want untracked %v", got, want)
+	}
+
+	gitTest(t, root, stageArgs([]string{"a.txt"})...)
+	gitTest(t, root, "commit", "-q", "-m", "init")
+
+	writeTestFile(t, root, "a.txt", "b\n")
+	gitTest(t, root, stageArgs([]string{"a.txt"})...)
+	got = testGitGroups(t, root)
+	if want := []gitSideChange{{"a.txt", 'M'}}; !reflect.DeepEqual(got[0], want) || len(got[1]) != 0 {
+		t.Errorf("staged: got %v, want staged %v", got, want)
+	}
+	gitTest(t, root, unstageArgs(root, []string{"a.txt"})...)
+	got = testGitGroups(t, root)
+	if want := []gitSideChange{{"a.txt", 'M'}}; len(got[0]) != 0 || !reflect.DeepEqual(got[1], want) {
+		t.Errorf("unstaged: got %v, want unstaged %v", got, want)
+	}
+
+	gitTest(t, root, discardArgs("a.txt", 'M')...)
+	writeTestFile(t, root, "new.txt", "a\n")
+	gitTest(t, root, discardArgs("new.txt", '?')...)
+	got = testGitGroups(t, root)
+	if len(got[0])+len(got[1])+len(got[2]) != 0 {
+		t.Errorf("discarded: got %v, want no changes", got)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(root, "a.txt"))
+	if err != nil || string(content) != "a\n" {
+		t.Errorf("discarded: got a.txt %q, want %q", content, "a\n")
+	}
+}
@@ -0,0 +1,229 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+)
+
+// hoverMaxColumns is the maximum width of the hover popup in columns
+const hoverMaxColumns = 100
+
+// hoverHighlights are the highlight groups of the colorscheme coloring the
+// classes of the highlighted code blocks rendered by github_flavored_markdown
+var hoverHighlights = map[string]string{
+	"com": "Comment",
+	"str": "String",
+	"kwd": "Keyword",
+	"typ": "Type",
+	"lit": "Number",
+	"pun": "Delimiter",
+	"pln": "Normal",
+	"tag": "Tag",
+	"dec": "PreProc",
+	"atn": "Identifier",
+	"atv": "String",
+}
+
+// hoverHandlerLua overrides the textDocument/hover handler of the built-in
+// LSP client to show the hover in the GUI
+const hoverHandlerLua = `(function()
+  if vim.lsp == nil or vim.lsp.handlers == nil then
+    return
+  end
+  vim.lsp.handlers['textDocument/hover'] = function(...)
+    local args = {...}
+    -- The handlers take (err, method, result, ...) until nvim 0.5.1
+    local result = args[2]
+    if type(result) == 'string' then
+      result = args[3]
+    end
+    if not (result and result.contents) then
+      return
+    end
+    local lines = vim.lsp.util.convert_input_to_markdown_lines(result.contents)
+    lines = vim.lsp.util.trim_empty_lines(lines)
+    if vim.tbl_isempty(lines) then
+      return
+    end
+    local pos = vim.fn.screenpos(0, vim.fn.line('.'), vim.fn.col('.'))
+    vim.g.gonvim_hover_shown = 1
+    vim.fn.rpcnotify(0, 'Gui', 'gonvim_hover_show', table.concat(lines, '\n'), pos.row - 1, pos.col - 1)
+  end
+end)()`
+
+// Hover is the popup showing the markdown documentation of LSP hover
+type Hover struct {
+	ws      *Workspace
+	widget  *widgets.QTextBrowser
+	updates chan *HoverContent
+	row     int
+	col     int
+}
+
+// HoverContent is the rendered hover
+type HoverContent struct {
+	html       string
+	styleSheet string
+	row        int
+	col        int
+}
+
+func initHover() *Hover {
+	widget := widgets.NewQTextBrowser(nil)
+	widget.SetOpenExternalLinks(true)
+	widget.SetFocusPolicy(core.Qt__NoFocus)
+	widget.SetFrameShape(widgets.QFrame__NoFrame)
+	widget.SetContentsMargins(0, 0, 0, 0)
+	widget.Document().SetDocumentMargin(8)
+	widget.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	widget.SetHorizontalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	widget.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAsNeeded)
+	widget.Hide()
+
+	shadow := widgets.NewQGraphicsDropShadowEffect(nil)
+	shadow.SetBlurRadius(28)
+	shadow.SetColor(gui.NewQColor3(0, 0, 0, 80))
+	shadow.SetOffset3(0, 6)
+	widget.SetGraphicsEffect(shadow)
+
+	return &Hover{
+		widget:  widget,
+		updates: make(chan *HoverContent, 10),
+	}
+}
+
+// showItem renders the markdown of the gonvim_hover_show event, whose
+// arguments are the markdown and optionally the 0-based screen row and column
+func (h *Hover) showItem(args []interface{}) {
+	if len(args) < 1 {
+		return
+	}
+	markdown := ""
+	switch content := args[0].(type) {
+	case string:
+		markdown = content
+	case []interface{}:
+		lines := []string{}
+		for _, line := range content {
+			if s, ok := line.(string); ok {
+				lines = append(lines, s)
+			}
+		}
+		markdown = strings.Join(lines, "\n")
+	}
+	if strings.TrimSpace(markdown) == "" {
+		return
+	}
+
+	row := h.ws.screen.cursor[0]
+	col := h.ws.screen.cursor[1]
+	if len(args) >= 3 {
+		row = reflectToInt(args[1])
+		col = reflectToInt(args[2])
+	}
+
+	h.updates <- &HoverContent{
+		html:       string(github_flavored_markdown.Markdown([]byte(markdown))),
+		styleSheet: h.codeStyleSheet(),
+		row:        row,
+		col:        col,
+	}
+	h.ws.signal.HoverSignal()
+}
+
+// codeStyleSheet returns the style sheet coloring the code blocks with the
+// colors of the current colorscheme
+func (h *Hover) codeStyleSheet() string {
+	colors := h.ws.getHighlightColors(hoverHighlights)
+	styleSheet := fmt.Sprintf("pre, code { font-family: '%s'; } a { color: %s; } ", editor.config.Editor.FontFamily, editor.config.SideBar.AccentColor)
+	classes := []string{}
+	for class := range colors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		if colors[class] == "" {
+			continue
+		}
+		styleSheet += fmt.Sprintf(".%s { color: %s; } ", class, colors[class])
+	}
+	return styleSheet
+}
+
+func (h *Hover) update() {
+	content := <-h.updates
+	h.row = content.row
+	h.col = content.col
+
+	h.widget.Document().SetDefaultStyleSheet(content.styleSheet)
+	h.widget.SetHtml(content.html)
+	h.resize()
+	h.move()
+	h.widget.VerticalScrollBar().SetValue(0)
+	h.widget.Raise()
+	h.widget.Show()
+}
+
+// resize fits the popup to the content within hoverMaxColumns and half of
+// the screen height
+func (h *Hover) resize() {
+	screenWidth := h.ws.screen.widget.Width()
+	screenHeight := h.ws.screen.widget.Height()
+	maxWidth := hoverMaxColumns * h.ws.font.width
+	if maxWidth > screenWidth-20 {
+		maxWidth = screenWidth - 20
+	}
+	maxHeight := screenHeight / 2
+
+	document := h.widget.Document()
+	document.SetTextWidth(-1)
+	width := int(math.Ceil(document.IdealWidth()))
+	if width > maxWidth {
+		width = maxWidth
+	}
+	document.SetTextWidth(float64(width))
+	height := int(math.Ceil(document.Size().Height()))
+	if height > maxHeight {
+		height = maxHeight
+		// Make room for the scroll bar
+		width += h.widget.VerticalScrollBar().SizeHint().Width()
+	}
+	h.widget.SetFixedSize2(width+2, height+2)
+}
+
+// move places the popup below the cursor, or above it when there is more
+// room above, within the screen
+func (h *Hover) move() {
+	screenWidth := h.ws.screen.widget.Width()
+	screenHeight := h.ws.screen.widget.Height()
+	width := h.widget.Width()
+	height := h.widget.Height()
+
+	x := int(float64(h.col) * h.ws.font.truewidth)
+	if x+width > screenWidth {
+		x = screenWidth - width
+	}
+	if x < 0 {
+		x = 0
+	}
+	top := h.row * h.ws.font.lineHeight
+	y := top + h.ws.font.lineHeight
+	if y+height > screenHeight && top > screenHeight-y {
+		y = top - height
+		if y < 0 {
+			y = 0
+		}
+	}
+	h.widget.Move2(x, y)
+}
+
+func (h *Hover) hide() {
+	h.widget.Hide()
+}
@@ -1,7 +1,10 @@
 package editor
 
 import (
-	"sort"
+	"fmt"
+	"html"
+	"math"
+	"strings"
 	"sync"
 
 	"github.com/therecipe/qt/core"
@@ -10,45 +13,54 @@ import (
 	"github.com/therecipe/qt/widgets"
 )
 
+// locpopupMaxColumns is the maximum width of the messages in columns
+const locpopupMaxColumns = 80
+
 // Locpopup is the location popup
 type Locpopup struct {
-	ws     *Workspace
-	mutex  sync.Mutex
-	widget *widgets.QWidget
-	//typeLabel    *widgets.QLabel
-	typeLabel    *svg.QSvgWidget
-	typeText     string
-	contentLabel *widgets.QLabel
-	contentText  string
-	shown        bool
-	updates      chan []interface{}
+	ws      *Workspace
+	mutex   sync.Mutex
+	widget  *widgets.QWidget
+	layout  *widgets.QVBoxLayout
+	font    *gui.QFont
+	rows    []*LocpopupRow
+	items   []*Diagnostic
+	shown   bool
+	updates chan []interface{}
+
+	// x and y are the position of the cursor the popup is placed at
+	x int
+	y int
+
+	diagnostics []*Diagnostic
+	bufnr       int
+	line        int
+	col         int
+	mode        string
+	// dismissed is the cursor position where the popup was closed with <Esc>
+	dismissed [3]int
+}
+
+// LocpopupRow is a diagnostic in the location popup
+type LocpopupRow struct {
+	layout *widgets.QHBoxLayout
+	icon   *svg.QSvgWidget
+	label  *widgets.QLabel
 }
 
 func initLocpopup() *Locpopup {
 	widget := widgets.NewQWidget(nil, 0)
 	widget.SetContentsMargins(8, 8, 8, 8)
-	layout := widgets.NewQHBoxLayout()
+	layout := widgets.NewQVBoxLayout()
 	layout.SetContentsMargins(0, 0, 0, 0)
-	layout.SetSpacing(4)
+	layout.SetSpacing(8)
 	widget.SetLayout(layout)
-	//typeLabel := widgets.NewQLabel(nil, 0)
-	//typeLabel.SetContentsMargins(4, 1, 4, 1)
-	typeLabel := svg.NewQSvgWidget(nil)
-	typeLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
-	typeLabel.SetFixedSize2(editor.iconSize-1, editor.iconSize-1)
-
-	contentLabel := widgets.NewQLabel(nil, 0)
-	contentLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
-	contentLabel.SetContentsMargins(0, 0, 0, 0)
-
-	layout.AddWidget(typeLabel, 0, 0)
-	layout.AddWidget(contentLabel, 0, 0)
 
 	loc := &Locpopup{
-		widget:       widget,
-		typeLabel:    typeLabel,
-		contentLabel: contentLabel,
-		updates:      make(chan []interface{}, 1000),
+		widget:  widget,
+		layout:  layout,
+		font:    gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false),
+		updates: make(chan []interface{}, 1000),
 	}
 
 	shadow := widgets.NewQGraphicsDropShadowEffect(nil)
@@ -60,40 +72,158 @@ func initLocpopup() *Locpopup {
 	return loc
 }
 
+func (l *Locpopup) newRow() *LocpopupRow {
+	layout := widgets.NewQHBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(4)
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedSize2(editor.iconSize-1, editor.iconSize-1)
+	label := widgets.NewQLabel(nil, 0)
+	label.SetFont(l.font)
+	label.SetContentsMargins(0, 0, 0, 0)
+	label.SetWordWrap(true)
+	label.SetTextFormat(core.Qt__RichText)
+	layout.AddWidget(icon, 0, 0)
+	layout.AddWidget(label, 1, 0)
+	layout.SetAlignment(icon, core.Qt__AlignTop)
+	l.layout.AddLayout(layout, 0)
+
+	return &LocpopupRow{
+		layout: layout,
+		icon:   icon,
+		label:  label,
+	}
+}
+
 func (l *Locpopup) subscribe() {
+	// The diagnostics are fetched for the problems panel even without the popup
+	l.ws.nvim.RegisterHandler("LocPopup", func(args ...interface{}) {
+		l.handle(args)
+	})
+	l.ws.nvim.Subscribe("LocPopup")
 	if !l.ws.drawLint {
 		return
 	}
 	l.ws.signal.ConnectLocpopupSignal(func() {
 		l.updateLocpopup()
 	})
-	l.ws.nvim.RegisterHandler("LocPopup", func(args ...interface{}) {
-		l.handle(args)
-	})
-	l.ws.nvim.Subscribe("LocPopup")
 }
 
 func (l *Locpopup) updateLocpopup() {
-	if !l.shown {
+	l.mutex.Lock()
+	shown := l.shown
+	items := l.items
+	l.mutex.Unlock()
+	if !shown {
 		l.widget.Hide()
 		return
 	}
-	l.contentLabel.SetText(l.contentText)
-	if l.typeText == "E" {
-		//l.typeLabel.SetText("Error")
-		//l.typeLabel.SetStyleSheet("background-color: rgba(204, 62, 68, 1);")
-		svgContent := editor.getSvg("linterr", newRGBA(204, 62, 68, 1))
-		l.typeLabel.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
-	} else if l.typeText == "W" {
-		//l.typeLabel.SetText("Warning")
-		//l.typeLabel.SetStyleSheet("background-color: rgba(203, 203, 65, 1);")
-		svgContent := editor.getSvg("lintwrn", newRGBA(204, 203, 65, 1))
-		l.typeLabel.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+
+	for len(l.rows) < len(items) {
+		l.rows = append(l.rows, l.newRow())
+	}
+	width := l.labelWidth(items)
+	for i, row := range l.rows {
+		if i >= len(items) {
+			row.icon.Hide()
+			row.label.Hide()
+			continue
+		}
+		d := items[i]
+		icon, color := diagnosticIcon(d.severity)
+		svgContent := editor.getSvg(icon, color)
+		row.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+		row.label.SetFixedWidth(width)
+		row.label.SetText(locpopupText(d, color))
+		row.icon.Show()
+		row.label.Show()
 	}
+	l.widget.AdjustSize()
+	l.move()
 	l.widget.Hide()
 	l.widget.Show()
 }
 
+// labelWidth returns the width of the messages, which is the width of the
+// longest line capped by the screen width and locpopupMaxColumns
+func (l *Locpopup) labelWidth(items []*Diagnostic) int {
+	metrics := gui.NewQFontMetricsF(l.font)
+	width := 0.0
+	for _, d := range items {
+		lines := append(strings.Split(d.message, "\n"), locpopupHeader(d))
+		for _, line := range lines {
+			width = math.Max(width, metrics.HorizontalAdvance(line, -1))
+		}
+	}
+	maxWidth := locpopupMaxColumns * l.ws.font.width
+	if screenWidth := l.ws.screen.widget.Width() - 16 - 4 - editor.iconSize - 8; screenWidth < maxWidth {
+		maxWidth = screenWidth
+	}
+	if int(math.Ceil(width)) < maxWidth {
+		return int(math.Ceil(width))
+	}
+	return maxWidth
+}
+
+// locpopupHeader returns the severity, the source and the code of the diagnostic
+func locpopupHeader(d *Diagnostic) string {
+	header := severityName(d.severity)
+	if detail := diagnosticDetail(d); detail != "" {
+		header += " " + detail
+	}
+	return header
+}
+
+func locpopupText(d *Diagnostic, color *RGBA) string {
+	text := fmt.Sprintf("<b><font color='%s'>%s</font></b>", color.print(), severityName(d.severity))
+	if detail := diagnosticDetail(d); detail != "" {
+		text += fmt.Sprintf(" <font color='%s'>%s</font>", gradColor(editor.fgcolor).print(), html.EscapeString(detail))
+	}
+	message := strings.Replace(html.EscapeString(d.message), "\n", "<br>", -1)
+	return text + "<br>" + message
+}
+
+// setPosition places the popup below the cursor at x and y
+func (l *Locpopup) setPosition(x, y int) {
+	l.x = x
+	l.y = y
+	l.move()
+}
+
+// move places the popup below the cursor, or above it when there is no room
+// below, keeping it within the screen
+func (l *Locpopup) move() {
+	screenWidth := l.ws.screen.widget.Width()
+	screenHeight := l.ws.screen.widget.Height()
+	width := l.widget.Width()
+	height := l.widget.Height()
+
+	x := l.x
+	if x+width > screenWidth {
+		x = screenWidth - width
+	}
+	if x < 0 {
+		x = 0
+	}
+	y := l.y + l.ws.font.lineHeight
+	if y+height > screenHeight && l.y-height >= 0 {
+		y = l.y - height
+	}
+	l.widget.Move2(x, y)
+}
+
+// dismiss hides the popup until the cursor moves
+func (l *Locpopup) dismiss() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.shown {
+		return
+	}
+	l.dismissed = [3]int{l.bufnr, l.line, l.col}
+	l.shown = false
+	l.widget.Hide()
+}
+
 func (l *Locpopup) handle(args []interface{}) {
 	if len(args) < 1 {
 		return
@@ -103,116 +233,106 @@ func (l *Locpopup) handle(args []interface{}) {
 		return
 	}
 	switch event {
-	case "update":
-		l.update(args[1:])
+	case "update", "diagnostics":
+		l.updateDiagnostics()
+	case "cursor":
+		l.moveCursor(args[1:])
 	}
 }
 
-func (l *Locpopup) update(args []interface{}) {
-	l.mutex.Lock()
-	shown := false
-	defer func() {
-		if !shown {
-			l.shown = false
-			l.ws.signal.LocpopupSignal()
-		}
-		l.mutex.Unlock()
-	}()
-	buf, err := l.ws.nvim.CurrentBuffer()
+// updateDiagnostics fetches the diagnostics and redraws the counts and the popup
+func (l *Locpopup) updateDiagnostics() {
+	diagnostics, err := l.ws.getDiagnostics()
 	if err != nil {
 		return
 	}
-	buftype := new(string)
-	err = l.ws.nvim.BufferOption(buf, "buftype", buftype)
+	buf, err := l.ws.nvim.CurrentBuffer()
 	if err != nil {
 		return
 	}
-	if *buftype == "terminal" {
-		return
+	l.mutex.Lock()
+	l.diagnostics = diagnostics
+	l.bufnr = int(buf)
+	l.mutex.Unlock()
+	l.ws.signal.DiagnosticsSignal()
+	if l.ws.drawLint {
+		l.update()
 	}
+}
 
-	mode := new(string)
-	err = l.ws.nvim.Call("mode", mode, "")
-	if err != nil {
-		return
-	}
-	if *mode != "n" {
-		return
-	}
+// getDiagnostics returns the last fetched diagnostics
+func (l *Locpopup) getDiagnostics() []*Diagnostic {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.diagnostics
+}
 
-	curWin, err := l.ws.nvim.CurrentWindow()
-	if err != nil {
+// moveCursor receives the buffer, the cursor position and the mode, and
+// redraws with the cached diagnostics
+func (l *Locpopup) moveCursor(args []interface{}) {
+	if len(args) < 4 {
 		return
 	}
-	pos, err := l.ws.nvim.WindowCursor(curWin)
-	if err != nil {
-		return
+	mode, _ := args[3].(string)
+	l.mutex.Lock()
+	l.bufnr = reflectToInt(args[0])
+	l.line = reflectToInt(args[1])
+	l.col = reflectToInt(args[2])
+	l.mode = mode
+	l.mutex.Unlock()
+	l.update()
+}
+
+func (l *Locpopup) update() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	counts := countDiagnostics(l.diagnostics, l.bufnr)
+	l.ws.statusline.lint.redraw(counts[0], counts[1], counts[2], counts[3])
+
+	items := []*Diagnostic{}
+	if l.mode == "n" && l.dismissed != [3]int{l.bufnr, l.line, l.col} {
+		items = diagnosticsAt(l.diagnostics, l.bufnr, l.line, l.col)
 	}
-	result := new([]map[string]interface{})
-	err = l.ws.nvim.Call("getloclist", result, "winnr(\"$\")")
-	if err != nil {
+	shown := len(items) > 0
+	if shown == l.shown && sameDiagnostics(items, l.items) {
 		return
 	}
+	l.items = items
+	l.shown = shown
+	l.ws.signal.LocpopupSignal()
+}
 
-	errors := 0
-	warnings := 0
-	locs := []map[string]interface{}{}
-	for _, loc := range *result {
-		lnumInterface := loc["lnum"]
-		if lnumInterface == nil {
+// diagnosticsAt returns the diagnostics under the cursor, or the ones in
+// the cursor line when none of them covers the cursor
+func diagnosticsAt(diagnostics []*Diagnostic, bufnr, line, col int) []*Diagnostic {
+	inLine := []*Diagnostic{}
+	covering := []*Diagnostic{}
+	for _, d := range diagnostics {
+		if d.bufnr != bufnr || line < d.lnum || line > d.endLnum {
 			continue
 		}
-		lnum := reflectToInt(lnumInterface)
-		if lnum == pos[0] {
-			locs = append(locs, loc)
+		if line == d.lnum {
+			inLine = append(inLine, d)
 		}
-		locType := loc["type"].(string)
-		switch locType {
-		case "E":
-			errors++
-		case "W":
-			warnings++
+		if (line > d.lnum || col >= d.col) && (line < d.endLnum || col <= d.endCol) {
+			covering = append(covering, d)
 		}
 	}
-	l.ws.statusline.lint.redraw(errors, warnings)
-	if len(locs) == 0 {
-		return
+	if len(covering) > 0 {
+		return covering
 	}
-	if len(locs) > 1 {
-		sort.Sort(ByCol(locs))
+	return inLine
+}
+
+func sameDiagnostics(a, b []*Diagnostic) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	var loc map[string]interface{}
-	for _, loc = range locs {
-		if pos[1] >= reflectToInt(loc["col"])-1 {
-			break
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
 		}
 	}
-
-	locType := loc["type"].(string)
-	text := loc["text"].(string)
-	shown = true
-	if locType != l.typeText || text != l.contentText || shown != l.shown {
-		l.typeText = locType
-		l.contentText = text
-		l.shown = shown
-		l.ws.signal.LocpopupSignal()
-	}
-}
-
-// ByCol sorts locations by column
-type ByCol []map[string]interface{}
-
-// Len of locations
-func (s ByCol) Len() int {
-	return len(s)
-}
-
-// Swap locations
-func (s ByCol) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-
-// Less than
-func (s ByCol) Less(i, j int) bool {
-	return reflectToInt(s[i]["col"]) > reflectToInt(s[j]["col"])
+	return true
 }
@@ -7,7 +7,7 @@ import (
 	"runtime"
 	"time"
 
-	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/neovim/go-client/nvim"
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/webchannel"
@@ -23,15 +23,22 @@ const (
 	GonvimMarkdownUpdateEvent     = "gonvim_markdown_update"
 	GonvimMarkdownScrollDownEvent = "gonvim_markdown_scroll_down"
 	GonvimMarkdownScrollUpEvent   = "gonvim_markdown_scroll_up"
+	GonvimMarkdownExportEvent     = "gonvim_markdown_export"
 )
 
-// Markdown is the markdown preview window
+// Markdown is the preview window of markdown and the other filetypes which
+// have the renderers
 type Markdown struct {
 	webview         *webengine.QWebEngineView
 	webpage         *webengine.QWebEnginePage
 	ws              *Workspace
 	markdownUpdates chan string
+	exports         chan *MarkdownExport
 	container       *widgets.QPlainTextEdit
+	sync            *widgets.QPlainTextEdit
+	renderers       map[string]PreviewRenderer
+	buf             nvim.Buffer
+	baseDir         string
 	hidden          bool
 	htmlSet         bool
 }
@@ -41,13 +48,21 @@ func newMarkdown(workspace *Workspace) *Markdown {
 		webview:         webengine.NewQWebEngineView(nil),
 		webpage:         webengine.NewQWebEnginePage(nil),
 		markdownUpdates: make(chan string, 1000),
+		exports:         make(chan *MarkdownExport, 10),
+		renderers:       previewRenderers(),
 		ws:              workspace,
 	}
 	m.ws.signal.ConnectMarkdownSignal(func() {
 		content := <-m.markdownUpdates
 		if !m.htmlSet {
 			m.htmlSet = true
-			m.webpage.SetHtml(m.getHTML(content), core.NewQUrl())
+			// Resolve the relative paths of the images from the directory of
+			// the buffer
+			baseURL := core.NewQUrl()
+			if m.baseDir != "" {
+				baseURL = core.NewQUrl3(fileURL(m.baseDir)+"/", core.QUrl__TolerantMode)
+			}
+			m.webpage.SetHtml(m.getHTML(content), baseURL)
 		} else {
 			m.container.SetPlainTextDefault(content)
 			m.container.TextChanged()
@@ -57,6 +72,9 @@ func newMarkdown(workspace *Workspace) *Markdown {
 		// m.webpage.FindText(string(line), 0)
 		// m.webpage.FindText("", 0)
 	})
+	m.ws.signal.ConnectMarkdownExportSignal(func() {
+		m.printToPdf(<-m.exports)
+	})
 	m.webview.ConnectEventFilter(func(watched *core.QObject, event *core.QEvent) bool {
 		if event.Type() == core.QEvent__KeyPress {
 			keyPress := gui.NewQKeyEventFromPointer(event.Pointer())
@@ -88,8 +106,11 @@ func newMarkdown(workspace *Workspace) *Markdown {
 
 	m.webview.SetPage(m.webpage)
 	m.container = widgets.NewQPlainTextEdit(nil)
+	m.sync = widgets.NewQPlainTextEdit(nil)
+	m.sync.ConnectTextChanged(m.syncChanged)
 	channel := webchannel.NewQWebChannel(nil)
 	channel.RegisterObject("content", m.container)
+	channel.RegisterObject("sync", m.sync)
 	//m.webpage.SetWebChannel2(channel)
 	m.webpage.SetWebChannel(channel)
 	m.hide()
@@ -185,7 +206,24 @@ func (m *Markdown) openReadme(reponame string, readme string) {
 			}
 		}
 	}
-	m.ws.nvim.Command(`silent vertical split https://raw.githubusercontent.com/` + reponame + "/master/" + readme)
+	editor.deinSide.preDisplayedReadme = reponame
+	// Read the installed plugin, which is available offline and matches the
+	// installed revision
+	if dir := deinPluginPath(reponame); dir != "" && m.openLocalReadme(dir, readme) {
+		return
+	}
+	if readme == "" {
+		readme = "README.md"
+	}
+	// HEAD is the default branch, which is not always master
+	m.ws.nvim.Command(`silent vertical split https://raw.githubusercontent.com/` + reponame + "/HEAD/" + readme)
+	m.newBuffer()
+	m.openPreviewBuffer()
+}
+
+// openPreviewBuffer replaces the split of the source with the preview buffer
+// and goes back to the previous window
+func (m *Markdown) openPreviewBuffer() {
 	m.ws.nvim.Command(`e ` + GonvimMarkdownBufName)
 	m.ws.nvim.Command("setlocal filetype=" + GonvimMarkdownBufName)
 	m.ws.nvim.Command("setlocal buftype=nofile")
@@ -204,8 +242,6 @@ func (m *Markdown) openReadme(reponame string, readme string) {
 		GonvimMarkdownScrollUpEvent,
 	))
 	m.ws.nvim.Command("wincmd p")
-
-	editor.deinSide.preDisplayedReadme = reponame
 }
 
 func (m *Markdown) toggle() {
@@ -249,24 +285,60 @@ func (m *Markdown) update() {
 	if err != nil {
 		return
 	}
+	var filetype string
+	m.ws.nvim.BufferOption(buf, "filetype", &filetype)
+	renderer, ok := m.renderer(buf, filetype)
+	if !ok {
+		return
+	}
 	lines, err := m.ws.nvim.BufferLines(buf, 0, -1, false)
 	if err != nil {
 	}
-	content := []byte{}
+	m.buf = buf
+	name, err := m.ws.nvim.BufferName(buf)
+	if err == nil && filepath.IsAbs(name) {
+		m.baseDir = filepath.Dir(name)
+	} else {
+		m.baseDir = ""
+	}
+	source := []string{}
 	for _, line := range lines {
-		content = append(content, line...)
-		content = append(content, '\n')
+		source = append(source, string(line))
 	}
 
-	output := github_flavored_markdown.Markdown(content)
+	output, err := renderer.render(source, name)
+	if err == errPreviewCanceled {
+		return
+	}
+	if err != nil {
+		output = previewError(filetype, err)
+	}
 	m.markdownUpdates <- fmt.Sprintf(`
 			<div id="placeholder" class="markdown-body">
 			%s
 			</div>
-			`, string(output))
+			`, output)
 	m.ws.signal.MarkdownSignal()
 }
 
+// renderer returns the renderer of the filetype of the buffer. The help
+// files are rendered only when they are opened as the READMEs of the plugins.
+func (m *Markdown) renderer(buf nvim.Buffer, filetype string) (PreviewRenderer, bool) {
+	renderer, ok := m.renderers[filetype]
+	if ok {
+		return renderer, true
+	}
+	if filetype != "help" {
+		return nil, false
+	}
+	var readme int
+	err := m.ws.nvim.BufferVar(buf, "gonvim_readme", &readme)
+	if err != nil || readme == 0 {
+		return nil, false
+	}
+	return &helpRenderer{}, true
+}
+
 func (m *Markdown) getHTML(content string) string {
 	js := `
   var placeholder = document.getElementById('placeholder');
@@ -291,13 +363,22 @@ function getMethods(obj) {
   return result;
 }
 
+%s
   new QWebChannel(qt.webChannelTransport,
     function(channel) {
       var content = channel.objects.content;
+      var sync = channel.objects.sync;
+      document.addEventListener('click', function(event) {
+        var line = gonvimClickedLine(event);
+        if (line > 0) {
+          sync.plainText = line + ':' + Date.now();
+        }
+      });
       content.textChanged.connect(function() {
 		  var frag = document.createElement('div');
 		  frag.innerHTML = content.plainText;
 		  dd.apply(placeholder, dd.diff(placeholder, frag.firstElementChild));
+		  gonvimRenderExtensions();
 			//placeholder.innerHTML = content.plainText;
 			//morphdom(placeholder, content.plainText);
 			//console.warn(document.body.innerHTML);
@@ -305,6 +386,7 @@ function getMethods(obj) {
     }
   );
 	`
+	js = fmt.Sprintf(js, markdownSyncJS+markdownExtensionsJS)
 	style := `
 .markdown-body {
   -ms-text-size-adjust: 100%;
@@ -2461,8 +2543,11 @@ function getMethods(obj) {
 <html lang="en">
   <head>
 <script type="text/javascript" src="qrc:///qtwebchannel/qwebchannel.js"></script>
+%s
 <script>%s</script>
     <style>
+     %s
+     %s
      %s
 	</style>
   </head>
@@ -2473,7 +2558,7 @@ function getMethods(obj) {
   </script>
   </body>
 </html>
-	`, morphdomjs, style, content, js)
+	`, markdownAssetsHTML(), morphdomjs, style, markdownExtensionsStyle, previewRendererStyle, content, js)
 
 	return html
 }
@@ -0,0 +1,370 @@
+package editor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/webengine"
+)
+
+var (
+	// markdownImageRe matches the src of the images in the rendered html
+	markdownImageRe = regexp.MustCompile(`(<img[^>]*\ssrc=")([^"]+)(")`)
+	// markdownFontRe matches the woff2 fonts loaded relative to the style
+	// sheet of KaTeX, like url(fonts/KaTeX_Main-Regular.woff2)
+	markdownFontRe = regexp.MustCompile(`url\((fonts/[^)]+\.woff2)\)`)
+)
+
+// markdownExportRenderedTitle is the title of the page to print to pdf,
+// which markdownExportWaitJS sets when the diagrams are rendered
+const markdownExportRenderedTitle = "gonvim-rendered"
+
+// markdownExportWaitJS waits for the diagrams to be rendered asynchronously
+// up to 10 seconds, and tells it by the title of the page
+var markdownExportWaitJS = fmt.Sprintf(`
+(function() {
+  var start = Date.now();
+  var wait = function() {
+    if (window.gonvimRendered || Date.now() - start > 10000) {
+      document.title = '%s';
+    } else {
+      setTimeout(wait, 100);
+    }
+  };
+  wait();
+})();
+`, markdownExportRenderedTitle)
+
+// markdownExportStyle lays out the exported document like GitHub
+const markdownExportStyle = `
+.markdown-body {
+  box-sizing: border-box;
+  max-width: 980px;
+  margin: 0 auto;
+  padding: 45px;
+}
+`
+
+// MarkdownExport is an export of the preview to PDF waiting for the page
+type MarkdownExport struct {
+	html    string
+	baseDir string
+	path    string
+}
+
+// export writes the preview of the current markdown buffer to a html or a
+// pdf file, of :GonvimMarkdownExport html|pdf [path]. The path defaults to
+// the one of the buffer with the extension of the format, which is
+// overwritten only after a confirmation. The buffer itself is never
+// overwritten.
+func (m *Markdown) export(args []interface{}) {
+	format := ""
+	if len(args) > 0 {
+		format, _ = args[0].(string)
+	}
+	format = strings.ToLower(format)
+	if format != "html" && format != "pdf" {
+		editor.pushNotification(NotifyWarn, -1, "[Gonvim] Usage: GonvimMarkdownExport html|pdf [path]")
+		return
+	}
+
+	buf, err := m.ws.nvim.CurrentBuffer()
+	if err != nil {
+		return
+	}
+	name, _ := m.ws.nvim.BufferName(buf)
+	if filepath.Base(name) == GonvimMarkdownBufName {
+		buf = m.buf
+		name, _ = m.ws.nvim.BufferName(buf)
+	}
+	lines, err := m.ws.nvim.BufferLines(buf, 0, -1, false)
+	if err != nil {
+		return
+	}
+	source := []string{}
+	for _, line := range lines {
+		source = append(source, string(line))
+	}
+	var filetype string
+	m.ws.nvim.BufferOption(buf, "filetype", &filetype)
+	renderer, ok := m.renderer(buf, filetype)
+	if !ok {
+		renderer = &markdownRenderer{}
+	}
+	body, err := renderer.render(source, name)
+	if err != nil {
+		editor.pushNotification(NotifyError, -1, fmt.Sprintf("[Gonvim] Failed to render the %s preview: %s", filetype, err))
+		return
+	}
+
+	path := ""
+	if len(args) > 1 {
+		path, _ = args[1].(string)
+	}
+	explicit := path != ""
+	if explicit {
+		m.ws.nvim.Call("fnamemodify", &path, path, ":p")
+	} else if filepath.IsAbs(name) {
+		path = strings.TrimSuffix(name, filepath.Ext(name)) + "." + format
+	} else {
+		editor.pushNotification(NotifyWarn, -1, "[Gonvim] Please specify the path to export the markdown preview to.")
+		return
+	}
+	baseDir := ""
+	if filepath.IsAbs(name) {
+		baseDir = filepath.Dir(name)
+	}
+	title := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+
+	if filepath.IsAbs(name) && filepath.Clean(path) == filepath.Clean(name) {
+		editor.pushNotification(NotifyWarn, -1, fmt.Sprintf("[Gonvim] Can't export the markdown preview to the buffer itself: %s", path))
+		return
+	}
+
+	write := func() {
+		switch format {
+		case "html":
+			err := writeMarkdownHTML(path, body, baseDir, title)
+			if err != nil {
+				editor.pushNotification(NotifyError, -1, fmt.Sprintf("[Gonvim] Failed to export the markdown preview: %s", err))
+				return
+			}
+			notifyMarkdownExported(path)
+		case "pdf":
+			// The images are loaded from the base url instead of inlined, since
+			// SetHtml can't take more than 2MB
+			m.exports <- &MarkdownExport{
+				html:    exportMarkdownHTML(body, baseDir, title, false),
+				baseDir: baseDir,
+				path:    path,
+			}
+			m.ws.signal.MarkdownExportSignal()
+		}
+	}
+	if explicit || !fileExists(path) {
+		write()
+		return
+	}
+
+	opts := []*NotifyButton{}
+	opt1 := &NotifyButton{
+		action: write,
+		text:   "Overwrite",
+	}
+	opts = append(opts, opt1)
+	opt2 := &NotifyButton{
+		action: func() {},
+		text:   "Cancel",
+	}
+	opts = append(opts, opt2)
+
+	editor.pushNotification(NotifyWarn, 0, fmt.Sprintf("[Gonvim] %s already exists. Do you want to overwrite it?", path), notifyOptionArg(opts))
+}
+
+// printToPdf loads the html in an offscreen page and prints it to the pdf
+// after the diagrams are rendered
+func (m *Markdown) printToPdf(export *MarkdownExport) {
+	page := webengine.NewQWebEnginePage(nil)
+	page.ConnectLoadFinished(func(ok bool) {
+		if !ok {
+			editor.pushNotification(NotifyError, -1, "[Gonvim] Failed to load the markdown preview to export.")
+			page.DeleteLater()
+			return
+		}
+		page.RunJavaScript(markdownExportWaitJS)
+	})
+	page.ConnectTitleChanged(func(title string) {
+		if title != markdownExportRenderedTitle {
+			return
+		}
+		layout := gui.NewQPageLayout2(
+			gui.NewQPageSize2(gui.QPageSize__A4),
+			gui.QPageLayout__Portrait,
+			core.NewQMarginsF3(10, 10, 10, 10),
+			gui.QPageLayout__Millimeter,
+			core.NewQMarginsF3(0, 0, 0, 0),
+		)
+		page.PrintToPdf(export.path, layout)
+	})
+	page.ConnectPdfPrintingFinished(func(filePath string, success bool) {
+		if success {
+			notifyMarkdownExported(filePath)
+		} else {
+			editor.pushNotification(NotifyError, -1, fmt.Sprintf("[Gonvim] Failed to export the markdown preview to %s", filePath))
+		}
+		page.DeleteLater()
+	})
+	baseURL := core.NewQUrl()
+	if export.baseDir != "" {
+		baseURL = core.NewQUrl3(fileURL(export.baseDir)+"/", core.QUrl__TolerantMode)
+	}
+	page.SetHtml(export.html, baseURL)
+}
+
+// writeMarkdownHTML writes the standalone html of the rendered body with
+// everything inlined to the path
+func writeMarkdownHTML(path string, body string, baseDir string, title string) error {
+	return ioutil.WriteFile(path, []byte(exportMarkdownHTML(body, baseDir, title, true)), 0644)
+}
+
+// exportMarkdownHTML returns the standalone html of the rendered body, with
+// the style sheets, the scripts and optionally the local images inlined
+func exportMarkdownHTML(body string, baseDir string, title string, inline bool) string {
+	assets := markdownAssetsHTML()
+	if inline {
+		body = inlineMarkdownImages(body, baseDir)
+		assets = inlineMarkdownAssets()
+	}
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+%s
+<style>
+%s
+%s
+</style>
+</head>
+<body>
+<div class="markdown-body">
+%s
+</div>
+<script>
+%s
+</script>
+</body>
+</html>
+`, html.EscapeString(title), assets, markdownPreviewStyle(), markdownExportStyle, body, markdownExtensionsJS)
+}
+
+// markdownPreviewStyle returns the style sheets in the style tag of the
+// preview page
+func markdownPreviewStyle() string {
+	page := (&Markdown{}).getHTML("")
+	start := strings.Index(page, "<style>")
+	if start < 0 {
+		return ""
+	}
+	page = page[start+len("<style>"):]
+	end := strings.Index(page, "</style>")
+	if end < 0 {
+		return ""
+	}
+	return page[:end]
+}
+
+// inlineMarkdownAssets returns KaTeX and Mermaid in the assets directory
+// inlined in style and script tags
+func inlineMarkdownAssets() string {
+	dir := markdownAssetsDir()
+	if dir == "" {
+		return ""
+	}
+	tags := ""
+	katexCSS := filepath.Join(dir, markdownAssets.katexCSS)
+	if content, err := ioutil.ReadFile(katexCSS); err == nil {
+		tags += fmt.Sprintf("<style>\n%s\n</style>\n", inlineMarkdownFonts(string(content), filepath.Dir(katexCSS)))
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, markdownAssets.katexJS)); err == nil {
+		tags += fmt.Sprintf("<script>\n%s\n</script>\n", content)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, markdownAssets.mermaidJS)); err == nil {
+		// Mermaid is kept as text and run only if the browser supports it
+		tags += fmt.Sprintf("<script type=\"text/plain\" id=\"gonvim-mermaid\">\n%s\n</script>\n", content)
+		tags += markdownMermaidHTML(`var script = document.createElement('script');
+  script.text = document.getElementById('gonvim-mermaid').textContent;
+  document.head.appendChild(script);`)
+	}
+	return tags
+}
+
+// inlineMarkdownFonts replaces the woff2 fonts in the style sheet in the
+// directory with data urls. The browsers prefer them to the woff and the ttf
+// ones, which are left as is.
+func inlineMarkdownFonts(css string, dir string) string {
+	return markdownFontRe.ReplaceAllStringFunc(css, func(font string) string {
+		path := markdownFontRe.FindStringSubmatch(font)[1]
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return font
+		}
+		return fmt.Sprintf("url(data:font/woff2;base64,%s)", base64.StdEncoding.EncodeToString(content))
+	})
+}
+
+// inlineMarkdownImages replaces the local images with data urls
+func inlineMarkdownImages(body string, baseDir string) string {
+	return markdownImageRe.ReplaceAllStringFunc(body, func(tag string) string {
+		match := markdownImageRe.FindStringSubmatch(tag)
+		src := html.UnescapeString(match[2])
+		u, err := url.Parse(src)
+		if err != nil {
+			return tag
+		}
+		path := ""
+		switch u.Scheme {
+		case "file":
+			path = filepath.FromSlash(u.Path)
+		case "":
+			path = filepath.FromSlash(u.Path)
+			if !filepath.IsAbs(path) {
+				if baseDir == "" {
+					return tag
+				}
+				path = filepath.Join(baseDir, path)
+			}
+		default:
+			return tag
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return tag
+		}
+		mimeType := mime.TypeByExtension(filepath.Ext(path))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		data := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(content))
+		return match[1] + data + match[3]
+	})
+}
+
+// notifyMarkdownExported notifies the exported file with a button opening
+// its folder
+func notifyMarkdownExported(path string) {
+	dir := filepath.Dir(path)
+	editor.pushNotification(NotifyInfo, -1, "[Gonvim] Exported the markdown preview to "+path, notifyOptionArg([]*NotifyButton{
+		{
+			action: func() {
+				openFolder(dir)
+			},
+			text: "Open folder",
+		},
+	}))
+}
+
+// openFolder opens the folder with the file manager
+func openFolder(dir string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", dir)
+	case "windows":
+		cmd = exec.Command("explorer", dir)
+	default:
+		cmd = exec.Command("xdg-open", dir)
+	}
+	cmd.Start()
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// testMarkdownFont is the stub of a font of KaTeX
+const testMarkdownFont = "wOF2"
+
+// testMarkdownAssets puts KaTeX and Mermaid stubs in ~/.gonvim/markdown of a
+// temporary home
+func testMarkdownAssets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	homedir.Reset()
+	t.Cleanup(homedir.Reset)
+
+	dir := filepath.Join(home, ".gonvim", "markdown")
+	for path, content := range map[string]string{
+		markdownAssets.katexCSS:                    `@font-face{src:url(fonts/x.woff2) format("woff2"),url(fonts/x.woff) format("woff")}`,
+		markdownAssets.katexJS:                     "var katexStub;",
+		markdownAssets.mermaidJS:                   "var mermaidStub;",
+		filepath.Join("katex", "fonts", "x.woff2"): testMarkdownFont,
+	} {
+		path = filepath.Join(dir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, filepath.Dir(path), filepath.Base(path), content)
+	}
+}
+
+func TestWriteMarkdownHTML(t *testing.T) {
+	testMarkdownAssets(t)
+	baseDir := t.TempDir()
+	image := []byte("\x89PNG\r\n\x1a\n")
+	err := os.Mkdir(filepath.Join(baseDir, "images"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(baseDir, "images"), "logo.png", string(image))
+
+	body := `<p><img src="images/logo.png" alt="logo"><img src="https://example.com/remote.png"><img src="missing.png"></p>`
+	path := filepath.Join(t.TempDir(), "README.html")
+	err = writeMarkdownHTML(path, body, baseDir, "<README>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(content)
+
+	for _, want := range []string{
+		"<title>&lt;README&gt;</title>",
+		// The style sheets of the preview and the export
+		".markdown-body {",
+		markdownExtensionsStyle,
+		markdownExportStyle,
+		// KaTeX and Mermaid
+		// The woff2 font is inlined and the others are left as is
+		`<style>
+@font-face{src:url(data:font/woff2;base64,` + base64.StdEncoding.EncodeToString([]byte(testMarkdownFont)) + `) format("woff2"),url(fonts/x.woff) format("woff")}
+</style>`,
+		"<script>\nvar katexStub;\n</script>",
+		"<script type=\"text/plain\" id=\"gonvim-mermaid\">\nvar mermaidStub;\n</script>",
+		// The local image
+		`<img src="data:image/png;base64,` + base64.StdEncoding.EncodeToString(image) + `" alt="logo">`,
+		// The remote and the missing images are left as is
+		`<img src="https://example.com/remote.png">`,
+		`<img src="missing.png">`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("exported html doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(output, "file://") {
+		t.Errorf("exported html links local files:\n%s", output)
+	}
+}
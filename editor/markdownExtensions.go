@@ -0,0 +1,371 @@
+package editor
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/shurcooL/github_flavored_markdown"
+)
+
+var (
+	// markdownDisplayMathRe matches the display math like $$ x^2 $$
+	markdownDisplayMathRe = regexp.MustCompile(`(?s)\$\$(.+?)\$\$`)
+	// markdownInlineMathRe matches the inline math like $x^2$, which neither
+	// starts nor ends with a space unlike the prices like "$5 and $10"
+	markdownInlineMathRe = regexp.MustCompile(`\$([^\s$]|[^\s$][^$\n]*?[^\s$\\])\$`)
+	// markdownCodeSpanRe matches the code spans, in which math is not rendered
+	markdownCodeSpanRe = regexp.MustCompile("`+[^`]*`+")
+	// markdownFootnoteDefRe matches the footnote definitions like [^1]: text
+	markdownFootnoteDefRe = regexp.MustCompile(`^\s{0,3}\[\^([^\]]+)\]:\s*(.*)$`)
+	// markdownFootnoteRefRe matches the footnote references like [^1]
+	markdownFootnoteRefRe = regexp.MustCompile(`\[\^([^\]]+)\]`)
+)
+
+// markdownMermaidSupportedJS checks if the engine can run the bundled
+// Mermaid, which needs the class static blocks, the logical assignments and
+// structuredClone of Chromium 98. The QtWebEngine of older Qt can't even
+// parse it, so it isn't loaded there and the diagrams are shown as code.
+const markdownMermaidSupportedJS = `(function() {
+  try {
+    new Function('class A { static { } }; var a; a ||= 1;');
+  } catch (e) {
+    return false;
+  }
+  return typeof structuredClone == 'function' && typeof Object.hasOwn == 'function';
+})()`
+
+// markdownExtensionsJS renders the math with KaTeX and the diagrams with
+// Mermaid when their assets are loaded, and marks the task list items. The
+// diagrams are rendered asynchronously and gonvimRenderExtensions returns
+// the promise of them. window.gonvimRendered is set when the first render
+// finishes.
+const markdownExtensionsJS = `
+  if (window.mermaid) {
+    mermaid.initialize({startOnLoad: false});
+  }
+
+  var gonvimRenderExtensions = function() {
+    if (window.katex) {
+      document.querySelectorAll('.gonvim-math').forEach(function(el) {
+        if (el.dataset.rendered) {
+          return;
+        }
+        el.dataset.rendered = '1';
+        try {
+          katex.render(el.textContent, el, {displayMode: el.dataset.display == '1', throwOnError: false});
+        } catch (e) {
+        }
+      });
+    }
+    var rendered = Promise.resolve();
+    if (window.mermaid) {
+      try {
+        rendered = mermaid.run({nodes: document.querySelectorAll('.mermaid:not([data-processed])')});
+      } catch (e) {
+      }
+    }
+    document.querySelectorAll('li > input[type="checkbox"]:first-child').forEach(function(el) {
+      el.parentNode.classList.add('task-list-item');
+    });
+    return rendered;
+  };
+  window.gonvimRendered = false;
+  gonvimRenderExtensions().catch(function() {}).then(function() {
+    window.gonvimRendered = true;
+  });
+`
+
+// markdownExtensionsStyle styles the math, the diagrams and the footnotes
+// like GitHub
+const markdownExtensionsStyle = `
+.markdown-body .gonvim-math[data-display="1"] {
+  display: block;
+  overflow-x: auto;
+  text-align: center;
+  margin: 0 0 16px;
+}
+
+.markdown-body .mermaid {
+  text-align: center;
+  margin: 0 0 16px;
+}
+
+.markdown-body .footnotes {
+  font-size: 12px;
+  color: #6a737d;
+  border-top: 1px solid #eaecef;
+  margin-top: 16px;
+  padding-top: 8px;
+}
+
+.markdown-body .footnotes ol {
+  padding-left: 16px;
+}
+
+.markdown-body .footnote-ref a {
+  font-size: 0.75em;
+}
+`
+
+// markdownAssets are the paths of KaTeX and Mermaid in the assets directory
+var markdownAssets = struct {
+	katexCSS  string
+	katexJS   string
+	mermaidJS string
+}{
+	katexCSS:  filepath.Join("katex", "katex.min.css"),
+	katexJS:   filepath.Join("katex", "katex.min.js"),
+	mermaidJS: filepath.Join("mermaid", "mermaid.min.js"),
+}
+
+// markdownAssetsDir returns the directory of the assets of the preview, the
+// markdown directory bundled next to the binary or ~/.gonvim/markdown
+func markdownAssetsDir() string {
+	dirs := []string{}
+	exe, err := os.Executable()
+	if err == nil {
+		dirs = append(dirs,
+			filepath.Join(filepath.Dir(exe), "markdown"),
+			// gonvim.app/Contents/Resources on macOS
+			filepath.Join(filepath.Dir(exe), "..", "Resources", "markdown"),
+		)
+	}
+	home, err := homedir.Dir()
+	if err == nil {
+		dirs = append(dirs, filepath.Join(home, ".gonvim", "markdown"))
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// markdownAssetsHTML returns the tags loading the assets found in the
+// assets directory
+func markdownAssetsHTML() string {
+	dir := markdownAssetsDir()
+	if dir == "" {
+		return ""
+	}
+	tags := ""
+	if path := filepath.Join(dir, markdownAssets.katexCSS); fileExists(path) {
+		tags += fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s\">\n", fileURL(path))
+	}
+	if path := filepath.Join(dir, markdownAssets.katexJS); fileExists(path) {
+		tags += fmt.Sprintf("<script type=\"text/javascript\" src=\"%s\"></script>\n", fileURL(path))
+	}
+	if path := filepath.Join(dir, markdownAssets.mermaidJS); fileExists(path) {
+		tag := fmt.Sprintf("<script type=\"text/javascript\" src=\"%s\">", fileURL(path))
+		tags += markdownMermaidHTML(fmt.Sprintf("document.write(%q + '</scr' + 'ipt>');", tag))
+	}
+	return tags
+}
+
+// markdownMermaidHTML returns the script running the statement loading
+// Mermaid if the engine supports it
+func markdownMermaidHTML(load string) string {
+	return fmt.Sprintf("<script type=\"text/javascript\">\nif (%s) {\n  %s\n}\n</script>\n", markdownMermaidSupportedJS, load)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// fileURL returns the file:// url of the path
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// C:/Users/... on Windows
+		path = "/" + path
+	}
+	u := &url.URL{Scheme: "file", Path: path}
+	return u.String()
+}
+
+// markdownPlaceholders keeps the html of the math, the diagrams and the
+// footnote references replaced by placeholders before github_flavored_markdown
+// renders the markdown, which would break them
+type markdownPlaceholders struct {
+	html []string
+}
+
+func (p *markdownPlaceholders) add(html string) string {
+	p.html = append(p.html, html)
+	return fmt.Sprintf("GONVIMPLACEHOLDER%dX", len(p.html)-1)
+}
+
+func (p *markdownPlaceholders) restore(output string) string {
+	for i := len(p.html) - 1; i >= 0; i-- {
+		output = strings.Replace(output, fmt.Sprintf("GONVIMPLACEHOLDER%dX", i), p.html[i], -1)
+	}
+	return output
+}
+
+// markdownFootnotes are the footnotes of a document in the order of their
+// first references
+type markdownFootnotes struct {
+	definitions map[string]string
+	order       []string
+}
+
+// renderMarkdown renders a block of markdown with math, Mermaid diagrams and
+// footnotes
+func renderMarkdown(lines []string, footnotes *markdownFootnotes) string {
+	placeholders := &markdownPlaceholders{}
+	text := []string{}
+	out := []string{}
+	flush := func() {
+		if len(text) > 0 {
+			out = append(out, protectMarkdownText(strings.Join(text, "\n"), placeholders, footnotes))
+			text = nil
+		}
+	}
+	fence := ""
+	var mermaid []string
+	for _, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				if mermaid != nil {
+					out = append(out, "", placeholders.add(fmt.Sprintf("<div class=\"mermaid\">%s</div>", html.EscapeString(strings.Join(mermaid, "\n")))), "")
+					mermaid = nil
+					continue
+				}
+			} else if mermaid != nil {
+				mermaid = append(mermaid, line)
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := markdownFenceRe.FindStringSubmatch(line); m != nil {
+			flush()
+			fence = m[1]
+			if strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), fence)) == "mermaid" {
+				mermaid = []string{}
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		text = append(text, line)
+	}
+	flush()
+	if mermaid != nil {
+		// Unclosed while typing
+		out = append(out, "", placeholders.add(fmt.Sprintf("<div class=\"mermaid\">%s</div>", html.EscapeString(strings.Join(mermaid, "\n")))))
+	}
+	output := github_flavored_markdown.Markdown([]byte(strings.Join(out, "\n") + "\n"))
+	return placeholders.restore(string(output))
+}
+
+// protectMarkdownText replaces the math and the footnote references out of
+// the code spans with placeholders
+func protectMarkdownText(text string, placeholders *markdownPlaceholders, footnotes *markdownFootnotes) string {
+	codeSpans := markdownCodeSpanRe.FindAllStringIndex(text, -1)
+	inCode := func(start, end int) bool {
+		for _, span := range codeSpans {
+			if start < span[1] && end > span[0] {
+				return true
+			}
+		}
+		return false
+	}
+	replace := func(re *regexp.Regexp, text string, fn func(match []string) string) string {
+		result := ""
+		last := 0
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			if inCode(loc[0], loc[1]) {
+				continue
+			}
+			// Skip the prices like "$5"
+			if re == markdownInlineMathRe && loc[1] < len(text) && text[loc[1]] >= '0' && text[loc[1]] <= '9' {
+				continue
+			}
+			match := []string{}
+			for i := 0; i < len(loc); i += 2 {
+				match = append(match, text[loc[i]:loc[i+1]])
+			}
+			result += text[last:loc[0]] + fn(match)
+			last = loc[1]
+		}
+		return result + text[last:]
+	}
+
+	text = replace(markdownDisplayMathRe, text, func(match []string) string {
+		return placeholders.add(fmt.Sprintf("<span class=\"gonvim-math\" data-display=\"1\">%s</span>", html.EscapeString(strings.TrimSpace(match[1]))))
+	})
+	// The positions of the code spans changed
+	codeSpans = markdownCodeSpanRe.FindAllStringIndex(text, -1)
+	text = replace(markdownInlineMathRe, text, func(match []string) string {
+		return placeholders.add(fmt.Sprintf("<span class=\"gonvim-math\" data-display=\"0\">%s</span>", html.EscapeString(match[1])))
+	})
+	codeSpans = markdownCodeSpanRe.FindAllStringIndex(text, -1)
+	text = replace(markdownFootnoteRefRe, text, func(match []string) string {
+		id := match[1]
+		if _, ok := footnotes.definitions[id]; !ok {
+			return match[0]
+		}
+		n := footnotes.number(id)
+		return placeholders.add(fmt.Sprintf("<sup class=\"footnote-ref\"><a href=\"#fn-%s\" id=\"fnref-%s\">%d</a></sup>", html.EscapeString(id), html.EscapeString(id), n))
+	})
+	return text
+}
+
+// number returns the number of the footnote in the order of the references
+func (f *markdownFootnotes) number(id string) int {
+	for i, o := range f.order {
+		if o == id {
+			return i + 1
+		}
+	}
+	f.order = append(f.order, id)
+	return len(f.order)
+}
+
+// splitMarkdownFootnotes removes the footnote definitions from the source
+func splitMarkdownFootnotes(lines []string) ([]string, *markdownFootnotes) {
+	footnotes := &markdownFootnotes{definitions: make(map[string]string)}
+	source := []string{}
+	for _, line := range lines {
+		m := markdownFootnoteDefRe.FindStringSubmatch(line)
+		if m == nil {
+			source = append(source, line)
+			continue
+		}
+		footnotes.definitions[m[1]] = m[2]
+		// Keep the line numbers of the blocks
+		source = append(source, "")
+	}
+	return source, footnotes
+}
+
+// html returns the footnotes section
+func (f *markdownFootnotes) html() string {
+	if len(f.order) == 0 {
+		return ""
+	}
+	items := ""
+	for _, id := range f.order {
+		content := renderMarkdown([]string{f.definitions[id]}, &markdownFootnotes{})
+		// Put the back link in the paragraph
+		content = strings.TrimSpace(content)
+		backlink := fmt.Sprintf(" <a href=\"#fnref-%s\">&#8617;</a>", html.EscapeString(id))
+		if strings.HasSuffix(content, "</p>") {
+			content = strings.TrimSuffix(content, "</p>") + backlink + "</p>"
+		} else {
+			content += backlink
+		}
+		items += fmt.Sprintf("<li id=\"fn-%s\">%s</li>", html.EscapeString(id), content)
+	}
+	return fmt.Sprintf("<section class=\"footnotes\"><ol>%s</ol></section>", items)
+}
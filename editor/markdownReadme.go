@@ -0,0 +1,94 @@
+package editor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// readmeNames are the names of the READMEs looked up in the installed
+// plugins, in the order of preference
+var readmeNames = []string{
+	"README.md",
+	"README.markdown",
+	"README.mkd",
+	"README.rst",
+	"README.adoc",
+	"README.asciidoc",
+	"README.txt",
+	"README",
+}
+
+// openLocalReadme opens the README of the plugin installed in the directory,
+// or the help file in doc/ if there is no README
+func (m *Markdown) openLocalReadme(dir string, readme string) bool {
+	path, filetype := localReadme(dir, readme)
+	if path == "" {
+		return false
+	}
+	var escaped string
+	err := m.ws.nvim.Call("fnameescape", &escaped, path)
+	if err != nil {
+		return false
+	}
+	m.ws.nvim.Command("silent vertical split " + escaped)
+	m.ws.nvim.Command("setlocal filetype=" + filetype)
+	if filetype == "help" {
+		// The help file is previewed only as the README
+		m.ws.nvim.Command("let b:gonvim_readme = 1")
+		m.ws.nvim.Command(`au! GonvimAuMd BufEnter <buffer> call rpcnotify(0, "Gui", "gonvim_markdown_new_buffer")`)
+	}
+	m.newBuffer()
+	m.openPreviewBuffer()
+	return true
+}
+
+// localReadme returns the README in the directory with the filetype to
+// render it, falling back to the help file in doc/
+func localReadme(dir string, readme string) (string, string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", ""
+	}
+	names := readmeNames
+	if readme != "" {
+		names = append([]string{readme}, names...)
+	}
+	for _, name := range names {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(entry.Name(), name) {
+				return filepath.Join(dir, entry.Name()), readmeFiletype(entry.Name())
+			}
+		}
+	}
+
+	docs, _ := filepath.Glob(filepath.Join(dir, "doc", "*.txt"))
+	if len(docs) == 0 {
+		return "", ""
+	}
+	sort.Strings(docs)
+	// Prefer the help file named after the plugin, like doc/fugitive.txt of
+	// vim-fugitive
+	plugin := strings.ToLower(filepath.Base(dir))
+	for _, doc := range docs {
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(doc), ".txt"))
+		if strings.Contains(plugin, name) {
+			return doc, "help"
+		}
+	}
+	return docs[0], "help"
+}
+
+// readmeFiletype returns the filetype of the README, which is regarded as
+// markdown unless the extension says otherwise
+func readmeFiletype(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".rst":
+		return "rst"
+	case ".adoc", ".asciidoc":
+		return "asciidoc"
+	default:
+		return "markdown"
+	}
+}
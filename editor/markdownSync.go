@@ -0,0 +1,213 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/neovim/go-client/nvim"
+)
+
+var (
+	// markdownFenceRe matches the fences of code blocks
+	markdownFenceRe = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	// markdownListRe matches the list items
+	markdownListRe = regexp.MustCompile(`^\s{0,3}([-*+]|\d+[.)])\s`)
+	// markdownHeadingRe matches the ATX headings
+	markdownHeadingRe = regexp.MustCompile(`^\s{0,3}#{1,6}(\s|$)`)
+	// markdownLinkDefRe matches the link reference definitions, which apply
+	// to all the blocks
+	markdownLinkDefRe = regexp.MustCompile(`^\s{0,3}\[[^\]^][^\]]*\]:\s*\S`)
+	// markdownHTMLOpenRe matches the lines opening HTML blocks like <details>
+	markdownHTMLOpenRe = regexp.MustCompile(`^\s{0,3}<([a-zA-Z][a-zA-Z0-9-]*)`)
+)
+
+// markdownVoidElements are the HTML elements without the end tags
+var markdownVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// markdownSyncJS scrolls the preview to the block of a source line and sends
+// the source line of a clicked block back through the sync object
+const markdownSyncJS = `
+  var gonvimBlocks = function() {
+    return Array.prototype.slice.call(document.querySelectorAll('[data-line]'));
+  };
+
+  var gonvimScrollToLine = function(line) {
+    var blocks = gonvimBlocks();
+    if (blocks.length == 0) {
+      return;
+    }
+    var i = 0;
+    while (i + 1 < blocks.length && parseInt(blocks[i + 1].dataset.line) <= line) {
+      i++;
+    }
+    var block = blocks[i];
+    var start = parseInt(block.dataset.line);
+    var top = block.getBoundingClientRect().top + window.pageYOffset;
+    if (i + 1 < blocks.length && line > start) {
+      var next = blocks[i + 1];
+      var nextTop = next.getBoundingClientRect().top + window.pageYOffset;
+      var nextStart = parseInt(next.dataset.line);
+      top += (nextTop - top) * (line - start) / (nextStart - start);
+    }
+    window.scrollTo({top: top - window.innerHeight / 3, behavior: 'smooth'});
+  };
+
+  var gonvimClickedLine = function(event) {
+    var block = event.target.closest('[data-line]');
+    if (!block) {
+      return 0;
+    }
+    var start = parseInt(block.dataset.line);
+    var next = block.nextElementSibling;
+    if (!next || !next.dataset.line) {
+      return start;
+    }
+    var rect = block.getBoundingClientRect();
+    var ratio = (event.clientY - rect.top) / rect.height;
+    return start + Math.floor((parseInt(next.dataset.line) - start) * ratio);
+  };
+`
+
+// markdownBlock is a top level block of markdown with its first source line
+type markdownBlock struct {
+	line  int // 1-based
+	lines []string
+}
+
+// splitMarkdownBlocks splits the source into the top level blocks separated
+// by blank lines, keeping the items of a list, the code blocks and the HTML
+// blocks together, and returns the link reference definitions
+func splitMarkdownBlocks(lines []string) ([]*markdownBlock, []string) {
+	blocks := []*markdownBlock{}
+	refs := []string{}
+	var block *markdownBlock
+	fence := ""
+	var htmlTag *regexp.Regexp
+	htmlDepth := 0
+	blank := true
+	for i, line := range lines {
+		if fence != "" {
+			block.lines = append(block.lines, line)
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if htmlTag != nil {
+			// The HTML block lasts until its element is closed
+			block.lines = append(block.lines, line)
+			htmlDepth += markdownHTMLDepth(htmlTag, line)
+			if htmlDepth <= 0 {
+				htmlTag = nil
+			}
+			blank = strings.TrimSpace(line) == ""
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			if block != nil {
+				block.lines = append(block.lines, line)
+			}
+			continue
+		}
+		if markdownLinkDefRe.MatchString(line) {
+			refs = append(refs, line)
+			continue
+		}
+		heading := markdownHeadingRe.MatchString(line)
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		inList := block != nil && markdownListRe.MatchString(block.lines[0])
+		newBlock := block == nil || heading || (blank && !indented && !(inList && markdownListRe.MatchString(line)))
+		if block != nil && markdownHeadingRe.MatchString(block.lines[0]) {
+			newBlock = true
+		}
+		if newBlock {
+			block = &markdownBlock{line: i + 1}
+			blocks = append(blocks, block)
+		}
+		block.lines = append(block.lines, line)
+		if m := markdownFenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+		} else if m := markdownHTMLOpenRe.FindStringSubmatch(line); m != nil && !markdownVoidElements[strings.ToLower(m[1])] {
+			tag := regexp.MustCompile(`(?i)<(/?)` + m[1] + `(?:[\s/>]|$)`)
+			if depth := markdownHTMLDepth(tag, line); depth > 0 {
+				htmlTag = tag
+				htmlDepth = depth
+			}
+		}
+		blank = false
+	}
+	return blocks, refs
+}
+
+// markdownHTMLDepth returns the number of the start tags minus that of the
+// end tags of the element in the line
+func markdownHTMLDepth(tag *regexp.Regexp, line string) int {
+	depth := 0
+	for _, m := range tag.FindAllStringSubmatch(line, -1) {
+		if m[1] == "" {
+			depth++
+		} else {
+			depth--
+		}
+	}
+	return depth
+}
+
+// renderMarkdownBlocks renders the blocks of the source annotated with their
+// source lines, followed by the footnotes
+func renderMarkdownBlocks(lines []string) string {
+	source, footnotes := splitMarkdownFootnotes(lines)
+	blocks, refs := splitMarkdownBlocks(source)
+	html := ""
+	for _, block := range blocks {
+		content := append(append(block.lines, ""), refs...)
+		html += fmt.Sprintf("<div data-line=\"%d\">%s</div>\n", block.line, renderMarkdown(content, footnotes))
+	}
+	return html + footnotes.html()
+}
+
+// cursorMoved scrolls the preview to the cursor line of the source buffer
+func (m *Markdown) cursorMoved(bufnr int, line int) {
+	if m.hidden || !m.htmlSet || int(m.buf) != bufnr {
+		return
+	}
+	m.webpage.RunJavaScript(fmt.Sprintf("gonvimScrollToLine(%d)", line))
+}
+
+// syncChanged moves the cursor of the source window to the line clicked in
+// the preview, sent as "line:timestamp"
+func (m *Markdown) syncChanged() {
+	text := m.sync.ToPlainText()
+	line, err := strconv.Atoi(strings.Split(text, ":")[0])
+	if err != nil || line < 1 {
+		return
+	}
+	go m.jumpToLine(line)
+}
+
+func (m *Markdown) jumpToLine(line int) {
+	wins, err := m.ws.nvim.TabpageWindows(nvim.Tabpage(0))
+	if err != nil {
+		return
+	}
+	for _, win := range wins {
+		buf, err := m.ws.nvim.WindowBuffer(win)
+		if err != nil || buf != m.buf {
+			continue
+		}
+		count, err := m.ws.nvim.BufferLineCount(buf)
+		if err == nil && line > count {
+			line = count
+		}
+		m.ws.nvim.SetCurrentWindow(win)
+		m.ws.nvim.SetWindowCursor(win, [2]int{line, 0})
+		return
+	}
+}
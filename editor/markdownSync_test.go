@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		blocks []markdownBlock
+		refs   []string
+	}{
+		{
+			name:   "paragraphs",
+			source: "# Title\ntext\n\nnext\nline",
+			blocks: []markdownBlock{
+				{1, []string{"# Title"}},
+				{2, []string{"text", ""}},
+				{4, []string{"next", "line"}},
+			},
+		},
+		{
+			name:   "lists",
+			source: "- a\n\n- b\n\n  more b\n\n1. c\n\ntext",
+			blocks: []markdownBlock{
+				{1, []string{"- a", "", "- b", "", "  more b", "", "1. c", ""}},
+				{9, []string{"text"}},
+			},
+		},
+		{
+			name:   "fences",
+			source: "```go\na\n\n# b\n```\n\n~~~\nc\n~~~",
+			blocks: []markdownBlock{
+				{1, []string{"```go", "a", "", "# b", "```", ""}},
+				{7, []string{"~~~", "c", "~~~"}},
+			},
+		},
+		{
+			name:   "html blocks",
+			source: "<details>\n<summary>a</summary>\n\nb\n\n<details>\n\nc\n</details>\n</details>\n\n<img src=\"x.png\">\n\n<div>d</div>\n\ntext",
+			blocks: []markdownBlock{
+				{1, []string{"<details>", "<summary>a</summary>", "", "b", "", "<details>", "", "c", "</details>", "</details>", ""}},
+				{12, []string{"<img src=\"x.png\">", ""}},
+				{14, []string{"<div>d</div>", ""}},
+				{16, []string{"text"}},
+			},
+		},
+		{
+			name:   "reference definitions",
+			source: "[a]: https://example.com/a\ntext [a] [b]\n\n  [b]: <https://example.com/b> \"b\"\n[^1]: note",
+			blocks: []markdownBlock{
+				{2, []string{"text [a] [b]", ""}},
+				{5, []string{"[^1]: note"}},
+			},
+			refs: []string{"[a]: https://example.com/a", "  [b]: <https://example.com/b> \"b\""},
+		},
+	}
+	for _, test := range tests {
+		blocks, refs := splitMarkdownBlocks(strings.Split(test.source, "\n"))
+		got := []markdownBlock{}
+		for _, block := range blocks {
+			got = append(got, *block)
+		}
+		if !reflect.DeepEqual(got, test.blocks) {
+			t.Errorf("%s: got blocks %q, want %q", test.name, got, test.blocks)
+		}
+		if test.refs == nil {
+			test.refs = []string{}
+		}
+		if !reflect.DeepEqual(refs, test.refs) {
+			t.Errorf("%s: got refs %q, want %q", test.name, refs, test.refs)
+		}
+	}
+}
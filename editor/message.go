@@ -2,84 +2,153 @@ package editor
 
 import (
 	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/svg"
 	"github.com/therecipe/qt/widgets"
 )
 
-// Message isj
+// messageMaxItems is the maximum number of the messages shown at once
+const messageMaxItems = 10
+
+// confirmChoiceRe matches a choice of confirm(), like "[Y]es" or "(N)o"
+var confirmChoiceRe = regexp.MustCompile(`[\[(]([^\])])[\])]`)
+
+// confirmSubChoicesRe matches the choices of :substitute with the c flag,
+// like "(y/n/a/q/l/^E/^Y)"
+var confirmSubChoicesRe = regexp.MustCompile(`\(([^()]*/[^()]*)\)\?*\s*$`)
+
+// Message shows the messages of ext_messages as toasts
 type Message struct {
 	ws      *Workspace
-	kind    string
 	width   int
 	widget  *widgets.QWidget
-	layout  *widgets.QGridLayout
+	layout  *widgets.QVBoxLayout
 	items   []*MessageItem
 	expires int
+	history *MessageHistory
+
+	// ruler shows the texts of msg_showmode, msg_showcmd and msg_ruler
+	ruler     *widgets.QLabel
+	showmode  string
+	showcmd   string
+	rulerText string
 }
 
-// MessageItem is
+// MessageItem is a message of msg_show
 type MessageItem struct {
 	m       *Message
-	active  bool
 	kind    string
 	text    string
 	hideAt  time.Time
-	expired bool
 	icon    *svg.QSvgWidget
 	label   *widgets.QLabel
+	buttons *widgets.QWidget
 	widget  *widgets.QWidget
 }
 
+// MessageHistory is the panel of msg_history_show, the output of :messages,
+// and of the output of the commands like :ls
+type MessageHistory struct {
+	m         *Message
+	widget    *widgets.QWidget
+	title     *widgets.QLabel
+	closeIcon *svg.QSvgWidget
+	browser   *widgets.QTextBrowser
+	output    []string
+}
+
 func initMessage() *Message {
-	width := 250
 	widget := widgets.NewQWidget(nil, 0)
 	widget.SetContentsMargins(0, 0, 0, 0)
-	layout := widgets.NewQGridLayout2()
+	widget.SetAttribute(core.Qt__WA_TranslucentBackground, true)
+	layout := widgets.NewQVBoxLayout()
 	layout.SetContentsMargins(0, 0, 0, 0)
-	layout.SetSpacing(0)
+	layout.SetSpacing(6)
 	layout.SetSizeConstraint(widgets.QLayout__SetMinAndMaxSize)
 	widget.SetLayout(layout)
-	widget.SetStyleSheet("* {background-color: rgba(24, 29, 34, 1); color: rgba(205, 211, 222, 1);}")
+	widget.Hide()
+
+	ruler := widgets.NewQLabel(nil, 0)
+	ruler.SetContentsMargins(8, 2, 8, 2)
+	ruler.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	ruler.SetTextFormat(core.Qt__PlainText)
+	ruler.Hide()
 
 	m := &Message{
-		width:   width,
+		width:   250,
 		widget:  widget,
 		layout:  layout,
 		expires: 10,
+		ruler:   ruler,
 	}
+	m.history = initMessageHistory(m)
+	return m
+}
 
-	items := []*MessageItem{}
-	for i := 0; i < 10; i++ {
-		w := widgets.NewQWidget(nil, 0)
-		w.SetContentsMargins(0, 0, 0, 0)
-		w.SetStyleSheet(".QWidget {border-left: 1px solid #000; border-bottom: 1px solid #000;}")
-		w.SetFixedWidth(34)
-		icon := svg.NewQSvgWidget(nil)
-		icon.SetFixedSize2(14, 14)
-		icon.SetParent(w)
-		icon.Move2(10, 10)
-		l := widgets.NewQLabel(nil, 0)
-		l.SetContentsMargins(10, 10, 10, 10)
-		l.SetStyleSheet("border-bottom: 1px solid #000; border-left: 1px solid #000; border-right: 1px solid #000;")
-		l.SetWordWrap(true)
-		l.SetText(fmt.Sprintf("ldksj sdlkfjd  lkdsj sdlkfj sdlfkj lsdlfj  dslfjdsf sdfdslfkjdsf lksdjf sdklfj sldfkj sldkfj sdlfkj sdlkfjsdf test%d", i))
-		layout.AddWidget(w, i, 0, 0)
-		layout.AddWidget(l, i, 1, 0)
-		w.Hide()
-		l.Hide()
-		items = append(items, &MessageItem{
-			m:      m,
-			label:  l,
-			icon:   icon,
-			widget: w,
-		})
+func initMessageHistory(m *Message) *MessageHistory {
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetContentsMargins(0, 0, 0, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(0)
+	widget.SetLayout(layout)
+
+	header := widgets.NewQWidget(nil, 0)
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(10, 4, 8, 4)
+	header.SetLayout(headerLayout)
+	title := widgets.NewQLabel(nil, 0)
+	title.SetText("Messages")
+	closeIcon := svg.NewQSvgWidget(nil)
+	closeIcon.SetFixedSize2(editor.iconSize-1, editor.iconSize-1)
+	svgContent := editor.getSvg("cross", nil)
+	closeIcon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	headerLayout.AddWidget(title, 1, 0)
+	headerLayout.AddWidget(closeIcon, 0, 0)
+
+	browser := widgets.NewQTextBrowser(nil)
+	browser.SetFocusPolicy(core.Qt__NoFocus)
+	browser.SetFrameShape(widgets.QFrame__NoFrame)
+	browser.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	browser.SetHorizontalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	browser.Document().SetDocumentMargin(8)
+
+	layout.AddWidget(header, 0, 0)
+	layout.AddWidget(browser, 1, 0)
+	widget.Hide()
+
+	h := &MessageHistory{
+		m:         m,
+		widget:    widget,
+		title:     title,
+		closeIcon: closeIcon,
+		browser:   browser,
 	}
-	widget.Show()
-	m.items = items
-	return m
+	closeIcon.ConnectMousePressEvent(func(event *gui.QMouseEvent) {
+		h.hide()
+	})
+	closeIcon.ConnectEnterEvent(func(event *core.QEvent) {
+		svgContent := editor.getSvg("hoverclose", nil)
+		closeIcon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	})
+	closeIcon.ConnectLeaveEvent(func(event *core.QEvent) {
+		svgContent := editor.getSvg("cross", nil)
+		closeIcon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	})
+	return h
+}
+
+func (m *Message) setParent(parent *widgets.QWidget) {
+	m.widget.SetParent(parent)
+	m.ruler.SetParent(parent)
+	m.history.widget.SetParent(parent)
 }
 
 func (m *Message) subscribe() {
@@ -88,152 +157,448 @@ func (m *Message) subscribe() {
 	})
 }
 
+// setColor styles the messages with the colors of the colorscheme
+func (m *Message) setColor(fg, bg, border *RGBA) {
+	m.widget.SetStyleSheet(fmt.Sprintf(".QWidget#messageItem { border: 1px solid %s; background-color: %s; } QLabel { color: %s; }", border.print(), bg.print(), fg.print()))
+	m.ruler.SetStyleSheet(fmt.Sprintf("QLabel { background-color: %s; color: %s; }", bg.print(), gradColor(fg).print()))
+	m.history.widget.SetStyleSheet(fmt.Sprintf(".QWidget { border-top: 1px solid %s; } QWidget { background-color: %s; } * { color: %s; }", border.print(), bg.print(), fg.print()))
+}
+
+// update removes the expired messages
 func (m *Message) update() {
 	now := time.Now()
-	hasExpired := false
+	items := []*MessageItem{}
 	for _, item := range m.items {
-		if !item.active {
-			break
-		}
-		if item.active && item.hideAt.Before(now) {
-			item.expired = true
-			hasExpired = true
+		if !item.hideAt.IsZero() && item.hideAt.Before(now) {
+			item.widget.DestroyQWidget()
+			continue
 		}
+		items = append(items, item)
 	}
-	if !hasExpired {
-		return
-	}
-	i := 0
-	for _, item := range m.items {
-		if item.active && !item.expired {
-			m.items[i].copy(item)
-			item.expired = true
-			i++
-		}
+	m.items = items
+	m.move()
+}
+
+func (m *Message) resize() {
+	m.width = m.ws.screen.widget.Width() / 3
+	if m.width < 250 {
+		m.width = 250
 	}
 	for _, item := range m.items {
-		if item.active && item.expired {
-			item.hide()
-		}
+		item.resize()
 	}
+	m.move()
+	m.history.resize()
+	m.moveRuler()
 }
 
-func (m *Message) resize() {
-	m.width = m.ws.width / 4
-	m.widget.Move2(m.ws.width-m.width-34, 0)
-	m.widget.Resize2(m.width+34, 0)
-	for _, item := range m.items {
-		item.label.SetMinimumHeight(0)
-		item.label.SetMinimumHeight(item.label.HeightForWidth(m.width))
+// move places the messages at the top right of the screen
+func (m *Message) move() {
+	if len(m.items) == 0 {
+		m.widget.Hide()
+		return
 	}
+	m.widget.AdjustSize()
+	m.widget.Move2(m.ws.screen.widget.Width()-m.widget.Width()-10, 10)
+	m.widget.Raise()
+	m.widget.Show()
 }
 
-func (m *Message) chunk(args []interface{}) {
-	text := ""
+// msgShow handles msg_show, whose arguments are [kind, content, replace_last]
+func (m *Message) msgShow(args []interface{}) {
+	output := false
 	for _, arg := range args {
-		chunk, ok := arg.([]interface{})
-		if !ok {
+		a, ok := arg.([]interface{})
+		if !ok || len(a) < 2 {
 			continue
 		}
-		if len(chunk) != 2 {
+		kind, _ := a[0].(string)
+		text, chunks := parseCmdChunks(m.ws.screen, a[1])
+		replaceLast := len(a) >= 3 && isTrue(a[2])
+		if strings.TrimSpace(text) == "" {
 			continue
 		}
-		msg, ok := chunk[0].(string)
-		if !ok {
+		if isMessageOutput(kind, text) {
+			m.history.showOutput(chunks, output)
+			output = true
 			continue
 		}
-		text += msg
+		if replaceLast && len(m.items) > 0 {
+			m.removeItem(m.items[len(m.items)-1])
+		}
+		// The search count is replaced by the next one
+		if kind == "search_count" {
+			for _, item := range m.items {
+				if item.kind == kind {
+					m.removeItem(item)
+					break
+				}
+			}
+		}
+		m.addItem(kind, text, chunks)
 	}
-	if text == "" {
-		return
+	m.move()
+}
+
+// isMessageOutput returns whether the message is the output of a command
+// like :ls, :map or :!cmd, which is shown in the panel instead of a toast
+func isMessageOutput(kind, text string) bool {
+	switch kind {
+	case "list_cmd":
+		return true
+	case "":
+		return strings.Contains(strings.Trim(text, "\n"), "\n")
 	}
-	for text[len(text)-1] == '\n' {
-		text = string(text[:len(text)-1])
-		if text == "" {
-			return
+	return false
+}
+
+// msgClear handles msg_clear, which clears the prompts and the search count,
+// while the other messages stay until they expire
+func (m *Message) msgClear() {
+	items := []*MessageItem{}
+	for _, item := range m.items {
+		if item.hideAt.IsZero() || item.kind == "search_count" {
+			item.widget.DestroyQWidget()
+			continue
 		}
+		items = append(items, item)
 	}
-	var item *MessageItem
-	allActive := true
-	for _, item = range m.items {
-		if !item.active {
-			allActive = false
-			break
-		}
+	m.items = items
+	m.move()
+}
+
+// msgRuler shows the ruler of msg_ruler when the statusline is hidden
+func (m *Message) msgRuler(args []interface{}) {
+	if editor.config.Statusline.Visible {
+		return
 	}
-	if allActive {
-		for i := 0; i < len(m.items)-1; i++ {
-			m.items[i].copy(m.items[i+1])
-		}
+	text, ok := m.rulerArg(args)
+	if !ok {
+		return
 	}
+	m.rulerText = text
+	m.updateRuler()
+}
 
-	item.hideAt = time.Now().Add(time.Duration(m.expires) * time.Second)
-	time.AfterFunc(time.Duration(m.expires+1)*time.Second, func() {
-		m.ws.signal.MessageSignal()
-	})
-	item.setKind(m.kind)
-	item.setText(text)
-	item.show()
-	m.widget.Resize2(m.width+34, 0)
-}
-
-func (i *MessageItem) setText(text string) {
-	i.text = text
-	label := i.label
-	label.SetMinimumHeight(0)
-	// label.SetMaximumHeight(0)
-	label.SetText(text)
-	height := label.HeightForWidth(i.m.width)
-	label.SetMinimumHeight(height)
-	label.SetMaximumHeight(height)
-	i.widget.SetMinimumHeight(height)
-	i.widget.SetMaximumHeight(height)
-}
-
-func (i *MessageItem) copy(item *MessageItem) {
-	i.hideAt = item.hideAt
-	i.setKind(item.kind)
-	i.setText(item.text)
-	i.show()
-}
-
-func (i *MessageItem) hide() {
-	i.expired = false
-	if !i.active {
+// msgShowmode shows the mode message like "-- INSERT --" or "recording @q"
+func (m *Message) msgShowmode(args []interface{}) {
+	text, ok := m.rulerArg(args)
+	if !ok {
 		return
 	}
-	i.active = false
-	i.widget.Hide()
-	i.icon.Hide()
-	i.label.Hide()
+	m.showmode = text
+	m.updateRuler()
 }
 
-func (i *MessageItem) show() {
-	i.expired = false
-	if i.active {
+// msgShowcmd shows the partial command of 'showcmd'
+func (m *Message) msgShowcmd(args []interface{}) {
+	text, ok := m.rulerArg(args)
+	if !ok {
 		return
 	}
-	i.active = true
-	i.label.Show()
-	i.icon.Show()
-	i.widget.Show()
+	m.showcmd = text
+	m.updateRuler()
 }
 
-func (i *MessageItem) setKind(kind string) {
-	if kind == i.kind {
+// rulerArg returns the text of the content of msg_ruler, msg_showmode and
+// msg_showcmd
+func (m *Message) rulerArg(args []interface{}) (string, bool) {
+	arg, ok := args[len(args)-1].([]interface{})
+	if !ok || len(arg) < 1 {
+		return "", false
+	}
+	text, _ := parseCmdChunks(m.ws.screen, arg[0])
+	return strings.TrimSpace(text), true
+}
+
+// updateRuler shows the partial command, the mode message and the ruler at
+// the bottom right of the screen
+func (m *Message) updateRuler() {
+	texts := []string{}
+	for _, text := range []string{m.showcmd, m.showmode, m.rulerText} {
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		m.ruler.Hide()
 		return
 	}
-	i.kind = kind
-	style := "border-bottom: 1px solid #000; border-left: 1px solid #000; border-right: 1px solid #000;"
-	switch i.kind {
-	case "emsg":
-		style += "color: rgba(204, 62, 68, 1);"
-		svgContent := editor.getSvg("fire", newRGBA(204, 62, 68, 1))
-		i.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	m.ruler.SetText(strings.Join(texts, "   "))
+	m.ruler.AdjustSize()
+	m.moveRuler()
+	m.ruler.Raise()
+	m.ruler.Show()
+}
+
+func (m *Message) moveRuler() {
+	m.ruler.Move2(m.ws.screen.widget.Width()-m.ruler.Width(), m.ws.screen.widget.Height()-m.ruler.Height())
+}
+
+// msgHistoryShow handles msg_history_show, whose argument is the list of
+// [kind, content] of :messages
+func (m *Message) msgHistoryShow(args []interface{}) {
+	arg, ok := args[len(args)-1].([]interface{})
+	if !ok || len(arg) < 1 {
+		return
+	}
+	entries, _ := arg[0].([]interface{})
+	m.history.show(entries)
+}
+
+func (m *Message) addItem(kind, text string, chunks []*CmdChunk) {
+	if len(m.items) >= messageMaxItems {
+		m.removeItem(m.items[0])
+	}
+
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetObjectName("messageItem")
+	widget.SetAttribute(core.Qt__WA_StyledBackground, true)
+	layout := widgets.NewQGridLayout2()
+	layout.SetContentsMargins(10, 8, 10, 8)
+	layout.SetHorizontalSpacing(8)
+	layout.SetVerticalSpacing(6)
+	widget.SetLayout(layout)
+
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedSize2(14, 14)
+	label := widgets.NewQLabel(nil, 0)
+	label.SetWordWrap(true)
+	label.SetTextFormat(core.Qt__RichText)
+	label.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	layout.AddWidget(icon, 0, 0, core.Qt__AlignTop)
+	layout.AddWidget(label, 0, 1, 0)
+
+	item := &MessageItem{
+		m:      m,
+		kind:   kind,
+		text:   text,
+		icon:   icon,
+		label:  label,
+		widget: widget,
+	}
+	item.setKind(kind)
+	label.SetText(fmt.Sprintf("<span style=\"white-space: pre-wrap;\">%s</span>", formatCmdChunks(trimCmdChunks(chunks))))
+
+	if choices := messageChoices(kind, text); len(choices) > 0 {
+		item.buttons = newMessageButtons(choices)
+		layout.AddWidget(item.buttons, 1, 1, 0)
+	} else {
+		expires := m.expires
+		if kind == "search_count" {
+			expires = 3
+		}
+		item.hideAt = time.Now().Add(time.Duration(expires) * time.Second)
+		time.AfterFunc(time.Duration(expires)*time.Second+100*time.Millisecond, func() {
+			m.ws.signal.MessageSignal()
+		})
+	}
+
+	m.layout.AddWidget(widget, 0, 0)
+	m.items = append(m.items, item)
+	item.resize()
+}
+
+func (m *Message) removeItem(item *MessageItem) {
+	items := []*MessageItem{}
+	for _, i := range m.items {
+		if i != item {
+			items = append(items, i)
+		}
+	}
+	m.items = items
+	item.widget.DestroyQWidget()
+}
+
+// resize fits the message to the width, within a third of the screen height
+func (i *MessageItem) resize() {
+	width := i.m.width - 20 - 14 - 8
+	i.label.SetFixedWidth(width)
+	height := i.label.HeightForWidth(width)
+	if maxHeight := i.m.ws.screen.widget.Height() / 3; height > maxHeight {
+		height = maxHeight
+	}
+	i.label.SetFixedHeight(height)
+}
+
+func (i *MessageItem) setKind(kind string) {
+	var icon string
+	var color *RGBA
+	switch kind {
+	case "emsg", "echoerr", "lua_error", "rpc_error":
+		icon, color = "fire", newRGBA(204, 62, 68, 1)
+	case "wmsg":
+		icon, color = "warn", newRGBA(203, 203, 65, 1)
+	case "confirm", "confirm_sub", "return_prompt":
+		icon, color = "exclamation", newRGBA(27, 161, 226, 1)
+	case "search_count":
+		icon, color = "select", newRGBA(141, 193, 73, 1)
 	default:
-		style += "color: rgba(81, 154, 186, 1);"
-		svgContent := editor.getSvg("comment", nil)
-		i.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+		icon, color = "comment", newRGBA(27, 161, 226, 1)
 	}
-	i.label.SetStyleSheet(style)
+	svgContent := editor.getSvg(icon, color)
+	i.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+}
+
+// MessageChoice is a choice of the prompts, answered with the key
+type MessageChoice struct {
+	text string
+	key  string
+}
+
+// messageChoices returns the choices of the prompts of the kind
+func messageChoices(kind, text string) []*MessageChoice {
+	switch kind {
+	case "return_prompt":
+		return []*MessageChoice{{"Continue", "<CR>"}}
+	case "confirm":
+		lines := strings.Split(strings.TrimSpace(text), "\n")
+		choices := []*MessageChoice{}
+		for _, choice := range strings.Split(strings.TrimRight(lines[len(lines)-1], ": "), ", ") {
+			match := confirmChoiceRe.FindStringSubmatchIndex(choice)
+			if match == nil {
+				continue
+			}
+			key := choice[match[2]:match[3]]
+			choices = append(choices, &MessageChoice{
+				text: choice[:match[0]] + key + choice[match[1]:],
+				key:  key,
+			})
+		}
+		return choices
+	case "confirm_sub":
+		match := confirmSubChoicesRe.FindStringSubmatch(strings.TrimSpace(text))
+		if match == nil {
+			return nil
+		}
+		choices := []*MessageChoice{}
+		for _, key := range strings.Split(match[1], "/") {
+			// Skip the scroll keys like ^E
+			if len(key) != 1 {
+				continue
+			}
+			choices = append(choices, &MessageChoice{text: key, key: key})
+		}
+		return choices
+	}
+	return nil
+}
+
+func newMessageButtons(choices []*MessageChoice) *widgets.QWidget {
+	widget := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQHBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(6)
+	widget.SetLayout(layout)
+	for _, choice := range choices {
+		button := widgets.NewQLabel(nil, 0)
+		button.SetText(html.EscapeString(choice.text))
+		button.SetContentsMargins(10, 3, 10, 3)
+		button.SetAlignment(core.Qt__AlignCenter)
+		button.SetStyleSheet("QLabel { color: #ffffff; background: #0e639c; }")
+		key := choice.key
+		button.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+			go editor.workspaces[editor.active].nvim.Input(key)
+		})
+		button.ConnectEnterEvent(func(event *core.QEvent) {
+			button.SetStyleSheet("QLabel { color: #ffffff; background: #1177bb; }")
+		})
+		button.ConnectLeaveEvent(func(event *core.QEvent) {
+			button.SetStyleSheet("QLabel { color: #ffffff; background: #0e639c; }")
+		})
+		layout.AddWidget(button, 0, 0)
+	}
+	layout.AddStretch(1)
+	return widget
+}
+
+// trimCmdChunks trims the newlines around the chunks, like the one at the
+// beginning of the confirm prompts
+func trimCmdChunks(chunks []*CmdChunk) []*CmdChunk {
+	trimmed := []*CmdChunk{}
+	for _, chunk := range chunks {
+		trimmed = append(trimmed, &CmdChunk{attrs: chunk.attrs, text: chunk.text})
+	}
+	for len(trimmed) > 0 {
+		trimmed[0].text = strings.TrimLeft(trimmed[0].text, "\n")
+		if trimmed[0].text != "" {
+			break
+		}
+		trimmed = trimmed[1:]
+	}
+	for len(trimmed) > 0 {
+		last := trimmed[len(trimmed)-1]
+		last.text = strings.TrimRight(last.text, "\n")
+		if last.text != "" {
+			break
+		}
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	return trimmed
+}
+
+// show renders the entries of msg_history_show
+func (h *MessageHistory) show(entries []interface{}) {
+	if len(entries) == 0 {
+		h.hide()
+		return
+	}
+	content := ""
+	for _, e := range entries {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) < 2 {
+			continue
+		}
+		kind, _ := entry[0].(string)
+		_, chunks := parseCmdChunks(h.m.ws.screen, entry[1])
+		style := ""
+		switch kind {
+		case "emsg", "echoerr", "lua_error", "rpc_error":
+			style = "color: #cc3e44;"
+		case "wmsg":
+			style = "color: #cbcb41;"
+		}
+		content += fmt.Sprintf("<div style=\"white-space: pre-wrap; %s\">%s</div>", style, formatCmdChunks(trimCmdChunks(chunks)))
+	}
+	h.render(fmt.Sprintf("Messages (%d)", len(entries)), content)
+}
+
+// showOutput shows the output of the commands like :ls, which isn't kept in
+// the history of :messages, until the panel is closed. The output is
+// appended to the one of the same msg_show if appendOutput is true.
+func (h *MessageHistory) showOutput(chunks []*CmdChunk, appendOutput bool) {
+	if !appendOutput {
+		h.output = nil
+	}
+	h.output = append(h.output, fmt.Sprintf("<div style=\"white-space: pre-wrap;\">%s</div>", formatCmdChunks(trimCmdChunks(chunks))))
+	h.render("Output", strings.Join(h.output, ""))
+}
+
+func (h *MessageHistory) render(title, content string) {
+	h.title.SetText(title)
+	h.browser.SetHtml(content)
+	h.resize()
+	h.widget.Raise()
+	h.widget.Show()
+	h.browser.VerticalScrollBar().SetValue(h.browser.VerticalScrollBar().Maximum())
+}
+
+// resize fits the panel to the messages within 40% of the screen height at
+// the bottom of the screen
+func (h *MessageHistory) resize() {
+	width := h.m.ws.screen.widget.Width()
+	screenHeight := h.m.ws.screen.widget.Height()
+	document := h.browser.Document()
+	document.SetTextWidth(float64(width))
+	height := int(math.Ceil(document.Size().Height())) + h.title.SizeHint().Height() + 8
+	if height > screenHeight*2/5 {
+		height = screenHeight * 2 / 5
+	}
+	h.widget.SetFixedSize2(width, height)
+	h.widget.Move2(0, screenHeight-height)
+}
+
+func (h *MessageHistory) hide() {
+	h.widget.Hide()
 }
@@ -2,12 +2,8 @@ package editor
 
 import (
 	"fmt"
-	"io/ioutil"
-	"math"
-	"os"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/neovim/go-client/nvim"
@@ -17,58 +13,130 @@ import (
 	"github.com/therecipe/qt/widgets"
 )
 
+const (
+	// minimapCharWidth and minimapLineHeight are the size in pixels of a
+	// character of the buffer in the minimap
+	minimapCharWidth  = 1
+	minimapLineHeight = 2
+	// minimapHighlightDelay debounces fetching the highlights while typing
+	minimapHighlightDelay = 150 * time.Millisecond
+)
+
+// minimapHighlightLua returns the foreground colors of the lines of the
+// buffer as {row, start column, end column, color} from treesitter, or from
+// the syntax highlighting if treesitter is not active for the buffer. The
+// end column is -1 for the end of the line. The syntax items are looked up
+// once per word or run of symbols, since synID() is slow.
+const minimapHighlightLua = `(function(bufnr, first, last, maxcols)
+  local spans = {}
+  local colors = {}
+  local color = function(id)
+    id = vim.fn.synIDtrans(id)
+    if colors[id] == nil then
+      local ok, hl = pcall(vim.api.nvim_get_hl_by_id, id, true)
+      colors[id] = ok and hl.foreground or -1
+    end
+    return colors[id]
+  end
+
+  local highlighter = vim.treesitter and vim.treesitter.highlighter
+  if highlighter and highlighter.active[bufnr] then
+    local ok, parser = pcall(vim.treesitter.get_parser, bufnr)
+    local get = vim.treesitter.query.get or vim.treesitter.query.get_query
+    local query = ok and get(parser:lang(), 'highlights')
+    if query then
+      local tree = parser:parse()[1]
+      for id, node in query:iter_captures(tree:root(), bufnr, first, last) do
+        local fg = color(vim.api.nvim_get_hl_id_by_name('@' .. query.captures[id]))
+        if fg ~= -1 then
+          local sr, sc, er, ec = node:range()
+          for row = math.max(sr, first), math.min(er, last - 1) do
+            table.insert(spans, {row, row == sr and sc or 0, row == er and ec or -1, fg})
+          end
+        end
+      end
+      return spans
+    end
+  end
+
+  vim.api.nvim_buf_call(bufnr, function()
+    if vim.bo.syntax == '' then
+      return
+    end
+    local lines = vim.api.nvim_buf_get_lines(bufnr, first, last, false)
+    for i, line in ipairs(lines) do
+      local row = first + i - 1
+      line = line:sub(1, maxcols)
+      local s = line:find('%S')
+      while s do
+        local _, e = line:find('^[%w_]+', s)
+        if not e then
+          _, e = line:find('^[^%w_%s]+', s)
+        end
+        local fg = color(vim.fn.synID(row + 1, s, 1))
+        if fg ~= -1 then
+          table.insert(spans, {row, s - 1, e, fg})
+        end
+        s = line:find('%S', e + 1)
+      end
+    end
+  end)
+  return spans
+end)(_A[1], _A[2], _A[3], _A[4])`
+
 type miniMapSignal struct {
 	core.QObject
-	_ func() `signal:"stopSignal"`
-	_ func() `signal:"redrawSignal"`
-	_ func() `signal:"guiSignal"`
+	_ func() `signal:"linesSignal"`
+	_ func() `signal:"highlightSignal"`
+	_ func() `signal:"attachSignal"`
+	_ func() `signal:"detachSignal"`
+	_ func() `signal:"fetchHighlightSignal"`
+}
+
+// minimapBuffer is the current buffer of the workspace to attach to
+type minimapBuffer struct {
+	buf     nvim.Buffer
+	tabstop int
 }
 
-// MiniMap is
+// MiniMap is the downscaled map of the current buffer of the workspace
 type MiniMap struct {
 	ws        *Workspace
 	widget    *widgets.QWidget
 	curRegion *widgets.QWidget
-	pos       int
 	width     int
 	height    int
-	currBuf   string
-
-	visible bool
-
-	font             *Font
-	content          [][]*Char
-	scrollRegion     []int
-	scrollDust       [2]int
-	scrollDustDeltaY int
-	queueRedrawArea  [4]int
-	paintMutex       sync.Mutex
-	redrawMutex      sync.Mutex
-	bg               *RGBA
-	curtab           nvim.Tabpage
-	cursor           [2]int
-	cmdheight        int
-	curWins          map[nvim.Window]*Window
-	highlight        Highlight
-	isSetColorscheme bool
-
-	sync          sync.Mutex
-	signal        *miniMapSignal
-	redrawUpdates chan [][]interface{}
-	guiUpdates    chan []interface{}
-	stopOnce      sync.Once
-	stop          chan struct{}
-
-	nvim       *nvim.Nvim
-	uiAttached bool
-	rows       int
-	cols       int
+	rows      int
+	cols      int
+
+	visible  bool
+	buf      nvim.Buffer
+	attached bool
+	lines    []string
+	spans    map[int][]*MiniMapSpan
+	tabstop  int
+	topLine  int
+
+	signal           *miniMapSignal
+	linesUpdates     chan []interface{}
+	highlightUpdates chan map[int][]*MiniMapSpan
+	attachUpdates    chan *minimapBuffer
+	detachUpdates    chan nvim.Buffer
+	highlightTimer   *time.Timer
+	scrollDust       int
 
 	foreground *RGBA
 	background *RGBA
 	special    *RGBA
 }
 
+// MiniMapSpan is a range of the columns of a line drawn in the color
+type MiniMapSpan struct {
+	start int
+	end   int // -1 for the end of the line
+	color *RGBA
+}
+
 func newMiniMap() *MiniMap {
 	widget := widgets.NewQWidget(nil, 0)
 	widget.SetContentsMargins(0, 0, 0, 0)
@@ -83,24 +151,35 @@ func newMiniMap() *MiniMap {
 	curRegion.SetFixedHeight(1)
 
 	m := &MiniMap{
-		widget:        widget,
-		curRegion:     curRegion,
-		scrollRegion:  []int{0, 0, 0, 0},
-		stop:          make(chan struct{}),
-		signal:        NewMiniMapSignal(nil),
-		redrawUpdates: make(chan [][]interface{}, 1000),
-		guiUpdates:    make(chan []interface{}, 1000),
-	}
-	m.signal.ConnectRedrawSignal(func() {
-		updates := <-m.redrawUpdates
-		m.handleRedraw(updates)
+		widget:           widget,
+		curRegion:        curRegion,
+		visible:          editor.config.MiniMap.Visible,
+		spans:            make(map[int][]*MiniMapSpan),
+		tabstop:          8,
+		topLine:          1,
+		signal:           NewMiniMapSignal(nil),
+		linesUpdates:     make(chan []interface{}, 1000),
+		highlightUpdates: make(chan map[int][]*MiniMapSpan, 10),
+		attachUpdates:    make(chan *minimapBuffer, 10),
+		detachUpdates:    make(chan nvim.Buffer, 10),
+	}
+	m.signal.ConnectLinesSignal(func() {
+		args := <-m.linesUpdates
+		m.bufLines(args)
 	})
-	m.signal.ConnectGuiSignal(func() {
-		updates := <-m.guiUpdates
-		m.handleRPCGui(updates)
+	m.signal.ConnectHighlightSignal(func() {
+		m.spans = <-m.highlightUpdates
+		m.widget.Update()
 	})
-	m.signal.ConnectStopSignal(func() {
+	m.signal.ConnectAttachSignal(func() {
+		m.attach(<-m.attachUpdates)
+	})
+	m.signal.ConnectDetachSignal(func() {
+		if buf := <-m.detachUpdates; buf == m.buf {
+			m.attached = false
+		}
 	})
+	m.signal.ConnectFetchHighlightSignal(m.fetchHighlights)
 	m.widget.ConnectPaintEvent(m.paint)
 	m.widget.ConnectResizeEvent(func(event *gui.QResizeEvent) {
 		m.updateSize()
@@ -109,109 +188,26 @@ func newMiniMap() *MiniMap {
 	m.widget.ConnectWheelEvent(m.wheelEvent)
 	m.widget.Hide()
 
-	switch runtime.GOOS {
-	case "windows":
-		m.font = initFontNew("Consolas", 1, 0)
-	case "darwin":
-		m.font = initFontNew("Courier New", 2, 0)
-	default:
-		m.font = initFontNew("Monospace", 1, 0)
-	}
-
 	return m
 }
 
-func (m *MiniMap) startMinimapProc() {
-	neovim, err := nvim.NewChildProcess(nvim.ChildProcessArgs("-u", "NONE", "-n", "--embed", "--headless"))
-	if err != nil {
-		fmt.Println(err)
-	}
-	m.nvim = neovim
-	m.nvim.RegisterHandler("Gui", func(updates ...interface{}) {
-		m.guiUpdates <- updates
-		m.signal.GuiSignal()
-	})
-	m.nvim.RegisterHandler("redraw", func(updates ...[]interface{}) {
-		m.redrawUpdates <- updates
-		m.signal.RedrawSignal()
-	})
-	m.width = m.widget.Width()
-	m.height = m.widget.Height()
-
-	m.updateSize()
-
-	go func() {
-		err := m.nvim.Serve()
-		if err != nil {
-			fmt.Println(err)
-		}
-		m.stopOnce.Do(func() {
-			close(m.stop)
-		})
-		m.signal.StopSignal()
-	}()
-
-	err = m.nvim.AttachUI(m.cols, m.rows, m.attachUIOption())
-	if err != nil {
-		fmt.Println(err)
-	}
-	m.uiAttached = true
-	m.visible = editor.config.MiniMap.Visible
-
-	m.nvim.Subscribe("Gui")
-	m.nvim.Command(":set laststatus=0 | set noruler")
-	m.nvim.Command(":syntax on")
-	m.nvim.Command(":set nowrap")
-}
-
-func (m *MiniMap) exit() {
-	go m.nvim.Command(":q!")
+// linesEvent receives nvim_buf_lines_event of the attached buffer from the
+// nvim of the workspace
+func (m *MiniMap) linesEvent(args ...interface{}) {
+	m.linesUpdates <- args
+	m.signal.LinesSignal()
 }
 
-func (m *MiniMap) attachUIOption() map[string]interface{} {
-	o := make(map[string]interface{})
-	o["rgb"] = true
-
-	apiInfo, err := m.nvim.APIInfo()
-	if err == nil {
-		for _, item := range apiInfo {
-			i, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			for k, v := range i {
-				if k != "ui_events" {
-					continue
-				}
-				events, ok := v.([]interface{})
-				if !ok {
-					continue
-				}
-				for _, event := range events {
-					function, ok := event.(map[string]interface{})
-					if !ok {
-						continue
-					}
-					_, ok = function["name"]
-					if !ok {
-						continue
-					}
-					//if name == "wildmenu_show" {
-					//	o["ext_wildmenu"] = true
-					//} else if name == "cmdline_show" {
-					//	o["ext_cmdline"] = true
-					//} else if name == "msg_chunk" {
-					//	o["ext_messages"] = true
-					//} else if name == "popupmenu_show" {
-					//	o["ext_popupmenu"] = true
-					//} else if name == "tabline_update" {
-					//	o["ext_tabline"] = m.drawTabline
-					//}
-				}
-			}
-		}
+// detachEvent receives nvim_buf_detach_event, sent when the buffer is
+// unloaded
+func (m *MiniMap) detachEvent(args ...interface{}) {
+	if len(args) < 1 {
+		return
+	}
+	if buf, ok := args[0].(nvim.Buffer); ok {
+		m.detachUpdates <- buf
+		m.signal.DetachSignal()
 	}
-	return o
 }
 
 func (m *MiniMap) toggle() {
@@ -223,866 +219,354 @@ func (m *MiniMap) toggle() {
 	m.bufUpdate()
 }
 
-func (m *MiniMap) paint(vqp *gui.QPaintEvent) {
-	m.paintMutex.Lock()
-	defer m.paintMutex.Unlock()
-
-	rect := vqp.M_rect()
-	font := m.font
-	top := rect.Y()
-	left := rect.X()
-	width := rect.Width()
-	height := rect.Height()
-	right := left + width
-	bottom := top + height
-	row := int(float64(top) / float64(font.lineHeight))
-	col := int(float64(left) / font.truewidth)
-	rows := int(math.Ceil(float64(bottom)/float64(font.lineHeight))) - row
-	cols := int(math.Ceil(float64(right)/font.truewidth)) - col
-
-	p := gui.NewQPainter2(m.widget)
-	if m.background != nil {
-		p.FillRect5(
-			left,
-			top,
-			width,
-			height,
-			m.background.QColor(),
-		)
-	}
-
-	p.SetFont(font.fontNew)
-
-	for y := row; y < row+rows; y++ {
-		if y >= m.rows {
-			continue
-		}
-		m.fillHightlight(p, y, col, cols, [2]int{0, 0})
-		m.drawText(p, y, col, cols, [2]int{0, 0})
-	}
-
-	m.drawWindows(p, row, col, rows, cols)
-	p.DestroyQPainter()
-}
-
-func (m *MiniMap) drawWindows(p *gui.QPainter, row, col, rows, cols int) {
-	done := make(chan struct{}, 1000)
-	go func() {
-		m.getWindows()
-		close(done)
-	}()
-	select {
-	case <-done:
-	case <-time.After(1 * time.Millisecond):
-	}
-}
-
-func (m *MiniMap) getWindows() {
-	wins := map[nvim.Window]*Window{}
-	neovim := m.nvim
-	curtab, _ := neovim.CurrentTabpage()
-	m.curtab = curtab
-	nwins, _ := neovim.TabpageWindows(curtab)
-	b := neovim.NewBatch()
-	for _, nwin := range nwins {
-		win := &Window{
-			win: nwin,
-		}
-		b.WindowWidth(nwin, &win.width)
-		b.WindowHeight(nwin, &win.height)
-		b.WindowPosition(nwin, &win.pos)
-		b.WindowTabpage(nwin, &win.tab)
-		wins[nwin] = win
-	}
-	b.Option("cmdheight", &m.cmdheight)
-	err := b.Execute()
-	if err != nil {
-		return
-	}
-	m.curWins = wins
-	for _, win := range m.curWins {
-		buf, _ := neovim.WindowBuffer(win.win)
-		win.bufName, _ = neovim.BufferName(buf)
-
-		if win.height+win.pos[0] < m.rows-m.cmdheight {
-			win.statusline = true
-		} else {
-			win.statusline = false
-		}
-		neovim.WindowOption(win.win, "winhl", &win.hl)
-		if win.hl != "" {
-			parts := strings.Split(win.hl, ",")
-			for _, part := range parts {
-				if strings.HasPrefix(part, "Normal:") {
-					hl := part[7:]
-					result := ""
-					neovim.Eval(fmt.Sprintf("synIDattr(hlID('%s'), 'bg')", hl), &result)
-					if result != "" {
-						var r, g, b int
-						format := "#%02x%02x%02x"
-						n, err := fmt.Sscanf(result, format, &r, &g, &b)
-						if err != nil {
-							continue
-						}
-						if n != 3 {
-							continue
-						}
-						win.bg = newRGBA(r, g, b, 1)
-					}
-				}
-			}
-		}
-	}
-}
-
-func (m *MiniMap) updateRows() bool {
-	var ret bool
-	m.height = m.widget.Height()
-	rows := m.height / m.font.lineHeight
-
-	if rows != m.rows {
-		ret = true
-	}
-	m.rows = rows
-	return ret
-}
-
-func (m *MiniMap) updateCols() bool {
-	var ret bool
-	m.width = m.widget.Width()
-	cols := int(float64(m.width) / m.font.truewidth)
-
-	if cols != m.cols {
-		ret = true
-	}
-	m.cols = cols
-	return ret
-}
-
-func (m *MiniMap) updateSize() {
-	isColDiff := m.updateCols()
-	isRowDiff := m.updateRows()
-	isTryResize := isColDiff || isRowDiff
-	if m.uiAttached && isTryResize {
-		m.nvim.TryResizeUI(m.cols, m.rows)
-	}
-}
-
 func (m *MiniMap) bufUpdate() {
 	if strings.Contains(m.ws.filepath, "[denite]") {
 		return
 	}
 	if !m.visible {
 		m.widget.Hide()
+		m.detach()
 		return
 	}
-	if m.ws.nvim == nil || m.nvim == nil {
+	if m.ws.nvim == nil {
 		return
 	}
-	if !m.isSetColorscheme {
-		m.setColorscheme()
-	}
 	m.widget.Show()
+	go m.currentBuffer()
+}
 
-	if m.currBuf == m.ws.filepath {
+// currentBuffer gets the current buffer to attach to
+func (m *MiniMap) currentBuffer() {
+	buf, err := m.ws.nvim.CurrentBuffer()
+	if err != nil {
 		return
 	}
-	m.currBuf = m.ws.filepath
-
-	if m.currBuf == "" {
-		go m.nvim.Command(":e! [No Name]")
-	} else {
-		go m.nvim.Command(":e! " + m.currBuf)
+	current := &minimapBuffer{
+		buf:     buf,
+		tabstop: 8,
 	}
-	m.mapScroll()
+	m.ws.nvim.BufferOption(buf, "tabstop", &current.tabstop)
+	m.attachUpdates <- current
+	m.signal.AttachSignal()
 }
 
-func (m *MiniMap) setColorscheme() {
-	basepath, _ := m.ws.nvim.CommandOutput("echo g:dein#_base_path")
-	packpath := basepath + `/repos/github.com/`
-	colo, _ := m.ws.nvim.CommandOutput("colo")
-	lsDirs, _ := ioutil.ReadDir(packpath)
-
-	// Set coloDir and
-	//  some exceptional color scheme names and plugin directory names
-	coloDir := ""
-	switch colo {
-	case "one":
-		coloDir = "vim-one"
-	case "primery":
-		coloDir = "vim-colorscheme-primary"
-	case "github":
-		coloDir = "vim-github-colorscheme"
-	case "OceanicNext":
-		coloDir = "oceanic-next"
-	default:
-		coloDir = colo
-	}
-
-	// Search colorscheme repo in dein.vim plugin directory
-	//  and set the repository to runtimepath
-	runtimeDir := ""
-	for _, d := range lsDirs {
-		dirname := d.Name()
-		finfo, err := os.Stat(packpath + dirname)
-		if err != nil {
-			continue
-		}
-		if finfo.IsDir() {
-			packDirs, _ := ioutil.ReadDir(packpath + dirname)
-			for _, p := range packDirs {
-				plugname := p.Name()
-				if strings.Contains(plugname, coloDir) {
-					runtimeDir = dirname + "/" + plugname
-					break
-				}
-			}
-			if runtimeDir != "" {
-				break
-			}
-		}
+// attach receives the lines of the buffer with nvim_buf_attach
+func (m *MiniMap) attach(current *minimapBuffer) {
+	if !m.visible {
+		return
 	}
-	m.nvim.Command("set runtimepath^=" + packpath + runtimeDir)
-	m.nvim.Command("set runtimepath^=" + packpath + runtimeDir + "/colors")
-	m.nvim.Command(":runtime! " + colo + ".vim")
-	m.nvim.Command(":colorscheme " + colo)
-	m.isSetColorscheme = true
-}
-
-func (m *MiniMap) mapScroll() {
-	absScreenTop := m.ws.curLine - m.ws.screen.cursor[0]
-	var absMapTop int
-	m.nvim.Eval("line('w0')", &absMapTop)
-
-	var regionHeight int
-	var winpos [2]int
-	for _, win := range m.ws.screen.curWins {
-		if win.pos[0] <= m.ws.screen.cursor[0] && m.ws.screen.cursor[0] <= win.pos[0]+win.height {
-			regionHeight = win.height
-			winpos = win.pos
-			break
-		}
+	if m.attached && current.buf == m.buf {
+		// The highlights may change on writing the buffer
+		m.tabstop = current.tabstop
+		m.queueHighlight()
+		return
 	}
-	m.curRegion.SetFixedHeight(int(float64(regionHeight) * float64(m.font.lineHeight)))
-	pos := int(float64(m.font.lineHeight) * float64(absScreenTop-absMapTop+winpos[0]))
-	m.curRegion.Move2(0, pos)
-}
-
-func (m *MiniMap) handleRedraw(updates [][]interface{}) {
-	for _, update := range updates {
-		event := update[0].(string)
-		args := update[1:]
-		switch event {
-		// case "update_fg":
-		// case "update_bg":
-		//	// go m.nvim.Command(`call rpcnotify(0, "Gui", "minimap_cursormoved",  getpos("."))`)
-		// case "update_sp":
-		case "cursor_goto":
-			m.cursorGoto(args)
-		case "put":
-			m.put(args)
-		case "eol_clear":
-			m.eolClear(args)
-		case "clear":
-			m.clear(args)
-		case "resize":
-			m.resize(args)
-		case "highlight_set":
-			m.highlightSet(args)
-		case "set_scroll_region":
-			m.setScrollRegion(args)
-		case "scroll":
-			m.scroll(args)
-			m.mapScroll()
-		// case "mode_change":
-		// case "popupmenu_show":
-		// case "popupmenu_hide":
-		// case "popupmenu_select":
-		// case "tabline_update":
-		// case "cmdline_show":
-		// case "cmdline_pos":
-		// case "cmdline_char":
-		// case "cmdline_hide":
-		// case "cmdline_function_show":
-		// case "cmdline_function_hide":
-		// case "wildmenu_show":
-		// case "wildmenu_select":
-		// case "wildmenu_hide":
-		// case "msg_start_kind":
-		// case "msg_chunk":
-		// case "msg_end":
-		// case "msg_showcmd":
-		// case "messages":
-		// case "busy_start":
-		// case "busy_stop":
-		default:
+	m.detach()
+	buf := current.buf
+	m.buf = buf
+	m.tabstop = current.tabstop
+	m.attached = true
+	go func() {
+		attached, err := m.ws.nvim.AttachBuffer(buf, true, make(map[string]interface{}))
+		if err != nil || !attached {
+			m.detachUpdates <- buf
+			m.signal.DetachSignal()
 		}
-	}
-	m.update()
+	}()
 }
 
-func (m *MiniMap) handleRPCGui(updates []interface{}) {
-	event := updates[0].(string)
-	switch event {
-	// case "minimap_cursormoved":
-	// 	pos := updates[1].([]interface{})
-	// 	ln := reflectToInt(pos[1])
-	// 	m.curLine = ln
-	default:
+func (m *MiniMap) detach() {
+	if !m.attached {
+		return
 	}
+	m.attached = false
+	go m.ws.nvim.DetachBuffer(m.buf)
 }
 
-func (m *MiniMap) put(args []interface{}) {
-	numChars := 0
-	x := m.cursor[1]
-	y := m.cursor[0]
-	row := m.cursor[0]
-	col := m.cursor[1]
-	if row >= m.rows {
+// bufLines applies nvim_buf_lines_event, of which the arguments are the
+// buffer, changedtick, firstline, lastline, linedata and more
+func (m *MiniMap) bufLines(args []interface{}) {
+	if len(args) < 5 {
 		return
 	}
-	line := m.content[row]
-	oldFirstNormal := true
-	if x >= len(line) {
-		x = len(line) - 1
-	}
-	char := line[x] // sometimes crash at this line
-	if char != nil && !char.normalWidth {
-		oldFirstNormal = false
+	buf, ok := args[0].(nvim.Buffer)
+	if !ok || buf != m.buf {
+		return
 	}
-	var lastChar *Char
-	oldNormalWidth := true
-	for _, arg := range args {
-		chars := arg.([]interface{})
-		for _, c := range chars {
-			if col >= len(line) {
-				continue
-			}
-			char := line[col]
-			if char != nil && !char.normalWidth {
-				oldNormalWidth = false
-			} else {
-				oldNormalWidth = true
-			}
-			if char == nil {
-				char = &Char{}
-				line[col] = char
-			}
-			char.char = c.(string)
-			char.normalWidth = m.isNormalWidth(char.char)
-			lastChar = char
-			char.highlight = m.highlight
-			col++
-			numChars++
-		}
+	first := reflectToInt(args[2])
+	last := reflectToInt(args[3])
+	data, _ := args[4].([]interface{})
+	lines := make([]string, len(data))
+	for i, line := range data {
+		lines[i], _ = line.(string)
 	}
-	if lastChar != nil && !lastChar.normalWidth {
-		numChars++
+	if last < 0 {
+		// The whole buffer on attaching
+		first = 0
+		last = len(m.lines)
 	}
-	if !oldNormalWidth {
-		numChars++
+	if last > len(m.lines) {
+		last = len(m.lines)
 	}
-	m.cursor[1] = col
-	if x > 0 {
-		char := line[x-1]
-		if char != nil && char.char != "" && !char.normalWidth {
-			x--
-			numChars++
-		} else {
-			if !oldFirstNormal {
-				x--
-				numChars++
-			}
-		}
+	if first > last {
+		first = last
 	}
-	m.queueRedraw(x, y, numChars, 1)
-}
+	m.lines = append(append(append([]string{}, m.lines[:first]...), lines...), m.lines[last:]...)
 
-func (m *MiniMap) highlightSet(args []interface{}) {
-	for _, arg := range args {
-		hl := arg.([]interface{})[0].(map[string]interface{})
-		highlight := Highlight{}
-
-		bold := hl["bold"]
-		if bold != nil {
-			highlight.bold = true
-		} else {
-			highlight.bold = false
-		}
-
-		italic := hl["italic"]
-		if italic != nil {
-			highlight.italic = true
-		} else {
-			highlight.italic = false
-		}
-
-		_, ok := hl["reverse"]
-		if ok {
-			highlight.foreground = m.highlight.background
-			highlight.background = m.highlight.foreground
-			m.highlight = highlight
-			continue
-		}
-
-		fg, ok := hl["foreground"]
-		if ok {
-			rgba := calcColor(reflectToInt(fg))
-			highlight.foreground = rgba
-		} else {
-			highlight.foreground = m.foreground
-		}
-
-		bg, ok := hl["background"]
-		if ok {
-			rgba := calcColor(reflectToInt(bg))
-			highlight.background = rgba
-		} else {
-			highlight.background = m.background
-		}
-		m.highlight = highlight
-	}
+	m.mapScroll()
+	m.queueHighlight()
+	m.widget.Update()
 }
 
-func (m *MiniMap) setScrollRegion(args []interface{}) {
-	arg := args[0].([]interface{})
-	top := reflectToInt(arg[0])
-	bot := reflectToInt(arg[1])
-	left := reflectToInt(arg[2])
-	right := reflectToInt(arg[3])
-	m.scrollRegion[0] = top
-	m.scrollRegion[1] = bot
-	m.scrollRegion[2] = left
-	m.scrollRegion[3] = right
+// queueHighlight fetches the highlights of the lines in the minimap after
+// the edits settle
+func (m *MiniMap) queueHighlight() {
+	if m.highlightTimer != nil {
+		m.highlightTimer.Stop()
+	}
+	m.highlightTimer = time.AfterFunc(minimapHighlightDelay, m.signal.FetchHighlightSignal)
 }
 
-func (m *MiniMap) scroll(args []interface{}) {
-	var count int
-	var ucount uint
-	switch args[0].([]interface{})[0].(type) {
-	case int64:
-		count = int(args[0].([]interface{})[0].(int64))
-	case uint64:
-		ucount = uint(args[0].([]interface{})[0].(uint64))
-	}
-	if ucount > 0 {
-		count = int(ucount)
+// fetchHighlights fetches the highlights of the lines in the minimap
+func (m *MiniMap) fetchHighlights() {
+	if !m.attached {
+		return
 	}
+	first := m.topLine - 1
+	go m.highlights(m.buf, first, first+m.rows, m.cols)
+}
 
-	top := m.scrollRegion[0]
-	bot := m.scrollRegion[1]
-	left := m.scrollRegion[2]
-	right := m.scrollRegion[3]
-
-	if top == 0 && bot == 0 && left == 0 && right == 0 {
-		top = 0
-		bot = m.rows - 1
-		left = 0
-		right = m.cols - 1
+// highlights sends the highlights of the lines of the buffer to the minimap
+func (m *MiniMap) highlights(buf nvim.Buffer, first, last, cols int) {
+	var result interface{}
+	err := m.ws.nvim.Call("luaeval", &result, minimapHighlightLua, []interface{}{int(buf), first, last, cols})
+	if err != nil {
+		return
 	}
-
-	m.queueRedraw(left, top, (right - left + 1), (bot - top + 1))
-
-	if count > 0 {
-		for row := top; row <= bot-count; row++ {
-			for col := left; col <= right; col++ {
-				if len(m.content) <= row+count {
-					continue
-				}
-				for _, line := range m.content {
-					if len(line) <= col {
-						return
-					}
-				}
-				m.content[row][col] = m.content[row+count][col]
-			}
-		}
-		for row := bot - count + 1; row <= bot; row++ {
-			for col := left; col <= right; col++ {
-				m.content[row][col] = nil
-			}
-		}
-		m.queueRedraw(left, (bot - count + 1), (right - left), count)
-		if top > 0 {
-			m.queueRedraw(left, (top - count), (right - left), count)
-		}
-	} else {
-		for row := bot; row >= top-count; row-- {
-			for col := left; col <= right; col++ {
-				m.content[row][col] = m.content[row+count][col]
-			}
-		}
-		for row := top; row < top-count; row++ {
-			for col := left; col <= right; col++ {
-				m.content[row][col] = nil
-			}
-		}
-		m.queueRedraw(left, top, (right - left), -count)
-		if bot < m.rows-1 {
-			m.queueRedraw(left, bot+1, (right - left), -count)
+	spans := make(map[int][]*MiniMapSpan)
+	items, _ := result.([]interface{})
+	for _, item := range items {
+		span, ok := item.([]interface{})
+		if !ok || len(span) < 4 {
+			continue
 		}
+		row := reflectToInt(span[0])
+		spans[row] = append(spans[row], &MiniMapSpan{
+			start: reflectToInt(span[1]),
+			end:   reflectToInt(span[2]),
+			color: calcColor(reflectToInt(span[3])),
+		})
 	}
+	m.highlightUpdates <- spans
+	m.signal.HighlightSignal()
 }
 
-func (m *MiniMap) update() {
-	x := m.queueRedrawArea[0]
-	y := m.queueRedrawArea[1]
-	width := m.queueRedrawArea[2] - x
-	height := m.queueRedrawArea[3] - y
-	if width > 0 && height > 0 {
-		// m.item.SetPixmap(s.pixmap)
-		m.widget.Update2(
-			int(float64(x)*m.font.truewidth),
-			y*m.font.lineHeight,
-			int(float64(width)*m.font.truewidth),
-			height*m.font.lineHeight,
+func (m *MiniMap) paint(event *gui.QPaintEvent) {
+	rect := event.M_rect()
+	p := gui.NewQPainter2(m.widget)
+	if m.background != nil {
+		p.FillRect5(
+			rect.X(),
+			rect.Y(),
+			rect.Width(),
+			rect.Height(),
+			m.background.QColor(),
 		)
 	}
-	m.queueRedrawArea[0] = m.cols
-	m.queueRedrawArea[1] = m.rows
-	m.queueRedrawArea[2] = 0
-	m.queueRedrawArea[3] = 0
-}
 
-func (m *MiniMap) queueRedrawAll() {
-	m.queueRedrawArea = [4]int{0, 0, m.cols, m.rows}
-}
-
-func (m *MiniMap) redraw() {
-	m.queueRedrawArea = [4]int{m.cols, m.rows, 0, 0}
-}
-
-func (m *MiniMap) queueRedraw(x, y, width, height int) {
-	if x < m.queueRedrawArea[0] {
-		m.queueRedrawArea[0] = x
-	}
-	if y < m.queueRedrawArea[1] {
-		m.queueRedrawArea[1] = y
-	}
-	if (x + width) > m.queueRedrawArea[2] {
-		m.queueRedrawArea[2] = x + width
-	}
-	if (y + height) > m.queueRedrawArea[3] {
-		m.queueRedrawArea[3] = y + height
+	top := rect.Y() / minimapLineHeight
+	bottom := (rect.Y()+rect.Height())/minimapLineHeight + 1
+	for y := top; y <= bottom && y < m.rows; y++ {
+		row := m.topLine - 1 + y
+		if row < 0 || row >= len(m.lines) {
+			continue
+		}
+		m.drawLine(p, y, row)
 	}
+
+	m.drawGitHunks(p)
+	p.DestroyQPainter()
 }
 
-func (m *MiniMap) drawText(p *gui.QPainter, y int, col int, cols int, pos [2]int) {
-	if y >= len(m.content) {
+// drawLine draws the characters of the line as blocks of their colors
+func (m *MiniMap) drawLine(p *gui.QPainter, y int, row int) {
+	if m.foreground == nil {
 		return
 	}
-	font := p.Font()
-	font.SetBold(false)
-	font.SetItalic(false)
-	pointF := core.NewQPointF()
-	line := m.content[y]
-	chars := map[Highlight][]int{}
-	specialChars := []int{}
-	if col > 0 {
-		char := line[col-1]
-		if char != nil && char.char != "" {
-			if !char.normalWidth {
-				col--
-				cols++
-			}
-		}
-	}
-	if col+cols < m.cols {
-	}
-	for x := col; x < col+cols; x++ {
-		if x >= len(line) {
-			continue
-		}
-		char := line[x]
-		if char == nil {
-			continue
-		}
-		if char.char == " " {
-			continue
+	spans := m.spans[row]
+	tabstop := m.tabstop
+	if tabstop < 1 {
+		tabstop = 8
+	}
+	var color *RGBA
+	start := 0
+	flush := func(end int) {
+		if end > m.cols {
+			end = m.cols
+		}
+		if color != nil && end > start {
+			p.FillRect5(
+				start*minimapCharWidth,
+				y*minimapLineHeight,
+				(end-start)*minimapCharWidth,
+				minimapLineHeight,
+				color.QColor(),
+			)
+		}
+		color = nil
+	}
+	x := 0
+	for i, r := range m.lines[row] {
+		if x >= m.cols {
+			break
 		}
-		if char.char == "" {
+		switch r {
+		case '\t':
+			flush(x)
+			x += tabstop - x%tabstop
 			continue
-		}
-		if !char.normalWidth {
-			specialChars = append(specialChars, x)
+		case ' ':
+			flush(x)
+			x++
 			continue
 		}
-		highlight := Highlight{}
-		fg := char.highlight.foreground
-		if fg == nil {
-			fg = m.foreground
-		}
-		highlight.foreground = fg
-		highlight.italic = char.highlight.italic
-		highlight.bold = char.highlight.bold
-
-		colorSlice, ok := chars[highlight]
-		if !ok {
-			colorSlice = []int{}
-		}
-		colorSlice = append(colorSlice, x)
-		chars[highlight] = colorSlice
-	}
-	for highlight, colorSlice := range chars {
-		text := ""
-		slice := colorSlice[:]
-		for x := col; x < col+cols; x++ {
-			if len(slice) == 0 {
-				break
-			}
-			index := slice[0]
-			if x < index {
-				text += " "
-				continue
-			}
-			if x == index {
-				text += line[x].char
-				slice = slice[1:]
-			}
-		}
-		if text != "" {
-			fg := highlight.foreground
-			if fg != nil {
-				p.SetPen2(gui.NewQColor3(fg.R, fg.G, fg.B, int(fg.A*255)))
-			}
-			pointF.SetX(float64(col-pos[1]) * m.font.truewidth)
-			pointF.SetY(float64((y-pos[0])*m.font.lineHeight + m.font.shift))
-			font.SetBold(highlight.bold)
-			font.SetItalic(highlight.italic)
-			p.DrawText(pointF, text)
+		c := minimapSpanColor(spans, i, m.foreground)
+		if color == nil || !color.equals(c) {
+			flush(x)
+			color = c
+			start = x
 		}
+		x++
 	}
+	flush(x)
+}
 
-	for _, x := range specialChars {
-		char := line[x]
-		if char == nil || char.char == " " {
-			continue
+// minimapSpanColor returns the color of the column, of which the last span
+// wins like the nested treesitter captures
+func minimapSpanColor(spans []*MiniMapSpan, col int, fg *RGBA) *RGBA {
+	for i := len(spans) - 1; i >= 0; i-- {
+		span := spans[i]
+		if span.start <= col && (span.end < 0 || col < span.end) {
+			return span.color
 		}
-		fg := char.highlight.foreground
-		if fg == nil {
-			fg = m.foreground
-		}
-		p.SetPen2(gui.NewQColor3(fg.R, fg.G, fg.B, int(fg.A*255)))
-		pointF.SetX(float64(x-pos[1]) * m.font.truewidth)
-		pointF.SetY(float64((y-pos[0])*m.font.lineHeight + m.font.shift))
-		font.SetBold(char.highlight.bold)
-		font.SetItalic(char.highlight.italic)
-		p.DrawText(pointF, char.char)
 	}
+	return fg
 }
 
-func (m *MiniMap) fillHightlight(p *gui.QPainter, y int, col int, cols int, pos [2]int) {
-	rectF := core.NewQRectF()
-	if y >= len(m.content) {
-		return
-	}
-	line := m.content[y]
-	start := -1
-	end := -1
-	var lastBg *RGBA
-	var bg *RGBA
-	var lastChar *Char
-	for x := col; x < col+cols; x++ {
-		if x >= len(line) {
-			continue
-		}
-		char := line[x]
-		if char != nil {
-			bg = char.highlight.background
-		} else {
-			bg = nil
-		}
-		if lastChar != nil && !lastChar.normalWidth {
-			bg = lastChar.highlight.background
+// drawGitHunks draws the git change markers as bars on the left edge
+func (m *MiniMap) drawGitHunks(p *gui.QPainter) {
+	for _, hunk := range m.ws.gitHunks {
+		y := (hunk.start - m.topLine) * minimapLineHeight
+		height := hunk.count * minimapLineHeight
+		if hunk.kind == "delete" {
+			height = 2
 		}
-		if bg != nil {
-			if lastBg == nil {
-				start = x
-				end = x
-				lastBg = bg
-			} else {
-				if lastBg.equals(bg) {
-					end = x
-				} else {
-					// last bg is different; draw the previous and start a new one
-					rectF.SetRect(
-						float64(start-pos[1])*m.font.truewidth,
-						float64((y-pos[0])*m.font.lineHeight),
-						float64(end-start+1)*m.font.truewidth,
-						float64(m.font.lineHeight),
-					)
-					p.FillRect4(
-						rectF,
-						gui.NewQColor3(lastBg.R, lastBg.G, lastBg.B, int(lastBg.A*255)),
-					)
-
-					// start a new one
-					start = x
-					end = x
-					lastBg = bg
-				}
-			}
-		} else {
-			if lastBg != nil {
-				rectF.SetRect(
-					float64(start-pos[1])*m.font.truewidth,
-					float64((y-pos[0])*m.font.lineHeight),
-					float64(end-start+1)*m.font.truewidth,
-					float64(m.font.lineHeight),
-				)
-				p.FillRect4(
-					rectF,
-					gui.NewQColor3(lastBg.R, lastBg.G, lastBg.B, int(lastBg.A*255)),
-				)
-
-				// start a new one
-				start = x
-				end = x
-				lastBg = nil
-			}
+		if y+height < 0 || y > m.height {
+			continue
 		}
-		lastChar = char
-	}
-	if lastBg != nil {
-		rectF.SetRect(
-			float64(start-pos[1])*m.font.truewidth,
-			float64((y-pos[0])*m.font.lineHeight),
-			float64(end-start+1)*m.font.truewidth,
-			float64(m.font.lineHeight),
-		)
-		p.FillRect4(
-			rectF,
-			gui.NewQColor3(lastBg.R, lastBg.G, lastBg.B, int(lastBg.A*255)),
-		)
+		p.FillRect5(0, y, 3, height, gitHunkColor(hunk.kind).QColor())
 	}
 }
 
-func (m *MiniMap) resize(args []interface{}) {
-	m.cursor[0] = 0
-	m.cursor[1] = 0
-	m.content = make([][]*Char, m.rows)
-	for i := 0; i < m.rows; i++ {
-		m.content[i] = make([]*Char, m.cols)
-	}
-	m.queueRedrawAll()
-}
-
-func (m *MiniMap) clear(args []interface{}) {
-	m.cursor[0] = 0
-	m.cursor[1] = 0
-	m.content = make([][]*Char, m.rows)
-	for i := 0; i < m.rows; i++ {
-		m.content[i] = make([]*Char, m.cols)
+func (m *MiniMap) updateSize() {
+	m.width = m.widget.Width()
+	m.height = m.widget.Height()
+	rows := m.height / minimapLineHeight
+	cols := m.width / minimapCharWidth
+	if rows == m.rows && cols == m.cols {
+		return
 	}
-	m.queueRedrawAll()
+	m.rows = rows
+	m.cols = cols
+	m.mapScroll()
+	m.queueHighlight()
 }
 
-func (m *MiniMap) eolClear(args []interface{}) {
-	row := m.cursor[0]
-	col := m.cursor[1]
-	if row >= m.rows {
-		return
+// mapScroll scrolls the minimap with the window of the cursor, so that the
+// top and the bottom of the buffer meet those of the minimap, and moves the
+// region of the window
+func (m *MiniMap) mapScroll() {
+	var regionHeight int
+	var winpos [2]int
+	for _, win := range m.ws.screen.curWins {
+		if win.pos[0] <= m.ws.screen.cursor[0] && m.ws.screen.cursor[0] <= win.pos[0]+win.height {
+			regionHeight = win.height
+			winpos = win.pos
+			break
+		}
 	}
-	line := m.content[row]
-	numChars := 0
-	for x := col; x < len(line); x++ {
-		line[x] = nil
-		numChars++
+	windowTop := m.ws.curLine - (m.ws.screen.cursor[0] - winpos[0])
+	if windowTop < 1 {
+		windowTop = 1
 	}
-	m.queueRedraw(col, row, numChars+1, 1)
-}
-
-func (m *MiniMap) cursorGoto(args []interface{}) {
-	pos, _ := args[0].([]interface{})
-	m.cursor[0] = reflectToInt(pos[0])
-	m.cursor[1] = reflectToInt(pos[1])
-}
 
-func (m *MiniMap) isNormalWidth(char string) bool {
-	if len(char) == 0 {
-		return true
+	total := len(m.lines)
+	topLine := 1
+	if total > m.rows && total > regionHeight {
+		topLine = 1 + (windowTop-1)*(total-m.rows)/(total-regionHeight)
+		if topLine > total-m.rows+1 {
+			topLine = total - m.rows + 1
+		}
+		if topLine < 1 {
+			topLine = 1
+		}
 	}
-	if char[0] <= 127 {
-		return true
+	if topLine != m.topLine {
+		m.topLine = topLine
+		m.queueHighlight()
+		m.widget.Update()
 	}
-	//return s.ws.font.fontMetrics.Width(char) == s.ws.font.truewidth
-	return m.font.fontMetrics.HorizontalAdvance(char, -1) == m.font.truewidth
+
+	m.curRegion.SetFixedHeight(regionHeight * minimapLineHeight)
+	m.curRegion.Move2(0, (windowTop-m.topLine)*minimapLineHeight)
 }
 
+// wheelEvent scrolls the window of the buffer
 func (m *MiniMap) wheelEvent(event *gui.QWheelEvent) {
-	var mu sync.Mutex
-	mu.Lock()
-	defer mu.Unlock()
-
-	var v, h, vert, horiz int
-	var accel int
-	font := m.font
-
+	var lines int
 	switch runtime.GOOS {
 	case "darwin":
 		pixels := event.PixelDelta()
 		if pixels != nil {
-			v = pixels.Y()
-			h = pixels.X()
-		}
-		if pixels.X() < 0 && m.scrollDust[0] > 0 {
-			m.scrollDust[0] = 0
-		}
-		if pixels.Y() < 0 && m.scrollDust[1] > 0 {
-			m.scrollDust[1] = 0
-		}
-
-		dx := math.Abs(float64(m.scrollDust[0]))
-		dy := math.Abs(float64(m.scrollDust[1]))
-
-		fontheight := float64(float64(font.lineHeight))
-		fontwidth := float64(font.truewidth)
-
-		m.scrollDust[0] += h
-		m.scrollDust[1] += v
-
-		if dx >= fontwidth {
-			horiz = int(math.Trunc(float64(m.scrollDust[0]) / fontheight))
-			m.scrollDust[0] = 0
-		}
-		if dy >= fontwidth {
-			vert = int(math.Trunc(float64(m.scrollDust[1]) / fontwidth))
-			m.scrollDust[1] = 0
+			m.scrollDust += pixels.Y()
 		}
-
-		m.scrollDustDeltaY = int(math.Abs(float64(vert)) - float64(m.scrollDustDeltaY))
-		if m.scrollDustDeltaY < 1 {
-			m.scrollDustDeltaY = 0
-		}
-		if m.scrollDustDeltaY <= 2 {
-			accel = 1
-		} else if m.scrollDustDeltaY > 2 {
-			accel = int(float64(m.scrollDustDeltaY) / float64(4))
-		}
-
+		lines = m.scrollDust / minimapLineHeight
+		m.scrollDust -= lines * minimapLineHeight
 	default:
-		vert = event.AngleDelta().Y()
-		accel = 16
+		if event.AngleDelta().Y() > 0 {
+			lines = 16
+		} else if event.AngleDelta().Y() < 0 {
+			lines = -16
+		}
 	}
-
-	if vert == 0 && horiz == 0 {
+	event.Accept()
+	if lines == 0 {
 		return
 	}
 
-	if vert > 0 {
-		m.nvim.Input(fmt.Sprintf("%v<C-y>", accel))
-	} else if vert < 0 {
-		m.nvim.Input(fmt.Sprintf("%v<C-e>", accel))
+	if lines > 0 {
+		go m.ws.nvim.Command(fmt.Sprintf(`exe "normal! %d\<C-y>"`, lines))
+	} else {
+		go m.ws.nvim.Command(fmt.Sprintf(`exe "normal! %d\<C-e>"`, -lines))
 	}
-
-	event.Accept()
 }
 
 func (m *MiniMap) mouseEvent(event *gui.QMouseEvent) {
-	font := m.font
-	y := int(float64(event.Y()) / float64(font.lineHeight))
-	var absMapTop int
-	m.nvim.Eval("line('w0')", &absMapTop)
-	targetPos := absMapTop + y
-	m.ws.nvim.Command(fmt.Sprintf("%d", targetPos))
+	targetPos := m.topLine + event.Y()/minimapLineHeight
+	if targetPos > len(m.lines) {
+		targetPos = len(m.lines)
+	}
+	if targetPos < 1 {
+		return
+	}
+	// Clicking a git change marker jumps to the top of the hunk
+	if event.X() < 4 {
+		for _, hunk := range m.ws.gitHunks {
+			if hunk.start <= targetPos && targetPos <= hunk.end() {
+				targetPos = hunk.start
+				break
+			}
+		}
+	}
+	go m.ws.nvim.Command(fmt.Sprintf("%d", targetPos))
 }
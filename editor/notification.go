@@ -2,6 +2,7 @@ package editor
 
 import (
 	"fmt"
+	"strings"
 	"time"
 	"unicode/utf8"
 
@@ -14,17 +15,27 @@ import (
 type NotifyLevel int
 
 const (
-	NotifyInfo NotifyLevel = 0
-	NotifyWarn NotifyLevel = 1
+	NotifyInfo  NotifyLevel = 0
+	NotifyWarn  NotifyLevel = 1
+	NotifyError NotifyLevel = 2
 )
 
 type Notification struct {
 	widget    *widgets.QWidget
 	closeIcon *svg.QSvgWidget
+	label     *widgets.QLabel
+	timer     *core.QTimer
 	pos       *core.QPoint
 	isDrag    bool
 	isMoved   bool
 	isHide    bool
+	level     NotifyLevel
+	message   string
+	count     int
+	period    int
+	// hasButtons is set for the notifications asking an action, which are
+	// never collapsed into a repeat count
+	hasButtons bool
 }
 
 type NotifyOptions struct {
@@ -56,15 +67,7 @@ func newNotification(l NotifyLevel, p int, message string, options ...NotifyOpti
 	levelIcon.SetFixedWidth(editor.iconSize)
 	levelIcon.SetFixedHeight(editor.iconSize)
 	levelIcon.SetContentsMargins(0, 0, 0, 0)
-	var level string
-	switch l {
-	case NotifyInfo:
-		level = e.getSvg("info", newRGBA(27, 161, 226, 1))
-	case NotifyWarn:
-		level = e.getSvg("warn", newRGBA(255, 205, 0, 1))
-	default:
-		level = e.getSvg("info", newRGBA(27, 161, 226, 1))
-	}
+	level := notifyLevelIcon(l)
 	levelIcon.Load2(core.NewQByteArray2(level, len(level)))
 
 	label := widgets.NewQLabel(nil, 0)
@@ -116,7 +119,12 @@ func newNotification(l NotifyLevel, p int, message string, options ...NotifyOpti
 	bottomlayout.SetSpacing(10)
 	bottomwidget.SetLayout(bottomlayout)
 
-	notification := &Notification{}
+	notification := &Notification{
+		label:   label,
+		level:   l,
+		message: message,
+		count:   1,
+	}
 	opts := NotifyOptions{}
 	for _, o := range options {
 		o(&opts)
@@ -155,6 +163,7 @@ func newNotification(l NotifyLevel, p int, message string, options ...NotifyOpti
 		}
 	}
 	if len(opts.buttons) > 0 {
+		notification.hasButtons = true
 		layout.SetSpacing(8)
 		bottomlayout.SetContentsMargins(0, 0, 0, 0)
 		bottomlayout.SetSpacing(10)
@@ -227,16 +236,70 @@ func newNotification(l NotifyLevel, p int, message string, options ...NotifyOpti
 	} else {
 		displayPeriod = p
 	}
+	notification.period = displayPeriod
 	if displayPeriod > 0 {
 		timer := core.NewQTimer(nil)
 		timer.SetSingleShot(true)
 		timer.ConnectTimeout(notification.hideNotification)
 		timer.Start(displayPeriod * 1000)
+		notification.timer = timer
 	}
 
 	return notification
 }
 
+// notifyLevelIcon returns the svg of the icon of the level
+func notifyLevelIcon(l NotifyLevel) string {
+	switch l {
+	case NotifyWarn:
+		return editor.getSvg("warn", newRGBA(255, 205, 0, 1))
+	case NotifyError:
+		return editor.getSvg("fire", newRGBA(204, 62, 68, 1))
+	default:
+		return editor.getSvg("info", newRGBA(27, 161, 226, 1))
+	}
+}
+
+// findNotification returns the shown notification of the same message
+func (e *Editor) findNotification(level NotifyLevel, message string) *Notification {
+	for _, item := range e.notifications {
+		if !item.isHide && !item.hasButtons && item.level == level && item.message == message {
+			return item
+		}
+	}
+	return nil
+}
+
+// repeat counts up the notification of the same message instead of popping
+// up another one, and extends its display period
+func (n *Notification) repeat() {
+	n.count++
+	n.label.SetText(fmt.Sprintf("%s (×%d)", n.message, n.count))
+	if n.timer != nil {
+		n.timer.Start(n.period * 1000)
+	}
+}
+
+// collapseRepeatedLines collapses the repeated lines of the message, like
+// the same errors in the output of :messages
+func collapseRepeatedLines(message string) string {
+	lines := strings.Split(message, "\n")
+	collapsed := []string{}
+	count := 0
+	for i, line := range lines {
+		count++
+		if i+1 < len(lines) && lines[i+1] == line {
+			continue
+		}
+		if count > 1 && strings.TrimSpace(line) != "" {
+			line = fmt.Sprintf("%s (×%d)", line, count)
+		}
+		collapsed = append(collapsed, line)
+		count = 0
+	}
+	return strings.Join(collapsed, "\n")
+}
+
 func (n *Notification) dropNotifications(fn ...func(*Notification)) {
 	e := editor
 	var newNotifications []*Notification
@@ -293,46 +356,6 @@ func (n *Notification) hideNotification() {
 		item.widget.Hide()
 		item.isHide = true
 	})
-	editor.displayNotifications = false
-	for _, item := range editor.notifications {
-		if item.isHide == false {
-			editor.displayNotifications = true
-		}
-	}
-}
-
-func (e *Editor) showNotifications() {
-	e.notifyStartPos = core.NewQPoint2(e.width-e.notificationWidth-10, e.height-30)
-	var x, y int
-	var newNotifications []*Notification
-	for _, item := range e.notifications {
-		x = e.notifyStartPos.X()
-		y = e.notifyStartPos.Y() - item.widget.Height() - 4
-		item.widget.Move2(x, y)
-		item.statusReset()
-		e.notifyStartPos = core.NewQPoint2(x, y)
-		newNotifications = append(newNotifications, item)
-	}
-	e.notifications = newNotifications
-	e.displayNotifications = true
-}
-
-func (e *Editor) hideNotifications() {
-	var newNotifications []*Notification
-	for _, item := range e.notifications {
-		item.isHide = true
-		item.widget.Hide()
-		newNotifications = append(newNotifications, item)
-	}
-	e.notifications = newNotifications
-	e.notifyStartPos = core.NewQPoint2(e.width-e.notificationWidth-10, e.height-30)
-	e.displayNotifications = false
-}
-
-func (n *Notification) statusReset() {
-	n.isHide = false
-	n.isMoved = false
-	n.widget.Show()
 }
 
 func (n *Notification) show() {
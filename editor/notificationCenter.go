@@ -0,0 +1,342 @@
+package editor
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/svg"
+	"github.com/therecipe/qt/widgets"
+)
+
+// notificationCenterMaxEntries is the number of the notifications kept in
+// the notification center
+const notificationCenterMaxEntries = 100
+
+// NotificationCenter is the panel keeping the history of the notifications
+type NotificationCenter struct {
+	widget       *widgets.QWidget
+	title        *widgets.QLabel
+	dndButton    *widgets.QLabel
+	clearButton  *widgets.QLabel
+	scrollArea   *widgets.QScrollArea
+	list         *widgets.QWidget
+	listLayout   *widgets.QVBoxLayout
+	rows         []*widgets.QWidget
+	entries      []*NotificationEntry
+	doNotDisturb bool
+	unread       int
+	visible      bool
+}
+
+// NotificationEntry is a notification in the notification center
+type NotificationEntry struct {
+	level   NotifyLevel
+	message string
+	buttons []*NotifyButton
+	handled bool
+	count   int
+	time    time.Time
+}
+
+func newNotificationCenter() *NotificationCenter {
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetContentsMargins(0, 0, 0, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(0)
+	widget.SetLayout(layout)
+
+	header := widgets.NewQWidget(nil, 0)
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(12, 8, 12, 8)
+	headerLayout.SetSpacing(12)
+	header.SetLayout(headerLayout)
+	title := widgets.NewQLabel(nil, 0)
+	title.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	title.SetText("Notifications")
+	dndButton := widgets.NewQLabel(nil, 0)
+	dndButton.SetText("Do not disturb: off")
+	clearButton := widgets.NewQLabel(nil, 0)
+	clearButton.SetText("Clear all")
+	headerLayout.AddWidget(title, 1, 0)
+	headerLayout.AddWidget(dndButton, 0, 0)
+	headerLayout.AddWidget(clearButton, 0, 0)
+
+	list := widgets.NewQWidget(nil, 0)
+	listLayout := widgets.NewQVBoxLayout()
+	listLayout.SetContentsMargins(0, 0, 0, 0)
+	listLayout.SetSpacing(0)
+	listLayout.AddStretch(1)
+	list.SetLayout(listLayout)
+
+	scrollArea := widgets.NewQScrollArea(nil)
+	scrollArea.SetWidgetResizable(true)
+	scrollArea.SetFrameShape(widgets.QFrame__NoFrame)
+	scrollArea.SetHorizontalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	scrollArea.SetWidget(list)
+
+	layout.AddWidget(header, 0, 0)
+	layout.AddWidget(scrollArea, 1, 0)
+	widget.Hide()
+
+	c := &NotificationCenter{
+		widget:      widget,
+		title:       title,
+		dndButton:   dndButton,
+		clearButton: clearButton,
+		scrollArea:  scrollArea,
+		list:        list,
+		listLayout:  listLayout,
+	}
+	dndButton.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		c.setDoNotDisturb(!c.doNotDisturb)
+	})
+	clearButton.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		c.clear()
+	})
+
+	go func() {
+		shadow := widgets.NewQGraphicsDropShadowEffect(nil)
+		shadow.SetBlurRadius(40)
+		shadow.SetColor(gui.NewQColor3(0, 0, 0, 200))
+		shadow.SetOffset3(-2, -1)
+		widget.SetGraphicsEffect(shadow)
+	}()
+
+	return c
+}
+
+// add keeps the notification, collapsing it into the entry of the same
+// message
+func (c *NotificationCenter) add(notify *Notify) {
+	if !c.visible {
+		c.unread++
+	}
+	for i, entry := range c.entries {
+		if entry.level != notify.level || entry.message != notify.message {
+			continue
+		}
+		entry.count++
+		entry.time = time.Now()
+		if notify.buttons != nil {
+			entry.buttons = c.entryButtons(entry, notify.buttons)
+			entry.handled = false
+			notify.buttons = entry.buttons
+		}
+		c.entries = append(append([]*NotificationEntry{entry}, c.entries[:i]...), c.entries[i+1:]...)
+		c.update()
+		return
+	}
+	entry := &NotificationEntry{
+		level:   notify.level,
+		message: notify.message,
+		count:   1,
+		time:    time.Now(),
+	}
+	entry.buttons = c.entryButtons(entry, notify.buttons)
+	// The actions of the popped up notification use the buttons of the entry
+	// to mark it handled
+	notify.buttons = entry.buttons
+	c.entries = append([]*NotificationEntry{entry}, c.entries...)
+	if len(c.entries) > notificationCenterMaxEntries {
+		c.entries = c.entries[:notificationCenterMaxEntries]
+	}
+	c.update()
+}
+
+// entryButtons returns the buttons marking the entry handled on click
+func (c *NotificationCenter) entryButtons(entry *NotificationEntry, buttons []*NotifyButton) []*NotifyButton {
+	if buttons == nil {
+		return nil
+	}
+	entryButtons := []*NotifyButton{}
+	for _, b := range buttons {
+		action := b.action
+		entryButtons = append(entryButtons, &NotifyButton{
+			text: b.text,
+			action: func() {
+				entry.handled = true
+				action()
+			},
+		})
+	}
+	return entryButtons
+}
+
+func (c *NotificationCenter) toggle() {
+	if c.visible {
+		c.hide()
+	} else {
+		c.show()
+	}
+}
+
+func (c *NotificationCenter) show() {
+	c.visible = true
+	c.unread = 0
+	c.setColor()
+	c.update()
+	c.widget.Raise()
+	c.widget.Show()
+	c.updateStatuslines()
+}
+
+func (c *NotificationCenter) hide() {
+	if !c.visible {
+		return
+	}
+	c.visible = false
+	c.widget.Hide()
+}
+
+// clear removes the entries and closes the popped up notifications
+func (c *NotificationCenter) clear() {
+	e := editor
+	for _, item := range e.notifications {
+		item.widget.DestroyQWidget()
+	}
+	e.notifications = nil
+	e.notifyStartPos = core.NewQPoint2(e.width-e.notificationWidth-10, e.height-30)
+	c.entries = nil
+	c.unread = 0
+	c.update()
+	c.updateStatuslines()
+}
+
+// updateStatuslines updates the count of the unread notifications in the
+// statuslines of the workspaces
+func (c *NotificationCenter) updateStatuslines() {
+	for _, ws := range editor.workspaces {
+		if ws.statusline != nil {
+			ws.statusline.notify.update()
+		}
+	}
+}
+
+// setDoNotDisturb stops popping up the notifications, which are still kept
+// in the notification center
+func (c *NotificationCenter) setDoNotDisturb(dnd bool) {
+	c.doNotDisturb = dnd
+	if dnd {
+		c.dndButton.SetText("Do not disturb: on")
+	} else {
+		c.dndButton.SetText("Do not disturb: off")
+	}
+}
+
+func (c *NotificationCenter) setColor() {
+	fg := editor.fgcolor
+	bg := editor.bgcolor
+	if fg == nil || bg == nil {
+		return
+	}
+	c.widget.SetStyleSheet(fmt.Sprintf(" * { color: %s; background: %s; } QLabel#detail { color: %s; } .QWidget#entry { border-top: 1px solid %s; }", fg.print(), shiftColor(bg, -8).print(), gradColor(fg).print(), shiftColor(bg, 5).print()))
+	c.dndButton.SetStyleSheet(fmt.Sprintf(" * { color: %s; }", editor.config.SideBar.AccentColor))
+	c.clearButton.SetStyleSheet(fmt.Sprintf(" * { color: %s; }", editor.config.SideBar.AccentColor))
+}
+
+// update renders the entries when the notification center is shown
+func (c *NotificationCenter) update() {
+	if !c.visible {
+		return
+	}
+	for _, row := range c.rows {
+		row.DestroyQWidget()
+	}
+	c.rows = nil
+	if len(c.entries) == 0 {
+		row := widgets.NewQLabel(nil, 0)
+		row.SetObjectName("detail")
+		row.SetContentsMargins(12, 12, 12, 12)
+		row.SetText("No notifications")
+		c.listLayout.InsertWidget(0, row, 0, 0)
+		c.rows = append(c.rows, row.QWidget_PTR())
+	}
+	for i, entry := range c.entries {
+		row := c.newRow(entry)
+		c.listLayout.InsertWidget(i, row, 0, 0)
+		c.rows = append(c.rows, row)
+	}
+	c.title.SetText(fmt.Sprintf("Notifications (%d)", len(c.entries)))
+	c.move()
+}
+
+func (c *NotificationCenter) newRow(entry *NotificationEntry) *widgets.QWidget {
+	row := widgets.NewQWidget(nil, 0)
+	row.SetObjectName("entry")
+	row.SetAttribute(core.Qt__WA_StyledBackground, true)
+	layout := widgets.NewQGridLayout2()
+	layout.SetContentsMargins(12, 8, 12, 8)
+	layout.SetHorizontalSpacing(8)
+	row.SetLayout(layout)
+
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedSize2(editor.iconSize, editor.iconSize)
+	svgContent := notifyLevelIcon(entry.level)
+	icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+
+	message := widgets.NewQLabel(nil, 0)
+	message.SetWordWrap(true)
+	message.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	message.SetText(entry.message)
+
+	detail := widgets.NewQLabel(nil, 0)
+	detail.SetObjectName("detail")
+	text := notificationTime(entry.time)
+	if entry.count > 1 {
+		text = fmt.Sprintf("%s  ×%d", text, entry.count)
+	}
+	detail.SetText(text)
+
+	layout.AddWidget(icon, 0, 0, core.Qt__AlignTop)
+	layout.AddWidget(message, 0, 1, 0)
+	layout.AddWidget(detail, 0, 2, core.Qt__AlignTop)
+	layout.SetColumnStretch(1, 1)
+
+	if len(entry.buttons) > 0 && !entry.handled {
+		buttons := widgets.NewQWidget(nil, 0)
+		buttonsLayout := widgets.NewQHBoxLayout()
+		buttonsLayout.SetContentsMargins(0, 4, 0, 0)
+		buttonsLayout.SetSpacing(10)
+		buttons.SetLayout(buttonsLayout)
+		for _, b := range entry.buttons {
+			button := widgets.NewQLabel(nil, 0)
+			button.SetText(html.EscapeString(b.text))
+			button.SetContentsMargins(10, 3, 10, 3)
+			button.SetStyleSheet(" * { color: #ffffff; background: #0e639c; }")
+			action := b.action
+			button.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+				entry.handled = true
+				go action()
+				c.update()
+			})
+			buttonsLayout.AddWidget(button, 0, 0)
+		}
+		buttonsLayout.AddStretch(1)
+		layout.AddWidget(buttons, 1, 1, 0)
+	}
+	return row
+}
+
+// notificationTime returns the time of the notification, with the date
+// unless it is today
+func notificationTime(t time.Time) string {
+	now := time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04:05")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
+// move places the notification center above the statusline at the right
+// of the window
+func (c *NotificationCenter) move() {
+	e := editor
+	width := e.notificationWidth
+	height := e.height * 2 / 3
+	c.widget.SetFixedSize2(width, height)
+	c.widget.Move2(e.width-width-10, e.height-height-30)
+}
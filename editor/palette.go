@@ -36,6 +36,7 @@ type Palette struct {
 	scrollBar        *widgets.QWidget
 	scrollBarPos     int
 	scrollCol        *widgets.QWidget
+	footer           *widgets.QLabel
 }
 
 // PaletteResultItem is the result item
@@ -115,8 +116,15 @@ func initPalette() *Palette {
 	cursor.SetFixedSize2(1, pattern.SizeHint().Height()-padding*2)
 	cursor.Move2(padding, padding)
 
+	footer := widgets.NewQLabel(nil, 0)
+	footer.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	footer.SetContentsMargins(padding, padding/2, padding, padding/2)
+	footer.SetWordWrap(true)
+	footer.Hide()
+
 	mainLayout.AddWidget(patternWidget, 0, 0)
 	mainLayout.AddWidget(resultMainWidget, 0, 0)
+	mainLayout.AddWidget(footer, 0, 0)
 
 	palette := &Palette{
 		width:            width,
@@ -129,6 +137,7 @@ func initPalette() *Palette {
 		scrollCol:        scrollCol,
 		scrollBar:        scrollBar,
 		cursor:           cursor,
+		footer:           footer,
 	}
 
 	resultItems := []*PaletteResultItem{}
@@ -233,11 +242,29 @@ func (p *Palette) hide() {
 	p.widget.Hide()
 }
 
+func (p *Palette) setFooter(text string) {
+	if text == "" {
+		p.footer.Hide()
+		return
+	}
+	p.footer.SetText(text)
+	p.footer.Show()
+}
+
 func (p *Palette) setPattern(text string) {
 	p.patternText = text
+	p.pattern.SetTextFormat(core.Qt__AutoText)
 	p.pattern.SetText(text)
 }
 
+// setFormattedPattern sets the pattern in html, with the plain text of it
+// which the cursor is placed in
+func (p *Palette) setFormattedPattern(text string, formatted string) {
+	p.patternText = text
+	p.pattern.SetTextFormat(core.Qt__RichText)
+	p.pattern.SetText(formatted)
+}
+
 func (p *Palette) cursorMove(x int) {
 	//p.cursorX = int(p.ws.font.defaultFontMetrics.Width(string(p.patternText[:x])))
 	font := gui.NewQFontMetricsF(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
@@ -300,6 +327,14 @@ func (f *PaletteResultItem) setItem(text string, itemType string, match []int) {
 		path = true
 	} else if itemType == "file_line" {
 		iconType = "empty"
+	} else if itemType == "tag" {
+		iconType = "flag"
+	} else if itemType == "help" {
+		iconType = "info"
+	} else if itemType == "commit" {
+		iconType = "git"
+	} else if itemType == "mark" {
+		iconType = "star"
 	}
 	if iconType != "" {
 		if iconType != f.iconType {
@@ -318,6 +353,15 @@ func (f *PaletteResultItem) setItem(text string, itemType string, match []int) {
 	}
 }
 
+// setFormattedItem sets the item in html without an icon
+func (f *PaletteResultItem) setFormattedItem(formatted string) {
+	f.hideIcon()
+	if formatted != f.baseText {
+		f.baseText = formatted
+		f.base.SetText(f.baseText)
+	}
+}
+
 func (f *PaletteResultItem) updateIcon() {
 	svgContent := editor.getSvg(f.iconType, nil)
 	f.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
@@ -0,0 +1,192 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// notifyLua overrides vim.notify to show the notifications in the GUI, of
+// which opts may have buttons, a list of the ids or {id, text}, and
+// on_action, a function called with the id of the clicked button. With
+// keep, the notifications without buttons go to the previous vim.notify.
+// It sends them with vim.rpcnotify, which works in the fast event contexts
+// like the callbacks of luv unlike vim.fn.
+const notifyLua = `(function(keep)
+  _G._gonvim_notify_callbacks = _G._gonvim_notify_callbacks or {}
+  local count = 0
+  local previous = vim.notify
+  vim.notify = function(msg, level, opts)
+    opts = opts or {}
+    if keep and not opts.buttons then
+      return previous(msg, level, opts)
+    end
+    msg = tostring(msg)
+    count = count + 1
+    local id = opts.id or ('gonvim_notify_' .. count)
+    local buttons = {}
+    for _, b in ipairs(opts.buttons or {}) do
+      if type(b) == 'table' then
+        table.insert(buttons, {id = b.id or b.text, text = b.text or b.id})
+      else
+        table.insert(buttons, {id = b, text = b})
+      end
+    end
+    if opts.on_action then
+      _G._gonvim_notify_callbacks[id] = opts.on_action
+    end
+    if opts.title then
+      msg = '[' .. tostring(opts.title) .. '] ' .. msg
+    end
+    vim.rpcnotify(0, 'Gui', 'gonvim_notify', {
+      id = id,
+      level = level or vim.log.levels.INFO,
+      message = msg,
+      timeout = opts.timeout == false and 0 or opts.timeout,
+      buttons = buttons,
+    })
+  end
+end)(_A)`
+
+// notifyActionLua calls the on_action of vim.notify with the clicked button
+const notifyActionLua = `(function(id, button)
+  local callbacks = _G._gonvim_notify_callbacks
+  if callbacks and callbacks[id] then
+    local callback = callbacks[id]
+    callbacks[id] = nil
+    callback(button)
+  end
+end)(_A[1], _A[2])`
+
+// PluginNotify is a notification of gonvim_notify from plugins
+type PluginNotify struct {
+	id       string
+	level    NotifyLevel
+	message  string
+	period   int
+	buttons  []*PluginNotifyButton
+	callback string
+}
+
+// PluginNotifyButton is a button of gonvim_notify, of which id is sent back
+// to the plugin on click
+type PluginNotifyButton struct {
+	id   string
+	text string
+}
+
+// notify shows the notification of gonvim_notify, whose argument is a dict
+// of the level ("info", "warn", "error" or vim.log.levels), the message, the
+// timeout in milliseconds (0 keeps it shown), the buttons (the ids or
+// {id, text}), the id of the notification and the name of the callback
+// function called with the id and the clicked button
+func (w *Workspace) notify(args []interface{}) {
+	if len(args) < 1 {
+		return
+	}
+	n := parsePluginNotify(args[0])
+	if n == nil {
+		return
+	}
+	var opts []NotifyOptionArg
+	if len(n.buttons) > 0 {
+		buttons := []*NotifyButton{}
+		for _, b := range n.buttons {
+			button := b.id
+			buttons = append(buttons, &NotifyButton{
+				text: b.text,
+				action: func() {
+					w.notifyAction(n, button)
+				},
+			})
+		}
+		opts = append(opts, notifyOptionArg(buttons))
+	}
+	editor.pushNotification(n.level, n.period, n.message, opts...)
+}
+
+// notifyAction sends the button clicked back to the plugin with the callback,
+// on_action of vim.notify and the User GonvimNotifyAction autocmd with
+// g:gonvim_notify_action
+func (w *Workspace) notifyAction(n *PluginNotify, button string) {
+	w.nvim.SetVar("gonvim_notify_action", map[string]interface{}{
+		"id":     n.id,
+		"button": button,
+	})
+	if n.callback != "" {
+		err := w.nvim.Call(n.callback, nil, n.id, button)
+		if err != nil {
+			editor.pushNotification(NotifyError, -1, fmt.Sprintf("[Gonvim] %s: %s", n.callback, err))
+		}
+	}
+	w.nvim.Call("luaeval", nil, notifyActionLua, []interface{}{n.id, button})
+	w.nvim.Command("if exists('#User#GonvimNotifyAction') | doautocmd <nomodeline> User GonvimNotifyAction | endif")
+}
+
+func parsePluginNotify(arg interface{}) *PluginNotify {
+	dict, ok := arg.(map[string]interface{})
+	if !ok {
+		// rpcnotify(0, "Gui", "gonvim_notify", "message")
+		message, ok := arg.(string)
+		if !ok {
+			return nil
+		}
+		dict = map[string]interface{}{"message": message}
+	}
+	n := &PluginNotify{
+		level:  pluginNotifyLevel(dict["level"]),
+		period: -1,
+	}
+	n.message, _ = dict["message"].(string)
+	n.id, _ = dict["id"].(string)
+	n.callback, _ = dict["callback"].(string)
+	if strings.TrimSpace(n.message) == "" {
+		return nil
+	}
+	if timeout, ok := dict["timeout"]; ok && timeout != nil {
+		ms := reflectToInt(timeout)
+		n.period = (ms + 999) / 1000
+	}
+	buttons, _ := dict["buttons"].([]interface{})
+	for _, b := range buttons {
+		switch button := b.(type) {
+		case string:
+			n.buttons = append(n.buttons, &PluginNotifyButton{id: button, text: button})
+		case map[string]interface{}:
+			id, _ := button["id"].(string)
+			text, _ := button["text"].(string)
+			if text == "" {
+				text = id
+			}
+			if id == "" {
+				id = text
+			}
+			if text == "" {
+				continue
+			}
+			n.buttons = append(n.buttons, &PluginNotifyButton{id: id, text: text})
+		}
+	}
+	return n
+}
+
+// pluginNotifyLevel returns the level of the name or vim.log.levels
+func pluginNotifyLevel(level interface{}) NotifyLevel {
+	switch l := level.(type) {
+	case string:
+		switch strings.ToLower(l) {
+		case "error", "err":
+			return NotifyError
+		case "warn", "warning":
+			return NotifyWarn
+		}
+	case int64, uint64:
+		// vim.log.levels.WARN and vim.log.levels.ERROR
+		switch reflectToInt(l) {
+		case 3:
+			return NotifyWarn
+		case 4:
+			return NotifyError
+		}
+	}
+	return NotifyInfo
+}
@@ -2,11 +2,21 @@ package editor
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"sync"
 
+	"github.com/akiyosi/gonvim/fuzzy"
+	"github.com/shurcooL/github_flavored_markdown"
+	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/svg"
 	"github.com/therecipe/qt/widgets"
 )
 
+// popupDocMaxColumns is the maximum width of the documentation in columns
+const popupDocMaxColumns = 60
+
 // PopupMenu is the popupmenu
 type PopupMenu struct {
 	ws              *Workspace
@@ -25,17 +35,31 @@ type PopupMenu struct {
 	scrollCol       *widgets.QWidget
 	x               int
 	y               int
+	// prefix is the text typed before the popup menu was shown, which the
+	// matched characters of the items are highlighted for
+	prefix string
+
+	// doc is the documentation of the selected item beside the popup menu
+	doc            *widgets.QTextBrowser
+	mutex          sync.Mutex
+	kindColors     map[string]*RGBA
+	codeStyleSheet string
 }
 
 // PopupItem is
 type PopupItem struct {
+	popup      *PopupMenu
+	kindWidget *widgets.QWidget
+	kindIcon   *svg.QSvgWidget
 	kindLabel  *widgets.QLabel
 	kindText   string
+	kindSvg    string
 	detailText string
 
 	menuLabel       *widgets.QLabel
 	menuText        string
 	menuTextRequest string
+	menuMatch       []int
 
 	detailLabel       *widgets.QLabel
 	detailTextRequest string
@@ -71,9 +95,20 @@ func initPopupmenuNew(font *Font) *PopupMenu {
 	var popupItems []*PopupItem
 
 	for i := 0; i < max; i++ {
+		kindWidget := widgets.NewQWidget(nil, 0)
+		kindWidget.SetContentsMargins(8, 8, 8, 8)
+		kindLayout := widgets.NewQHBoxLayout()
+		kindLayout.SetContentsMargins(0, 0, 0, 0)
+		kindLayout.SetSpacing(0)
+		kindWidget.SetLayout(kindLayout)
+		kindIcon := svg.NewQSvgWidget(nil)
+		kindIcon.SetFixedSize2(editor.iconSize, editor.iconSize)
 		kind := widgets.NewQLabel(nil, 0)
-		kind.SetContentsMargins(8, 8, 8, 8)
+		kind.SetFixedWidth(editor.iconSize)
+		kind.SetAlignment(core.Qt__AlignCenter)
 		kind.SetFont(font.fontNew)
+		kindLayout.AddWidget(kindIcon, 0, 0)
+		kindLayout.AddWidget(kind, 0, 0)
 		menu := widgets.NewQLabel(nil, 0)
 		menu.SetContentsMargins(8, 8, 8, 8)
 		menu.SetFont(font.fontNew)
@@ -82,11 +117,13 @@ func initPopupmenuNew(font *Font) *PopupMenu {
 		detail.SetFont(font.fontNew)
 		detail.SetObjectName("detailpopup")
 
-		layout.AddWidget(kind, i, 0, 0)
+		layout.AddWidget(kindWidget, i, 0, 0)
 		layout.AddWidget(menu, i, 1, 0)
 		layout.AddWidget(detail, i, 2, 0)
 
 		popupItem := &PopupItem{
+			kindWidget:  kindWidget,
+			kindIcon:    kindIcon,
 			kindLabel:   kind,
 			menuLabel:   menu,
 			detailLabel: detail,
@@ -94,6 +131,15 @@ func initPopupmenuNew(font *Font) *PopupMenu {
 		popupItems = append(popupItems, popupItem)
 	}
 
+	doc := widgets.NewQTextBrowser(nil)
+	doc.SetOpenExternalLinks(true)
+	doc.SetFocusPolicy(core.Qt__NoFocus)
+	doc.SetFrameShape(widgets.QFrame__NoFrame)
+	doc.Document().SetDocumentMargin(8)
+	doc.SetFont(font.fontNew)
+	doc.SetHorizontalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	doc.Hide()
+
 	popup := &PopupMenu{
 		widget:    widget,
 		layout:    layout,
@@ -101,6 +147,10 @@ func initPopupmenuNew(font *Font) *PopupMenu {
 		total:     max,
 		scrollBar: scrollBar,
 		scrollCol: scrollCol,
+		doc:       doc,
+	}
+	for _, item := range popupItems {
+		item.popup = popup
 	}
 
 	shadow := widgets.NewQGraphicsDropShadowEffect(nil)
@@ -119,6 +169,35 @@ func (p *PopupMenu) updateFont(font *Font) {
 		popupItem.menuLabel.SetFont(font.fontNew)
 		popupItem.detailLabel.SetFont(font.fontNew)
 	}
+	p.doc.SetFont(font.fontNew)
+}
+
+// updateColors fetches the colors of the kinds and the code blocks in the
+// documentation from the colorscheme
+func (p *PopupMenu) updateColors() {
+	groups := map[string]string{}
+	for _, kind := range completionKinds {
+		groups[kind.hlGroup] = kind.hlGroup
+	}
+	colors := map[string]*RGBA{}
+	for group, color := range p.ws.getHighlightColors(groups) {
+		if rgba := hexToRGBA(color); rgba != nil {
+			colors[group] = rgba
+		}
+	}
+	codeStyleSheet := p.ws.hover.codeStyleSheet()
+
+	p.mutex.Lock()
+	p.kindColors = colors
+	p.codeStyleSheet = codeStyleSheet
+	p.mutex.Unlock()
+}
+
+// kindColor returns the color of the highlight group of a kind
+func (p *PopupMenu) kindColor(group string) *RGBA {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.kindColors[group]
 }
 
 func (p *PopupMenu) showItems(args []interface{}) {
@@ -130,6 +209,7 @@ func (p *PopupMenu) showItems(args []interface{}) {
 	p.rawItems = items
 	p.selected = selected
 	p.top = 0
+	p.prefix = p.typedText(row, col)
 
 	popupItems := p.items
 	itemHeight := p.ws.font.height + 20
@@ -163,11 +243,27 @@ func (p *PopupMenu) showItems(args []interface{}) {
 		p.scrollCol.Hide()
 	}
 
-	p.widget.Move2(
-		int(float64(col)*p.ws.font.truewidth)-popupItems[0].kindLabel.Width()-8,
-		(row+1)*p.ws.font.lineHeight,
-	)
+	p.x = int(float64(col)*p.ws.font.truewidth) - popupItems[0].kindWidget.Width() - 8
+	p.y = (row + 1) * p.ws.font.lineHeight
+	p.widget.Move2(p.x, p.y)
 	p.show()
+	p.updateDocumentation()
+}
+
+// typedText returns the text from the start of the completion to the cursor
+func (p *PopupMenu) typedText(row, col int) string {
+	screen := p.ws.screen
+	if screen.cursor[0] != row || row < 0 || row >= len(screen.content) {
+		return ""
+	}
+	line := screen.content[row]
+	text := ""
+	for c := col; c < screen.cursor[1] && c < len(line); c++ {
+		if c >= 0 && line[c] != nil {
+			text += line[c].char
+		}
+	}
+	return strings.TrimSpace(text)
 }
 
 func (p *PopupMenu) show() {
@@ -176,6 +272,62 @@ func (p *PopupMenu) show() {
 
 func (p *PopupMenu) hide() {
 	p.widget.Hide()
+	p.doc.Hide()
+}
+
+// updateDocumentation shows the info of the selected item rendered as
+// markdown beside the popup menu
+func (p *PopupMenu) updateDocumentation() {
+	info := ""
+	if p.selected >= 0 && p.selected < len(p.rawItems) {
+		if item, ok := p.rawItems[p.selected].([]interface{}); ok && len(item) >= 4 {
+			info, _ = item[3].(string)
+		}
+	}
+	if strings.TrimSpace(info) == "" || p.widget.IsHidden() {
+		p.doc.Hide()
+		return
+	}
+
+	p.mutex.Lock()
+	styleSheet := p.codeStyleSheet
+	p.mutex.Unlock()
+	p.doc.Document().SetDefaultStyleSheet(styleSheet)
+	p.doc.SetHtml(string(github_flavored_markdown.Markdown([]byte(info))))
+
+	screenWidth := p.ws.screen.widget.Width()
+	screenHeight := p.ws.screen.widget.Height()
+	maxWidth := popupDocMaxColumns * p.ws.font.width
+	maxHeight := screenHeight - p.y
+	if p.widget.Height() > maxHeight/2 {
+		maxHeight = p.widget.Height()
+	}
+	document := p.doc.Document()
+	document.SetTextWidth(-1)
+	width := int(math.Ceil(document.IdealWidth()))
+	if width > maxWidth {
+		width = maxWidth
+	}
+	document.SetTextWidth(float64(width))
+	height := int(math.Ceil(document.Size().Height()))
+	if height > maxHeight {
+		height = maxHeight
+		width += p.doc.VerticalScrollBar().SizeHint().Width()
+	}
+	p.doc.SetFixedSize2(width+2, height+2)
+
+	// Beside the popup menu on the right, or on the left when there is no room
+	x := p.x + p.widget.Width() + 4
+	if x+p.doc.Width() > screenWidth {
+		x = p.x - p.doc.Width() - 4
+	}
+	if x < 0 {
+		x = 0
+	}
+	p.doc.Move2(x, p.y)
+	p.doc.VerticalScrollBar().SetValue(0)
+	p.doc.Raise()
+	p.doc.Show()
 }
 
 func (p *PopupMenu) selectItem(args []interface{}) {
@@ -193,6 +345,8 @@ func (p *PopupMenu) selectItem(args []interface{}) {
 		popupItem := p.items[i]
 		popupItem.setSelected(selected == i+p.top)
 	}
+	p.selected = selected
+	p.updateDocumentation()
 }
 
 func (p *PopupMenu) scroll(n int) {
@@ -212,8 +366,17 @@ func (p *PopupMenu) scroll(n int) {
 }
 
 func (p *PopupItem) updateKind() {
-	p.kindLabel.SetStyleSheet(fmt.Sprintf("background-color: %s; color: %s;", p.kindBg.String(), p.kindColor.String()))
-	p.kindLabel.SetText(p.kindText)
+	p.kindWidget.SetStyleSheet(fmt.Sprintf("background-color: %s; color: %s;", p.kindBg.String(), p.kindColor.String()))
+	if p.kindSvg == "" {
+		p.kindLabel.SetText(p.kindText)
+		p.kindIcon.Hide()
+		p.kindLabel.Show()
+		return
+	}
+	svgContent := editor.getSvg(p.kindSvg, p.kindColor)
+	p.kindIcon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	p.kindLabel.Hide()
+	p.kindIcon.Show()
 }
 
 func (p *PopupItem) updateMenu() {
@@ -229,12 +392,24 @@ func (p *PopupItem) updateMenu() {
 	}
 	if p.menuTextRequest != p.menuText {
 		p.menuText = p.menuTextRequest
-		p.menuLabel.SetText(p.menuText)
+		p.menuLabel.SetText(formatText(p.menuText, p.menuMatch, false))
 		p.detailText = p.detailTextRequest
 		p.detailLabel.SetText(p.detailText)
 	}
 }
 
+func matchEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *PopupItem) setSelected(selected bool) {
 	p.selectedRequest = selected
 	p.updateMenu()
@@ -243,56 +418,49 @@ func (p *PopupItem) setSelected(selected bool) {
 func (p *PopupItem) setItem(item []interface{}, selected bool) {
 	text := item[0].(string)
 	kindText := item[1].(string)
-	detail := fmt.Sprintf("%s", item[2:])
+	detail, _ := item[2].(string)
 
 	p.setKind(kindText, selected)
+	match := fuzzy.MatchPositions(text, p.popup.prefix)
+	if !matchEqual(match, p.menuMatch) {
+		// Redraw the text for the new matches
+		p.menuText = ""
+	}
+	p.menuMatch = match
 	p.menuTextRequest = text
-	p.detailTextRequest = detail[1 : len(detail)-1] // cut "[" and "]"
+	p.detailTextRequest = detail
 	p.setSelected(selected)
 }
 
+// setKind shows the icon and the color of the LSP kind of the item, or the
+// first letter of the kind when it is unknown
 func (p *PopupItem) setKind(kindText string, selected bool) {
 	color := newRGBA(151, 195, 120, 1)
-	bg := newRGBA(151, 195, 120, 0.2)
-
-	switch kindText {
-	case "function", "func":
-		kindText = "f"
-		color = newRGBA(97, 174, 239, 1)
-		bg = newRGBA(97, 174, 239, 0.2)
-	case "var", "statement", "instance", "param", "import":
-		kindText = "v"
-		color = newRGBA(223, 106, 115, 1)
-		bg = newRGBA(223, 106, 115, 0.2)
-	case "const":
-		kindText = "c"
-		color = newRGBA(223, 106, 115, 1)
-		bg = newRGBA(223, 106, 115, 0.2)
-	case "class":
-		kindText = "c"
-		color = newRGBA(229, 193, 124, 1)
-		bg = newRGBA(229, 193, 124, 0.2)
-	case "type":
-		kindText = "t"
-		color = newRGBA(229, 193, 124, 1)
-		bg = newRGBA(229, 193, 124, 0.2)
-	case "module":
-		kindText = "m"
-		color = newRGBA(42, 161, 152, 1)
-		bg = newRGBA(42, 161, 152, 0.2)
-	case "keyword":
-		kindText = "k"
-		color = newRGBA(42, 161, 152, 1)
-		bg = newRGBA(42, 161, 152, 0.2)
-	case "package":
-		kindText = "p"
-		color = newRGBA(42, 161, 152, 1)
-		bg = newRGBA(42, 161, 152, 0.2)
-	default:
-		kindText = "b"
-	}
-	if kindText != p.kindText {
+	icon := ""
+	kind, override := lookupCompletionKind(kindText)
+	if kind != nil {
+		icon = kind.icon
+		if c := p.popup.kindColor(kind.hlGroup); c != nil {
+			color = c
+		}
+		kindText = ""
+	} else {
+		kindText = completionKindLetter(kindText)
+	}
+	if override != nil {
+		if override.Icon != "" {
+			icon = override.Icon
+		}
+		if c := hexToRGBA(override.Color); c != nil {
+			color = c
+		}
+	}
+	bg := color.copy()
+	bg.A = 0.2
+
+	if kindText != p.kindText || icon != p.kindSvg || p.kindColor == nil || !color.equals(p.kindColor) {
 		p.kindText = kindText
+		p.kindSvg = icon
 		p.kindColor = color
 		p.kindBg = bg
 		p.updateKind()
@@ -304,7 +472,7 @@ func (p *PopupItem) hide() {
 		return
 	}
 	p.hidden = true
-	p.kindLabel.Hide()
+	p.kindWidget.Hide()
 	p.menuLabel.Hide()
 	p.detailLabel.Hide()
 }
@@ -314,7 +482,7 @@ func (p *PopupItem) show() {
 		return
 	}
 	p.hidden = false
-	p.kindLabel.Show()
+	p.kindWidget.Show()
 	p.menuLabel.Show()
 	p.detailLabel.Show()
 }
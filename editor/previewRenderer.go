@@ -0,0 +1,266 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// previewCommandTimeout is the time limit of the external converters
+	previewCommandTimeout = 10 * time.Second
+	// previewCommandDelay debounces running the external converters while
+	// typing
+	previewCommandDelay = 150 * time.Millisecond
+)
+
+// errPreviewCanceled is the error of the renders superseded by newer ones
+var errPreviewCanceled = errors.New("canceled by a newer render")
+
+var (
+	// previewBodyRe matches the body of a standalone html document
+	previewBodyRe = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	// previewHeadStyleRe matches the style sheets in the head of the document
+	previewHeadStyleRe = regexp.MustCompile(`(?is)<style[^>]*>.*?</style>`)
+	// helpTagRe matches the tags like *tag* in the help files
+	helpTagRe = regexp.MustCompile(`\*([^\s*|]+)\*`)
+	// helpLinkRe matches the links like |tag| in the help files
+	helpLinkRe = regexp.MustCompile(`\|([^\s|]+)\|`)
+)
+
+// previewRendererStyle styles the previews of the filetypes other than
+// markdown
+const previewRendererStyle = `
+.markdown-body .gonvim-preview-frame {
+  width: 100%;
+  height: calc(100vh - 32px);
+  border: 0;
+  background: #fff;
+}
+
+.markdown-body .gonvim-preview-image {
+  text-align: center;
+}
+
+.markdown-body .gonvim-preview-image img {
+  max-width: 100%;
+}
+
+.markdown-body .gonvim-help {
+  background: transparent;
+  font-size: 13px;
+}
+
+.markdown-body .gonvim-help .help-tag {
+  color: #d73a49;
+}
+
+.markdown-body .gonvim-help .help-heading {
+  font-weight: 600;
+}
+
+.markdown-body .gonvim-preview-error {
+  color: #cb2431;
+  white-space: pre-wrap;
+}
+`
+
+// PreviewRenderer renders the source of a filetype to the html of the
+// preview. The path is the one of the buffer, which may be empty.
+type PreviewRenderer interface {
+	render(lines []string, path string) (string, error)
+}
+
+// markdownRenderer renders markdown with the source lines of the blocks for
+// the scroll sync
+type markdownRenderer struct{}
+
+func (r *markdownRenderer) render(lines []string, path string) (string, error) {
+	return renderMarkdownBlocks(lines), nil
+}
+
+// htmlRenderer shows a html file in a frame. The frame is sandboxed without
+// scripts, which could reach the web channel of the preview otherwise.
+type htmlRenderer struct{}
+
+func (r *htmlRenderer) render(lines []string, path string) (string, error) {
+	return fmt.Sprintf("<iframe class=\"gonvim-preview-frame\" sandbox=\"allow-same-origin\" srcdoc=\"%s\"></iframe>", html.EscapeString(strings.Join(lines, "\n"))), nil
+}
+
+// svgRenderer shows a svg image
+type svgRenderer struct{}
+
+func (r *svgRenderer) render(lines []string, path string) (string, error) {
+	return previewSvgImage(strings.Join(lines, "\n")), nil
+}
+
+// helpRenderer renders the help files of Vim with the tags, the links to
+// them and the headings. It is used only for the plugins without README, not
+// for every help buffer.
+type helpRenderer struct{}
+
+func (r *helpRenderer) render(lines []string, path string) (string, error) {
+	out := []string{}
+	for _, line := range lines {
+		heading := strings.HasSuffix(line, " ~")
+		if heading {
+			line = strings.TrimSuffix(line, " ~")
+		}
+		line = html.EscapeString(line)
+		line = helpTagRe.ReplaceAllString(line, `<span class="help-tag" id="$1">$1</span>`)
+		line = helpLinkRe.ReplaceAllString(line, `<a href="#$1">$1</a>`)
+		if heading {
+			line = `<span class="help-heading">` + line + `</span>`
+		}
+		out = append(out, line)
+	}
+	return fmt.Sprintf("<pre class=\"gonvim-help\">%s</pre>", strings.Join(out, "\n")), nil
+}
+
+// commandRenderer pipes the source to an external converter, of which output
+// is html, svg or text. A render cancels the one in progress, of which
+// converter is killed.
+type commandRenderer struct {
+	args   []string
+	output string
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (r *commandRenderer) render(lines []string, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), previewCommandTimeout)
+	defer cancel()
+	r.mutex.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.cancel = cancel
+	r.mutex.Unlock()
+
+	// Wait for the edits to settle
+	select {
+	case <-ctx.Done():
+		return "", errPreviewCanceled
+	case <-time.After(previewCommandDelay):
+	}
+
+	cmd := exec.CommandContext(ctx, r.args[0], r.args[1:]...)
+	if filepath.IsAbs(path) {
+		// Resolve the included files from the directory of the buffer
+		cmd.Dir = filepath.Dir(path)
+	}
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return "", errPreviewCanceled
+		}
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("%s: %s\n%s", r.args[0], err, stderr.String())
+		}
+		return "", fmt.Errorf("%s: %s", r.args[0], err)
+	}
+	switch r.output {
+	case "svg":
+		return previewSvgImage(string(out)), nil
+	case "text":
+		return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(string(out))), nil
+	default:
+		return previewHTMLBody(string(out)), nil
+	}
+}
+
+// previewRenderers returns the renderers keyed by filetype, the built-in
+// ones overridden by the converters in the config
+func previewRenderers() map[string]PreviewRenderer {
+	renderers := map[string]PreviewRenderer{
+		"markdown": &markdownRenderer{},
+		"html":     &htmlRenderer{},
+		"svg":      &svgRenderer{},
+		"rst": &commandRenderer{
+			args: []string{"rst2html5", "--no-doc-title"},
+		},
+		"asciidoc": &commandRenderer{
+			args: []string{"asciidoctor", "--embedded", "--out-file", "-", "-"},
+		},
+		"dot": &commandRenderer{
+			args:   []string{"dot", "-Tsvg"},
+			output: "svg",
+		},
+	}
+	for filetype, converter := range editor.config.Preview.Renderers {
+		args := strings.Fields(converter.Command)
+		if len(args) == 0 {
+			continue
+		}
+		renderers[filetype] = &commandRenderer{
+			args:   args,
+			output: strings.ToLower(converter.Output),
+		}
+	}
+	return renderers
+}
+
+// previewFiletypes returns the filetypes which have the renderers
+func previewFiletypes() []string {
+	filetypes := []string{}
+	for filetype := range previewRenderers() {
+		filetypes = append(filetypes, filetype)
+	}
+	sort.Strings(filetypes)
+	return filetypes
+}
+
+// previewAutoCmds returns the autocmds updating the preview of the buffers
+// of the filetypes which have the renderers
+func previewAutoCmds() string {
+	quoted := []string{}
+	for _, filetype := range previewFiletypes() {
+		if strings.ContainsAny(filetype, "\r\n") {
+			continue
+		}
+		quoted = append(quoted, vimStringLiteral(filetype))
+	}
+	// Single quotes are doubled since the lines are quoted in splitVimscript
+	filetypes := strings.Replace(strings.Join(quoted, ","), "'", "''", -1)
+	return fmt.Sprintf(`
+	aug GonvimAuMd | au! | aug END
+	au GonvimAuMd TextChanged,TextChangedI * if index([%s], &filetype) >= 0 | call rpcnotify(0, "Gui", "gonvim_markdown_update") | endif
+	au GonvimAuMd BufEnter * if index([%s], &filetype) >= 0 | call rpcnotify(0, "Gui", "gonvim_markdown_new_buffer") | endif
+	`, filetypes, filetypes)
+}
+
+// previewSvgImage returns the svg as an image, which diffDOM can update
+// unlike inline svg elements
+func previewSvgImage(svg string) string {
+	return fmt.Sprintf("<div class=\"gonvim-preview-image\"><img src=\"data:image/svg+xml;base64,%s\"></div>", base64.StdEncoding.EncodeToString([]byte(svg)))
+}
+
+// previewHTMLBody returns the body of the output of a converter with the
+// style sheets of the head, or the output itself if it is a fragment
+func previewHTMLBody(output string) string {
+	loc := previewBodyRe.FindStringSubmatchIndex(output)
+	if loc == nil {
+		return output
+	}
+	head := output[:loc[0]]
+	return strings.Join(previewHeadStyleRe.FindAllString(head, -1), "\n") + output[loc[2]:loc[3]]
+}
+
+// previewError returns the error of a renderer shown in the preview
+func previewError(filetype string, err error) string {
+	return fmt.Sprintf("<pre class=\"gonvim-preview-error\">[Gonvim] Failed to render the %s preview: %s</pre>", html.EscapeString(filetype), html.EscapeString(err.Error()))
+}
@@ -0,0 +1,385 @@
+package editor
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/svg"
+	"github.com/therecipe/qt/widgets"
+)
+
+// maxProblemItems limits the number of the diagnostics drawn in ProblemsSide
+const maxProblemItems = 1000
+
+// ProblemsSide is the side bar listing the diagnostics of the active workspace
+type ProblemsSide struct {
+	widget     *widgets.QWidget
+	layout     *widgets.QVBoxLayout
+	scrollarea *widgets.QScrollArea
+	filter     *widgets.QLineEdit
+	severities [4]*ProblemsSeverity
+	list       *widgets.QWidget
+	listLayout *widgets.QVBoxLayout
+	empty      *widgets.QLabel
+	rows       []*ProblemsRow
+}
+
+// ProblemsRow is a row of ProblemsSide showing either the header of a file or
+// a diagnostic in it. The rows are reused across the updates, and the ones
+// not needed are hidden.
+type ProblemsRow struct {
+	widget     *widgets.QWidget
+	layout     *widgets.QHBoxLayout
+	icon       *svg.QSvgWidget
+	label      *widgets.QLabel
+	severity   int
+	diagnostic *Diagnostic // nil for the file header
+}
+
+// ProblemsSeverity is the toggle button filtering a severity in ProblemsSide
+type ProblemsSeverity struct {
+	widget   *widgets.QWidget
+	icon     *svg.QSvgWidget
+	label    *widgets.QLabel
+	severity int
+	hidden   bool
+}
+
+func newProblemsSide() *ProblemsSide {
+	fg := editor.fgcolor
+	bg := editor.bgcolor
+
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(0)
+	widget := widgets.NewQWidget(nil, 0)
+	widget.SetContentsMargins(0, 0, 0, 0)
+	widget.SetLayout(layout)
+
+	headerWidget := widgets.NewQWidget(nil, 0)
+	headerLayout := widgets.NewQHBoxLayout()
+	headerLayout.SetContentsMargins(20, 15, 20, 5)
+	headerLayout.SetSpacing(2)
+	header := widgets.NewQLabel(nil, 0)
+	header.SetContentsMargins(0, 0, 0, 0)
+	header.SetText("PROBLEMS")
+	header.SetStyleSheet(fmt.Sprintf(" .QLabel{ color: %s;} ", fg.print()))
+	header.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	headerLayout.AddWidget(header, 1, 0)
+	headerWidget.SetLayout(headerLayout)
+
+	side := &ProblemsSide{
+		widget: widget,
+		layout: layout,
+	}
+
+	for i := range side.severities {
+		severity := side.newSeverity(i + 1)
+		headerLayout.AddWidget(severity.widget, 0, 0)
+		side.severities[i] = severity
+	}
+
+	filterWidget := widgets.NewQWidget(nil, 0)
+	filterLayout := widgets.NewQHBoxLayout()
+	filterLayout.SetContentsMargins(20, 5, 20, 5)
+	filterWidget.SetLayout(filterLayout)
+	filter := widgets.NewQLineEdit(nil)
+	filter.SetPlaceholderText("Filter")
+	filter.SetMinimumHeight(editor.config.Editor.FontSize + 3)
+	filter.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	filter.SetFocusPolicy(core.Qt__ClickFocus)
+	filter.SetStyleSheet(fmt.Sprintf(".QLineEdit { border: 1px solid %s; border-radius: 1px; background: rgba(%d, %d, %d, 1); selection-background-color: rgba(%d, %d, %d, 1); }", editor.config.SideBar.AccentColor, bg.R, bg.G, bg.B, gradColor(bg).R, gradColor(bg).G, gradColor(bg).B))
+	filter.ConnectTextChanged(func(text string) {
+		side.update()
+	})
+	filterLayout.AddWidget(filter, 0, 0)
+	side.filter = filter
+
+	empty := widgets.NewQLabel(nil, 0)
+	empty.SetContentsMargins(20, 10, 20, 10)
+	empty.SetWordWrap(true)
+	empty.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	side.empty = empty
+
+	list := widgets.NewQWidget(nil, 0)
+	listLayout := widgets.NewQVBoxLayout()
+	listLayout.SetContentsMargins(0, 0, 0, 0)
+	listLayout.SetSpacing(0)
+	list.SetLayout(listLayout)
+	side.list = list
+	side.listLayout = listLayout
+
+	layout.AddWidget(headerWidget, 0, 0)
+	layout.AddWidget(filterWidget, 0, 0)
+	layout.AddWidget(empty, 0, 0)
+	layout.AddWidget(list, 0, 0)
+	layout.AddStretch(1)
+
+	sideStyle := fmt.Sprintf("QWidget { color: rgba(%d, %d, %d, 1); border-right: 0px solid; }", gradColor(fg).R, gradColor(fg).G, gradColor(fg).B)
+	side.widget.SetStyleSheet(fmt.Sprintf(".QWidget { padding-top: 5px; background-color: rgba(%d, %d, %d, 1); } ", shiftColor(bg, -5).R, shiftColor(bg, -5).G, shiftColor(bg, -5).B) + sideStyle)
+
+	sideArea := widgets.NewQScrollArea(nil)
+	sideArea.SetWidgetResizable(true)
+	sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	sideArea.SetFocusPolicy(core.Qt__ClickFocus)
+	sideArea.SetWidget(widget)
+	sideArea.SetFrameShape(widgets.QFrame__NoFrame)
+	sideArea.ConnectEnterEvent(func(event *core.QEvent) {
+		sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAsNeeded)
+	})
+	sideArea.ConnectLeaveEvent(func(event *core.QEvent) {
+		sideArea.SetVerticalScrollBarPolicy(core.Qt__ScrollBarAlwaysOff)
+	})
+	sideArea.SetStyleSheet(fmt.Sprintf(".QScrollBar { border-width: 0px; background-color: %s; width: 5px; margin: 0 0 0 0; } .QScrollBar::handle:vertical {background-color: %s; min-height: 25px;} .QScrollBar::handle:vertical:hover {background-color: %s; min-height: 25px;} .QScrollBar::add-line:vertical, .QScrollBar::sub-line:vertical { border: none; background: none; } .QScrollBar::add-page:vertical, QScrollBar::sub-page:vertical { background: none; }", shiftColor(bg, -5).print(), gradColor(bg).print(), editor.config.SideBar.AccentColor))
+	side.scrollarea = sideArea
+
+	return side
+}
+
+func (side *ProblemsSide) newSeverity(severity int) *ProblemsSeverity {
+	widget := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQHBoxLayout()
+	layout.SetContentsMargins(4, 0, 0, 0)
+	layout.SetSpacing(2)
+	widget.SetLayout(layout)
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedSize2(editor.iconSize-1, editor.iconSize-1)
+	label := widgets.NewQLabel(nil, 0)
+	label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	layout.AddWidget(icon, 0, 0)
+	layout.AddWidget(label, 0, 0)
+
+	s := &ProblemsSeverity{
+		widget:   widget,
+		icon:     icon,
+		label:    label,
+		severity: severity,
+	}
+	s.setIcon()
+
+	switch severity {
+	case SeverityError:
+		widget.SetToolTip("Show Errors")
+	case SeverityWarn:
+		widget.SetToolTip("Show Warnings")
+	case SeverityInfo:
+		widget.SetToolTip("Show Infos")
+	case SeverityHint:
+		widget.SetToolTip("Show Hints")
+	}
+	widget.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		s.hidden = !s.hidden
+		s.setIcon()
+		side.update()
+	})
+	widget.ConnectEnterEvent(func(event *core.QEvent) {
+		cursor := gui.NewQCursor()
+		cursor.SetShape(core.Qt__PointingHandCursor)
+		gui.QGuiApplication_SetOverrideCursor(cursor)
+	})
+	widget.ConnectLeaveEvent(func(event *core.QEvent) {
+		gui.QGuiApplication_RestoreOverrideCursor()
+	})
+
+	return s
+}
+
+// setIcon draws the icon, dimmed when the severity is filtered out
+func (s *ProblemsSeverity) setIcon() {
+	icon, color := diagnosticIcon(s.severity)
+	if s.hidden {
+		color = gradColor(editor.bgcolor)
+	}
+	svgContent := editor.getSvg(icon, color)
+	s.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+}
+
+// update redraws the diagnostics of the active workspace
+func (side *ProblemsSide) update() {
+	ws := editor.workspaces[editor.active]
+	diagnostics := []*Diagnostic{}
+	if ws.loc != nil {
+		diagnostics = ws.loc.getDiagnostics()
+	}
+
+	counts := [4]int{}
+	for _, d := range diagnostics {
+		if d.severity >= SeverityError && d.severity <= SeverityHint {
+			counts[d.severity-1]++
+		}
+	}
+	for i, severity := range side.severities {
+		severity.label.SetText(fmt.Sprintf("%d", counts[i]))
+	}
+
+	used := 0
+	nextRow := func() *ProblemsRow {
+		if used == len(side.rows) {
+			row := side.newRow()
+			side.listLayout.AddWidget(row.widget, 0, 0)
+			side.rows = append(side.rows, row)
+		}
+		row := side.rows[used]
+		used++
+		row.widget.Show()
+		return row
+	}
+
+	query := strings.ToLower(strings.TrimSpace(side.filter.Text()))
+	var fileRow *ProblemsRow
+	file := ""
+	fileCount := 0
+	bufnr := -1
+	shown := 0
+	for _, d := range diagnostics {
+		if !side.match(d, query) {
+			continue
+		}
+		if shown >= maxProblemItems {
+			break
+		}
+		shown++
+		if d.bufnr != bufnr || fileRow == nil {
+			if fileRow != nil {
+				fileRow.setFile(file, ws.cwd, fileCount)
+			}
+			bufnr = d.bufnr
+			file = d.file
+			fileCount = 0
+			fileRow = nextRow()
+		}
+		fileCount++
+		nextRow().setDiagnostic(d)
+	}
+	if fileRow != nil {
+		fileRow.setFile(file, ws.cwd, fileCount)
+	}
+	for _, row := range side.rows[used:] {
+		row.widget.Hide()
+	}
+
+	switch {
+	case len(diagnostics) == 0:
+		side.empty.SetText("No problems have been detected in the workspace.")
+		side.empty.Show()
+	case shown == 0:
+		side.empty.SetText("No results found. Review the filters.")
+		side.empty.Show()
+	default:
+		side.empty.Hide()
+	}
+}
+
+// match reports whether the diagnostic passes the severity and text filters
+func (side *ProblemsSide) match(d *Diagnostic, query string) bool {
+	if d.severity >= SeverityError && d.severity <= SeverityHint && side.severities[d.severity-1].hidden {
+		return false
+	}
+	if query == "" {
+		return true
+	}
+	for _, text := range []string{d.message, d.source, d.code, d.file} {
+		if strings.Contains(strings.ToLower(text), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (side *ProblemsSide) newRow() *ProblemsRow {
+	widget := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQHBoxLayout()
+	layout.SetSpacing(6)
+	widget.SetLayout(layout)
+
+	icon := svg.NewQSvgWidget(nil)
+	icon.SetFixedSize2(editor.iconSize-1, editor.iconSize-1)
+
+	label := widgets.NewQLabel(nil, 0)
+	label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	label.SetSizePolicy2(widgets.QSizePolicy__Ignored, widgets.QSizePolicy__Preferred)
+
+	layout.AddWidget(icon, 0, 0)
+	layout.AddWidget(label, 1, 0)
+	layout.SetAlignment(icon, core.Qt__AlignTop)
+
+	row := &ProblemsRow{
+		widget: widget,
+		layout: layout,
+		icon:   icon,
+		label:  label,
+	}
+	widget.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		d := row.diagnostic
+		if d == nil {
+			return
+		}
+		// Go to the window showing the buffer if any, otherwise open the
+		// buffer hiding the current one, which may be modified
+		go editor.workspaces[editor.active].nvim.Command(fmt.Sprintf("if bufwinid(%d) != -1 | call win_gotoid(bufwinid(%d)) | else | buffer! %d | endif | call cursor(%d, %d)", d.bufnr, d.bufnr, d.bufnr, d.lnum, d.col))
+	})
+	widget.ConnectEnterEvent(func(event *core.QEvent) {
+		if row.diagnostic == nil {
+			return
+		}
+		widget.SetStyleSheet(fmt.Sprintf(" .QWidget { background-color: %s; } ", shiftColor(editor.bgcolor, -15).print()))
+	})
+	widget.ConnectLeaveEvent(func(event *core.QEvent) {
+		widget.SetStyleSheet("")
+	})
+
+	return row
+}
+
+// setFile shows the header of the file with the number of the diagnostics
+func (row *ProblemsRow) setFile(file, cwd string, count int) {
+	row.diagnostic = nil
+	row.widget.SetStyleSheet("")
+	row.layout.SetContentsMargins(20, 6, 20, 2)
+	row.icon.Hide()
+	row.label.SetWordWrap(false)
+
+	name := "[No Name]"
+	dir := ""
+	if file != "" {
+		name = filepath.Base(file)
+		dir = filepath.Dir(file)
+		if rel, err := filepath.Rel(cwd, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			dir = rel
+		}
+		if dir == "." {
+			dir = ""
+		}
+	}
+	text := fmt.Sprintf("<b>%s</b>", html.EscapeString(name))
+	if dir != "" {
+		text += fmt.Sprintf(" <font color='%s'>%s</font>", gradColor(editor.bgcolor).print(), html.EscapeString(dir))
+	}
+	text += fmt.Sprintf("  %d", count)
+	row.label.SetText(text)
+	row.label.SetToolTip(file)
+}
+
+// setDiagnostic shows the diagnostic with the icon of the severity
+func (row *ProblemsRow) setDiagnostic(d *Diagnostic) {
+	row.diagnostic = d
+	row.layout.SetContentsMargins(28, 2, 20, 2)
+	if row.severity != d.severity {
+		row.severity = d.severity
+		name, color := diagnosticIcon(d.severity)
+		svgContent := editor.getSvg(name, color)
+		row.icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
+	}
+	row.icon.Show()
+	row.label.SetWordWrap(true)
+
+	text := html.EscapeString(d.message)
+	detail := strings.TrimSpace(fmt.Sprintf("%s (%d, %d)", diagnosticDetail(d), d.lnum, d.col))
+	text += fmt.Sprintf(" <font color='%s'>%s</font>", gradColor(editor.bgcolor).print(), html.EscapeString(detail))
+	row.label.SetText(text)
+	row.label.SetToolTip("")
+}
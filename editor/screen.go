@@ -50,6 +50,10 @@ type Screen struct {
 	redrawMutex      sync.Mutex
 	drawSplit        bool
 	tooltip          *widgets.QLabel
+	// hlAttrs are the highlight attributes of ext_linegrid by their ids
+	hlAttrs map[int]map[string]interface{}
+	// highlights caches the highlights of hlAttrs with the default colors
+	highlights map[int]Highlight
 }
 
 func newScreen() *Screen {
@@ -67,6 +71,8 @@ func newScreen() *Screen {
 		lastCursor:   [2]int{0, 0},
 		scrollRegion: []int{0, 0, 0, 0},
 		tooltip:      tooltip,
+		hlAttrs:      make(map[int]map[string]interface{}),
+		highlights:   make(map[int]Highlight),
 	}
 
 	widget.ConnectPaintEvent(screen.paint)
@@ -689,6 +695,124 @@ func (s *Screen) highlightSet(args []interface{}) {
 	}
 }
 
+// hlAttrDefine stores the highlight attributes of ext_linegrid
+func (s *Screen) hlAttrDefine(args []interface{}) {
+	for _, arg := range args {
+		a, ok := arg.([]interface{})
+		if !ok || len(a) < 2 {
+			continue
+		}
+		id := reflectToInt(a[0])
+		attrs, _ := a[1].(map[string]interface{})
+		s.hlAttrs[id] = attrs
+		delete(s.highlights, id)
+	}
+}
+
+// resetHighlights drops the cached highlights for the new default colors
+func (s *Screen) resetHighlights() {
+	s.highlights = make(map[int]Highlight)
+}
+
+// getHighlight returns the highlight of the id of ext_linegrid
+func (s *Screen) getHighlight(id int) Highlight {
+	if highlight, ok := s.highlights[id]; ok {
+		return highlight
+	}
+	attrs := s.hlAttrs[id]
+	highlight := Highlight{
+		foreground: s.ws.foreground,
+		background: s.ws.background,
+		bold:       isTrue(attrs["bold"]),
+		italic:     isTrue(attrs["italic"]),
+	}
+	if fg, ok := attrs["foreground"]; ok {
+		highlight.foreground = calcColor(reflectToInt(fg))
+	}
+	if bg, ok := attrs["background"]; ok {
+		highlight.background = calcColor(reflectToInt(bg))
+	}
+	if isTrue(attrs["reverse"]) {
+		highlight.foreground, highlight.background = highlight.background, highlight.foreground
+	}
+	s.highlights[id] = highlight
+	return highlight
+}
+
+// chunkAttrs returns the highlight attributes of a chunk of the cmdline and
+// the messages, which are the attributes themselves, or the id of them with
+// ext_linegrid
+func (s *Screen) chunkAttrs(attrs interface{}) map[string]interface{} {
+	switch a := attrs.(type) {
+	case map[string]interface{}:
+		return a
+	case int64, uint64:
+		return s.hlAttrs[reflectToInt(a)]
+	}
+	return nil
+}
+
+// gridLine draws the cells of grid_line with put, keeping the cursor
+func (s *Screen) gridLine(args []interface{}) {
+	cursor := s.cursor
+	for _, arg := range args {
+		a, ok := arg.([]interface{})
+		if !ok || len(a) < 4 {
+			continue
+		}
+		s.cursor[0] = reflectToInt(a[1])
+		s.cursor[1] = reflectToInt(a[2])
+		cells, _ := a[3].([]interface{})
+		for _, c := range cells {
+			cell, ok := c.([]interface{})
+			if !ok || len(cell) < 1 {
+				continue
+			}
+			text, _ := cell[0].(string)
+			// The highlight is omitted when it is the same as the previous cell
+			if len(cell) >= 2 {
+				s.highlight = s.getHighlight(reflectToInt(cell[1]))
+			}
+			repeat := 1
+			if len(cell) >= 3 {
+				repeat = reflectToInt(cell[2])
+			}
+			chars := make([]interface{}, repeat)
+			for i := range chars {
+				chars[i] = text
+			}
+			s.put([]interface{}{chars})
+		}
+	}
+	s.cursor = cursor
+}
+
+// gridCursorGoto moves the cursor with grid_cursor_goto
+func (s *Screen) gridCursorGoto(args []interface{}) {
+	a, ok := args[len(args)-1].([]interface{})
+	if !ok || len(a) < 3 {
+		return
+	}
+	s.cursor[0] = reflectToInt(a[1])
+	s.cursor[1] = reflectToInt(a[2])
+}
+
+// gridScroll scrolls the region of grid_scroll, of which bot and right are
+// exclusive unlike set_scroll_region
+func (s *Screen) gridScroll(args []interface{}) {
+	for _, arg := range args {
+		a, ok := arg.([]interface{})
+		if !ok || len(a) < 6 {
+			continue
+		}
+		s.scrollRegion[0] = reflectToInt(a[1])
+		s.scrollRegion[1] = reflectToInt(a[2]) - 1
+		s.scrollRegion[2] = reflectToInt(a[3])
+		s.scrollRegion[3] = reflectToInt(a[4]) - 1
+		s.scroll([]interface{}{[]interface{}{a[5]}})
+	}
+}
+
 func (s *Screen) setScrollRegion(args []interface{}) {
 	arg := args[0].([]interface{})
 	top := reflectToInt(arg[0])
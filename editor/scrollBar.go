@@ -1,6 +1,9 @@
 package editor
 
 import (
+	"fmt"
+
+	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/widgets"
 )
 
@@ -25,10 +28,51 @@ func newScrollBar() *ScrollBar {
 		thumb:  thumb,
 	}
 	scrollBar.widget.Hide()
+	scrollBar.widget.ConnectPaintEvent(scrollBar.paint)
+	scrollBar.widget.ConnectMousePressEvent(scrollBar.mouseEvent)
 
 	return scrollBar
 }
 
+// markerRect returns the y and the height of the marker of the hunk
+func (s *ScrollBar) markerRect(hunk *GitHunk) (int, int) {
+	height := s.widget.Height()
+	y := int(float64(hunk.start-1) / float64(s.ws.maxLine) * float64(height))
+	h := int(float64(hunk.count) / float64(s.ws.maxLine) * float64(height))
+	if h < 2 {
+		h = 2
+	}
+	return y, h
+}
+
+// paint draws the git change markers on the right half of the scrollbar
+func (s *ScrollBar) paint(event *gui.QPaintEvent) {
+	s.widget.PaintEventDefault(event)
+	if s.ws == nil || s.ws.maxLine == 0 || len(s.ws.gitHunks) == 0 {
+		return
+	}
+	p := gui.NewQPainter2(s.widget)
+	for _, hunk := range s.ws.gitHunks {
+		y, h := s.markerRect(hunk)
+		p.FillRect5(s.thumb.Width(), y, s.widget.Width()-s.thumb.Width(), h, gitHunkColor(hunk.kind).QColor())
+	}
+	p.DestroyQPainter()
+}
+
+// mouseEvent jumps to the hunk of the clicked marker
+func (s *ScrollBar) mouseEvent(event *gui.QMouseEvent) {
+	if s.ws == nil || s.ws.maxLine == 0 {
+		return
+	}
+	for _, hunk := range s.ws.gitHunks {
+		y, h := s.markerRect(hunk)
+		if y-2 <= event.Y() && event.Y() <= y+h+2 {
+			go s.ws.nvim.Command(fmt.Sprintf("%d", hunk.start))
+			return
+		}
+	}
+}
+
 func (s *ScrollBar) update() {
 	top := s.ws.screen.scrollRegion[0]
 	bot := s.ws.screen.scrollRegion[1]
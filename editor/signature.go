@@ -2,26 +2,75 @@ package editor
 
 import (
 	"fmt"
+	"html"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
+	"github.com/shurcooL/github_flavored_markdown"
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/svg"
 	"github.com/therecipe/qt/widgets"
 )
 
-// Signature is
+// signatureMaxColumns is the maximum width of the documentation in columns
+const signatureMaxColumns = 80
+
+// signatureHelpLua overrides the textDocument/signatureHelp handler of the
+// built-in LSP client to show the signatures in the GUI
+const signatureHelpLua = `(function()
+  if vim.lsp == nil or vim.lsp.handlers == nil then
+    return
+  end
+  vim.lsp.handlers['textDocument/signatureHelp'] = function(...)
+    local args = {...}
+    -- The handlers take (err, method, result, ...) until nvim 0.5.1
+    local result = args[2]
+    if type(result) == 'string' then
+      result = args[3]
+    end
+    if not (result and result.signatures and #result.signatures > 0) then
+      vim.fn.rpcnotify(0, 'Gui', 'signature_hide')
+      return
+    end
+    vim.g.gonvim_signature_shown = 1
+    vim.fn.rpcnotify(0, 'Gui', 'signature_show', result)
+  end
+end)()`
+
+// Signature is the popup of the signature help
 type Signature struct {
-	ws            *Workspace
-	cusor         []int
-	comma         int
-	formattedText string
-	text          string
-	widget        *widgets.QWidget
-	label         *widgets.QLabel
-	height        int
-	x             int
-	y             int
+	ws         *Workspace
+	cusor      []int
+	widget     *widgets.QWidget
+	counter    *widgets.QLabel
+	label      *widgets.QLabel
+	paramDoc   *widgets.QLabel
+	doc        *widgets.QLabel
+	signatures []*SignatureInfo
+	active     int
+	// activeParam is the active parameter of the signature help, used for
+	// the signatures without their own active parameter
+	activeParam int
+	x           int
+	y           int
+}
+
+// SignatureInfo is a signature of the signature help
+type SignatureInfo struct {
+	label       string
+	doc         string
+	params      []*SignatureParam
+	activeParam int // -1 when the signature doesn't have its own
+}
+
+// SignatureParam is a parameter of a signature, with the byte range of its
+// label in the label of the signature
+type SignatureParam struct {
+	start int
+	end   int
+	doc   string
 }
 
 func initSignature() *Signature {
@@ -29,6 +78,7 @@ func initSignature() *Signature {
 	widget.SetContentsMargins(8, 8, 8, 8)
 	layout := widgets.NewQVBoxLayout()
 	layout.SetContentsMargins(0, 0, 0, 0)
+	layout.SetSpacing(4)
 
 	icon := svg.NewQSvgWidget(nil)
 	icon.SetFixedWidth(editor.iconSize)
@@ -37,72 +87,195 @@ func initSignature() *Signature {
 	svgContent := editor.getSvg("flag", hexToRGBA(editor.config.SideBar.AccentColor))
 	icon.Load2(core.NewQByteArray2(svgContent, len(svgContent)))
 
+	font := gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false)
+	counter := widgets.NewQLabel(nil, 0)
+	counter.SetFont(font)
+	cursor := gui.NewQCursor()
+	cursor.SetShape(core.Qt__PointingHandCursor)
+	counter.SetCursor(cursor)
+	counter.SetToolTip("Next signature")
+	counter.Hide()
+
+	header := widgets.NewQHBoxLayout()
+	header.SetContentsMargins(0, 0, 0, 0)
+	header.SetSpacing(6)
+	header.AddWidget(icon, 0, 0)
+	header.AddWidget(counter, 0, 0)
+	header.AddStretch(1)
+
 	label := widgets.NewQLabel(nil, 0)
-	label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize-1, 1, false))
+	label.SetFont(font)
+	label.SetTextFormat(core.Qt__RichText)
+
+	paramDoc := widgets.NewQLabel(nil, 0)
+	paramDoc.SetFont(font)
+	paramDoc.SetWordWrap(true)
+	paramDoc.SetTextFormat(core.Qt__RichText)
 
-	layout.AddWidget(icon, 0, 0)
+	doc := widgets.NewQLabel(nil, 0)
+	doc.SetFont(font)
+	doc.SetWordWrap(true)
+	doc.SetTextFormat(core.Qt__RichText)
+
+	layout.AddLayout(header, 0)
 	layout.AddWidget(label, 0, 0)
+	layout.AddWidget(paramDoc, 0, 0)
+	layout.AddWidget(doc, 0, 0)
 
 	widget.SetLayout(layout)
 	signature := &Signature{
-		cusor:  []int{0, 0},
-		widget: widget,
-		label:  label,
-		height: widget.SizeHint().Height(),
+		cusor:    []int{0, 0},
+		widget:   widget,
+		counter:  counter,
+		label:    label,
+		paramDoc: paramDoc,
+		doc:      doc,
 	}
+	counter.ConnectMousePressEvent(func(*gui.QMouseEvent) {
+		signature.cycle(1)
+	})
 	return signature
 }
 
+// showItem shows the signature_show event, which is either an LSP
+// SignatureHelp with an optional cursor offset, or a label with the cursor
+// offset and the index of the active parameter
 func (s *Signature) showItem(args []interface{}) {
-	text := args[0].(string)
-	s.text = text
-	cursor := args[1].([]interface{})
-	s.comma = reflectToInt(args[2])
-	s.cusor[0] = reflectToInt(cursor[0])
-	s.cusor[1] = reflectToInt(cursor[1])
+	if len(args) < 1 {
+		return
+	}
+	s.cusor[0] = 0
+	s.cusor[1] = 0
+	switch help := args[0].(type) {
+	case map[string]interface{}:
+		s.signatures, s.active, s.activeParam = parseSignatureHelp(help)
+		if len(args) >= 2 {
+			s.setCursor(args[1])
+		}
+	case string:
+		s.signatures = []*SignatureInfo{{
+			label:       help,
+			params:      splitSignatureParams(help),
+			activeParam: -1,
+		}}
+		s.active = 0
+		s.activeParam = 0
+		if len(args) >= 3 {
+			s.setCursor(args[1])
+			s.activeParam = reflectToInt(args[2])
+		}
+	default:
+		return
+	}
+	if len(s.signatures) == 0 {
+		s.hide()
+		return
+	}
 	s.update()
 	s.move()
 	s.hide()
 	s.show()
 }
 
+func (s *Signature) setCursor(arg interface{}) {
+	cursor, ok := arg.([]interface{})
+	if !ok || len(cursor) < 2 {
+		return
+	}
+	s.cusor[0] = reflectToInt(cursor[0])
+	s.cusor[1] = reflectToInt(cursor[1])
+}
+
+// pos sets the active parameter
 func (s *Signature) pos(args []interface{}) {
-	s.comma = reflectToInt(args[0])
+	if len(args) < 1 || len(s.signatures) == 0 {
+		return
+	}
+	s.activeParam = reflectToInt(args[0])
+	s.signatures[s.active].activeParam = -1
 	s.update()
-	// s.underline()
+	s.move()
+}
+
+// cycle shows the next or the previous overload
+func (s *Signature) cycle(n int) {
+	if len(s.signatures) < 2 {
+		return
+	}
+	s.active = (s.active + n + len(s.signatures)) % len(s.signatures)
+	s.update()
+	s.move()
 }
 
 func (s *Signature) update() {
-	text := s.text
-	left := strings.Index(text, "(")
-	right := strings.Index(text, ")")
-	n := 0
-	i := left + 1
-	start := i
-	for ; i < right; i++ {
-		if string(text[i]) == "," {
-			n++
-			if n > s.comma {
-				break
-			}
-			start = i
-		}
+	if s.active >= len(s.signatures) {
+		s.active = 0
+	}
+	sig := s.signatures[s.active]
+
+	if len(s.signatures) > 1 {
+		s.counter.SetText(fmt.Sprintf("%d/%d", s.active+1, len(s.signatures)))
+		s.counter.Show()
+	} else {
+		s.counter.Hide()
 	}
-	for ; start < i; start++ {
-		t := string(text[start])
-		if t == "," || t == " " {
+
+	active := sig.activeParam
+	if active < 0 {
+		active = s.activeParam
+	}
+	text := html.EscapeString(sig.label)
+	paramDoc := ""
+	if active >= 0 && active < len(sig.params) {
+		param := sig.params[active]
+		text = fmt.Sprintf("%s<font color=\"%s\" style=\"text-decoration:underline\";><b>%s</b></font>%s",
+			html.EscapeString(sig.label[:param.start]),
+			editor.config.SideBar.AccentColor,
+			html.EscapeString(sig.label[param.start:param.end]),
+			html.EscapeString(sig.label[param.end:]))
+		paramDoc = param.doc
+	}
+	s.label.SetText(text)
+
+	// The documentation wraps at signatureMaxColumns, or at the width of
+	// the label when it is wider
+	labelWidth := s.label.SizeHint().Width()
+	maxWidth := signatureMaxColumns * s.ws.font.width
+	if maxWidth < labelWidth {
+		maxWidth = labelWidth
+	}
+	for _, doc := range []struct {
+		label *widgets.QLabel
+		text  string
+	}{
+		{s.paramDoc, paramDoc},
+		{s.doc, sig.doc},
+	} {
+		if doc.text == "" {
+			doc.label.Hide()
 			continue
-		} else {
-			break
 		}
+		doc.label.SetText(doc.text)
+		doc.label.SetWordWrap(false)
+		width := doc.label.SizeHint().Width()
+		doc.label.SetWordWrap(true)
+		if width > maxWidth {
+			width = maxWidth
+		}
+		doc.label.SetFixedWidth(width)
+		doc.label.Show()
 	}
-	formattedText := fmt.Sprintf("%s<font style=\"text-decoration:underline\";>%s</font>%s", text[:start], text[start:i], text[i:])
-	s.formattedText = formattedText
-	s.label.SetText(formattedText)
+	s.widget.AdjustSize()
 }
 
+// move places the popup above the cursor so that the label of the
+// signature starts at the opening parenthesis, or below the cursor when
+// there is no room above
 func (s *Signature) move() {
-	text := s.text
+	if len(s.signatures) == 0 {
+		return
+	}
+	text := s.signatures[s.active].label
 	row := s.ws.screen.cursor[0] + s.cusor[0]
 	col := s.ws.screen.cursor[1] + s.cusor[1]
 	i := strings.Index(text, "(")
@@ -112,14 +285,193 @@ func (s *Signature) move() {
 		x -= s.ws.font.defaultFontMetrics.HorizontalAdvance(string(text[:i]), -1)
 	}
 	s.x = int(x)
-	s.y = row*s.ws.font.lineHeight - s.height
+	if maxX := s.ws.screen.widget.Width() - s.widget.Width(); s.x > maxX {
+		s.x = maxX
+	}
+	if s.x < 0 {
+		s.x = 0
+	}
+	s.y = row*s.ws.font.lineHeight - s.widget.Height()
+	if s.y < 0 {
+		s.y = (row + 1) * s.ws.font.lineHeight
+	}
 	s.widget.Move2(s.x, s.y)
 }
 
 func (s *Signature) show() {
+	s.widget.Raise()
 	s.widget.Show()
 }
 
 func (s *Signature) hide() {
 	s.widget.Hide()
 }
+
+// parseSignatureHelp returns the signatures, the active signature and the
+// active parameter of an LSP SignatureHelp
+func parseSignatureHelp(help map[string]interface{}) ([]*SignatureInfo, int, int) {
+	signatures := []*SignatureInfo{}
+	items, _ := help["signatures"].([]interface{})
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		label, _ := m["label"].(string)
+		sig := &SignatureInfo{
+			label:       label,
+			doc:         lspDocumentation(m["documentation"]),
+			activeParam: lspInt(m["activeParameter"], -1),
+		}
+		params, _ := m["parameters"].([]interface{})
+		start := strings.Index(label, "(") + 1
+		for _, p := range params {
+			pm, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			param := &SignatureParam{doc: lspDocumentation(pm["documentation"])}
+			switch l := pm["label"].(type) {
+			case string:
+				// Search after the previous parameter, for the parameter
+				// names also in the name of the function or the types
+				i := strings.Index(label[start:], l)
+				if i < 0 {
+					i = strings.Index(label, l) - start
+				}
+				if l == "" || i+start < 0 {
+					param.start, param.end = 0, 0
+				} else {
+					param.start = start + i
+					param.end = param.start + len(l)
+					start = param.end
+				}
+			case []interface{}:
+				if len(l) == 2 {
+					param.start, param.end = utf16RangeToBytes(label, reflectToInt(l[0]), reflectToInt(l[1]))
+					start = param.end
+				}
+			}
+			sig.params = append(sig.params, param)
+		}
+		signatures = append(signatures, sig)
+	}
+	active := lspInt(help["activeSignature"], 0)
+	if active < 0 || active >= len(signatures) {
+		active = 0
+	}
+	return signatures, active, lspInt(help["activeParameter"], 0)
+}
+
+// lspInt returns the integer of an optional LSP field, or def when it is
+// omitted or null
+func lspInt(v interface{}, def int) int {
+	switch v.(type) {
+	case int64, uint64:
+		return reflectToInt(v)
+	}
+	return def
+}
+
+// lspDocumentation returns the html of a string or MarkupContent documentation
+func lspDocumentation(v interface{}) string {
+	switch doc := v.(type) {
+	case string:
+		return strings.Replace(html.EscapeString(strings.TrimSpace(doc)), "\n", "<br>", -1)
+	case map[string]interface{}:
+		value, _ := doc["value"].(string)
+		if strings.TrimSpace(value) == "" {
+			return ""
+		}
+		if kind, _ := doc["kind"].(string); kind == "markdown" {
+			return strings.TrimSpace(string(github_flavored_markdown.Markdown([]byte(value))))
+		}
+		return lspDocumentation(value)
+	}
+	return ""
+}
+
+// utf16RangeToBytes converts a range of UTF-16 code units, in which LSP
+// counts the offsets in the labels, to a byte range of s
+func utf16RangeToBytes(s string, start, end int) (int, int) {
+	if start > end {
+		start, end = end, start
+	}
+	startByte, endByte := len(s), len(s)
+	units := 0
+	for i, r := range s {
+		if units == start && startByte == len(s) {
+			startByte = i
+		}
+		if units >= end {
+			endByte = i
+			break
+		}
+		if n := utf16.RuneLen(r); n > 0 {
+			units += n
+		} else {
+			units++
+		}
+	}
+	if startByte > endByte {
+		startByte = endByte
+	}
+	return startByte, endByte
+}
+
+// splitSignatureParams returns the byte ranges of the parameters of a
+// signature label. The commas inside brackets and quotes don't separate the
+// parameters, e.g. in generics, function-typed parameters and default values.
+func splitSignatureParams(label string) []*SignatureParam {
+	left := strings.Index(label, "(")
+	if left < 0 {
+		return nil
+	}
+	params := []*SignatureParam{}
+	depth := 0
+	var quote rune
+	var prev rune
+	start := left + 1
+	add := func(end int) {
+		s, e := start, end
+		for s < e && (label[s] == ' ' || label[s] == '\t') {
+			s++
+		}
+		for e > s && (label[e-1] == ' ' || label[e-1] == '\t') {
+			e--
+		}
+		if s < e {
+			params = append(params, &SignatureParam{start: s, end: e})
+		}
+	}
+	for i := left + 1; i < len(label); {
+		r, size := utf8.DecodeRuneInString(label[i:])
+		switch {
+		case quote != 0:
+			if r == quote && prev != '\\' {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '(' || r == '[' || r == '{' || r == '<':
+			depth++
+		case r == '>' && (prev == '-' || prev == '='):
+			// An arrow or a comparison, not a closing bracket
+		case r == ')' || r == ']' || r == '}' || r == '>':
+			if depth == 0 && r == ')' {
+				add(i)
+				return params
+			}
+			if depth > 0 {
+				depth--
+			}
+		case r == ',' && depth == 0:
+			add(i)
+			start = i + size
+		}
+		prev = r
+		i += size
+	}
+	add(len(label))
+	return params
+}
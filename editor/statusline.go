@@ -2,12 +2,10 @@ package editor
 
 import (
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
-	"github.com/akiyosi/gonvim/osdepend"
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/svg"
@@ -35,9 +33,17 @@ type Statusline struct {
 	encoding   *StatuslineEncoding
 	fileFormat *StatuslineFileFormat
 	lint       *StatuslineLint
+	users      map[string]*StatuslineUser
 	updates    chan []interface{}
 }
 
+// StatuslineUser is a user defined segment showing the value of a Vim expression
+type StatuslineUser struct {
+	name  string
+	text  string
+	label *widgets.QLabel
+}
+
 // StatuslineNotify
 type StatuslineNotify struct {
 	s      *Statusline
@@ -52,20 +58,27 @@ type StatuslineLint struct {
 	s          *Statusline
 	errors     int
 	warnings   int
+	infos      int
+	hints      int
 	widget     *widgets.QWidget
 	okIcon     *svg.QSvgWidget
 	errorIcon  *svg.QSvgWidget
 	warnIcon   *svg.QSvgWidget
+	infoIcon   *svg.QSvgWidget
+	hintIcon   *svg.QSvgWidget
 	okLabel    *widgets.QLabel
 	errorLabel *widgets.QLabel
 	warnLabel  *widgets.QLabel
+	infoLabel  *widgets.QLabel
+	hintLabel  *widgets.QLabel
 	svgLoaded  bool
 }
 
 // StatuslineMain is
 type StatuslineMain struct {
-	s      *Statusline
-	widget *widgets.QWidget
+	s          *Statusline
+	widget     *widgets.QWidget
+	modeWidget *widgets.QWidget
 
 	modeIcon  *svg.QSvgWidget
 	modeLabel *widgets.QLabel
@@ -106,11 +119,22 @@ type StatuslineGit struct {
 	s         *Statusline
 	branch    string
 	file      string
+	root      string
 	widget    *widgets.QWidget
 	label     *widgets.QLabel
 	icon      *svg.QSvgWidget
 	svgLoaded bool
 	hidden    bool
+	// updates are the repositories of the files found in the goroutines
+	updates chan *statuslineGitUpdate
+}
+
+// statuslineGitUpdate is the repository and its status of a file
+type statuslineGitUpdate struct {
+	file   string
+	root   string
+	status *GitStatus
+	err    error
 }
 
 // StatuslineEncoding is
@@ -129,9 +153,6 @@ func initStatuslineNew() *Statusline {
 	widget := widgets.NewQWidget(nil, 0)
 	widget.SetContentsMargins(0, 0, 6, 0)
 
-	// spacing, padding, paddingtop, rightitemnum, width
-	layout := newVFlowLayout(16, 10, 1, 1, 0)
-	widget.SetLayout(layout)
 	widget.SetObjectName("statusline")
 
 	s := &Statusline{
@@ -158,10 +179,11 @@ func initStatuslineNew() *Statusline {
 	gitWidget.SetLayout(gitLayout)
 	gitWidget.Hide()
 	git := &StatuslineGit{
-		s:      s,
-		widget: gitWidget,
-		icon:   gitIcon,
-		label:  gitLabel,
+		s:       s,
+		widget:  gitWidget,
+		icon:    gitIcon,
+		label:   gitLabel,
+		updates: make(chan *statuslineGitUpdate, 1000),
 	}
 	s.git = git
 
@@ -192,20 +214,26 @@ func initStatuslineNew() *Statusline {
 	folderLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
 	folderLabel.SetContentsMargins(0, 0, 0, 1)
 	folderLabel.Hide()
-	modeAndFileLayout := widgets.NewQHBoxLayout()
-	modeAndFileLayout.SetContentsMargins(0, 0, 0, 1)
-	modeAndFileLayout.SetSpacing(6)
-	modeAndFileLayout.AddWidget(modeLabel, 0, 0)
-	modeAndFileLayout.AddWidget(modeIcon, 0, 0)
-	modeAndFileLayout.AddWidget(folderLabel, 0, 0)
-	modeAndFileLayout.AddWidget(fileLabel, 0, 0)
-	modeAndFileWidget := widgets.NewQWidget(nil, 0)
-	modeAndFileWidget.SetLayout(modeAndFileLayout)
+	modeLayout := widgets.NewQHBoxLayout()
+	modeLayout.SetContentsMargins(0, 0, 0, 1)
+	modeLayout.SetSpacing(6)
+	modeLayout.AddWidget(modeLabel, 0, 0)
+	modeLayout.AddWidget(modeIcon, 0, 0)
+	modeWidget := widgets.NewQWidget(nil, 0)
+	modeWidget.SetLayout(modeLayout)
+	fileLayout := widgets.NewQHBoxLayout()
+	fileLayout.SetContentsMargins(0, 0, 0, 1)
+	fileLayout.SetSpacing(6)
+	fileLayout.AddWidget(folderLabel, 0, 0)
+	fileLayout.AddWidget(fileLabel, 0, 0)
+	fileWidget := widgets.NewQWidget(nil, 0)
+	fileWidget.SetLayout(fileLayout)
 	main := &StatuslineMain{
 		s:           s,
 		modeLabel:   modeLabel,
 		modeIcon:    modeIcon,
-		widget:      modeAndFileWidget,
+		widget:      fileWidget,
+		modeWidget:  modeWidget,
 		fileLabel:   fileLabel,
 		folderLabel: folderLabel,
 	}
@@ -285,12 +313,7 @@ func initStatuslineNew() *Statusline {
 		notifyWidget.SetStyleSheet("")
 	})
 	notifyWidget.ConnectMousePressEvent(func(event *gui.QMouseEvent) {
-		switch editor.displayNotifications {
-		case true:
-			editor.hideNotifications()
-		case false:
-			editor.showNotifications()
-		}
+		editor.notificationCenter.toggle()
 	})
 	// notifyWidget.ConnectMouseReleaseEvent(func(*gui.QMouseEvent) {
 	// })
@@ -314,6 +337,21 @@ func initStatuslineNew() *Statusline {
 	warnLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
 	warnLabel.SetContentsMargins(0, 0, 0, 0)
 	//warnLabel.Show()
+	// Info and hint are shown only when there are any
+	infoIcon := svg.NewQSvgWidget(nil)
+	infoIcon.SetFixedSize2(editor.iconSize, editor.iconSize)
+	infoIcon.Hide()
+	infoLabel := widgets.NewQLabel(nil, 0)
+	infoLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	infoLabel.SetContentsMargins(0, 0, 0, 0)
+	infoLabel.Hide()
+	hintIcon := svg.NewQSvgWidget(nil)
+	hintIcon.SetFixedSize2(editor.iconSize, editor.iconSize)
+	hintIcon.Hide()
+	hintLabel := widgets.NewQLabel(nil, 0)
+	hintLabel.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+	hintLabel.SetContentsMargins(0, 0, 0, 0)
+	hintLabel.Hide()
 	lintLayout := widgets.NewQHBoxLayout()
 	lintLayout.SetContentsMargins(0, 0, 0, 0)
 	lintLayout.SetSpacing(0)
@@ -323,38 +361,129 @@ func initStatuslineNew() *Statusline {
 	lintLayout.AddWidget(errorLabel, 0, 0)
 	lintLayout.AddWidget(warnIcon, 0, 0)
 	lintLayout.AddWidget(warnLabel, 0, 0)
+	lintLayout.AddWidget(infoIcon, 0, 0)
+	lintLayout.AddWidget(infoLabel, 0, 0)
+	lintLayout.AddWidget(hintIcon, 0, 0)
+	lintLayout.AddWidget(hintLabel, 0, 0)
 	lintWidget := widgets.NewQWidget(nil, 0)
 	lintWidget.SetLayout(lintLayout)
+	lintWidget.ConnectMousePressEvent(func(event *gui.QMouseEvent) {
+		if !editor.activity.problemsItem.active {
+			editor.activity.problemsItem.activate()
+		}
+	})
 	lint := &StatuslineLint{
 		s:          s,
 		widget:     lintWidget,
 		okIcon:     okIcon,
 		errorIcon:  errorIcon,
 		warnIcon:   warnIcon,
+		infoIcon:   infoIcon,
+		hintIcon:   hintIcon,
 		okLabel:    okLabel,
 		errorLabel: errorLabel,
 		warnLabel:  warnLabel,
+		infoLabel:  infoLabel,
+		hintLabel:  hintLabel,
 		errors:     -1,
 		warnings:   -1,
 	}
 	s.lint = lint
 
+	segments := map[string]widgets.QWidget_ITF{
+		"mode":         modeWidget,
+		"path":         fileWidget,
+		"notification": notifyWidget,
+		"git":          gitWidget,
+		"filetype":     filetypeLabel,
+		"fileformat":   fileFormatLabel,
+		"encoding":     encodingLabel,
+		"position":     posLabel,
+		"lint":         lintWidget,
+	}
+	s.users = map[string]*StatuslineUser{}
+	for name, segment := range editor.config.Statusline.Segments {
+		if segment.Expr == "" {
+			continue
+		}
+		label := widgets.NewQLabel(nil, 0)
+		label.SetFont(gui.NewQFont2(editor.config.Editor.FontFamily, editor.config.Editor.FontSize, 1, false))
+		label.Hide()
+		s.users[name] = &StatuslineUser{
+			name:  name,
+			label: label,
+		}
+		segments[name] = label
+	}
+
 	s.setContentsMarginsForWidgets(0, 7, 0, 9)
 
-	layout.AddWidget(modeAndFileWidget)
-	layout.AddWidget(notifyWidget)
-	layout.AddWidget(gitWidget)
-	layout.AddWidget(filetypeLabel)
-	layout.AddWidget(fileFormatLabel)
-	layout.AddWidget(encodingLabel)
-	layout.AddWidget(posLabel)
-	layout.AddWidget(lintWidget)
+	hide := map[string]bool{}
+	for _, name := range editor.config.Statusline.Hide {
+		hide[name] = true
+	}
+	left := []widgets.QWidget_ITF{}
+	right := []widgets.QWidget_ITF{}
+	unknown := []string{}
+	for _, name := range editor.config.Statusline.Left {
+		segment, ok := segments[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if !hide[name] {
+			left = append(left, segment)
+			delete(segments, name)
+		}
+	}
+	for _, name := range editor.config.Statusline.Right {
+		segment, ok := segments[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if !hide[name] {
+			right = append(right, segment)
+			delete(segments, name)
+		}
+	}
+	if len(unknown) > 0 {
+		editor.pushNotification(NotifyWarn, -1, "[Gonvim] Unknown statusline segments in setting.toml: "+strings.Join(unknown, ", "))
+	}
+
+	// The flow layout puts the items after the left ones from the right edge,
+	// so a placeholder keeps the right segments right-aligned even if
+	// the left is empty.
+	if len(left) == 0 {
+		left = append(left, widgets.NewQWidget(nil, 0))
+	}
+	// spacing, padding, paddingtop, rightitemnum, width
+	layout := newVFlowLayout(16, 10, 1, len(left), 0)
+	widget.SetLayout(layout)
+	for _, segment := range left {
+		layout.AddWidget(segment)
+	}
+	for i := len(right) - 1; i >= 0; i-- {
+		layout.AddWidget(right[i])
+	}
+
+	// The segments not in the layout are kept in a hidden widget,
+	// so they never show up as top level windows.
+	hiddenSegments := widgets.NewQWidget(widget, 0)
+	hiddenSegments.Hide()
+	for _, segment := range segments {
+		segment.QWidget_PTR().SetParent(hiddenSegments)
+	}
 
 	return s
 }
 
 func (s *Statusline) setContentsMarginsForWidgets(l int, u int, r int, d int) {
 	s.main.widget.SetContentsMargins(l, u, r, d)
+	s.main.modeWidget.SetContentsMargins(l, u, r, d)
+	for _, user := range s.users {
+		user.label.SetContentsMargins(l, u, r, d)
+	}
 	s.pos.label.SetContentsMargins(l, u, r, d)
 	s.notify.widget.SetContentsMargins(l, u, r, d)
 	s.filetype.label.SetContentsMargins(l, u, r, d)
@@ -377,7 +506,7 @@ func (s *Statusline) subscribe() {
 		s.lint.update()
 	})
 	s.ws.signal.ConnectGitSignal(func() {
-		s.git.update()
+		s.git.apply(<-s.git.updates)
 	})
 	s.ws.nvim.RegisterHandler("statusline", func(updates ...interface{}) {
 		s.updates <- updates
@@ -401,7 +530,19 @@ func (s *Statusline) handleUpdates(updates []interface{}) {
 		s.filetype.redraw(filetype)
 		s.encoding.redraw(encoding)
 		s.fileFormat.redraw(fileFormat)
-		go s.git.redraw(s.ws.filepath)
+		s.git.redraw(s.ws.filepath)
+	case "user":
+		user, ok := s.users[updates[1].(string)]
+		if !ok {
+			return
+		}
+		text := ""
+		if len(updates) > 2 && updates[2] != nil {
+			text = fmt.Sprintf("%v", updates[2])
+		}
+		user.redraw(text)
+	case "gitrefresh":
+		s.git.refresh()
 	default:
 		fmt.Println("unhandled statusline event", event)
 	}
@@ -508,14 +649,6 @@ func (s *StatusMode) redraw() {
 	}
 }
 
-func (s *StatuslineGit) hide() {
-	if s.hidden {
-		return
-	}
-	s.hidden = true
-	s.s.ws.signal.GitSignal()
-}
-
 func (s *StatuslineGit) update() {
 	if s.hidden {
 		s.widget.Hide()
@@ -531,51 +664,74 @@ func (s *StatuslineGit) update() {
 	s.widget.Show()
 }
 
+// redraw finds the repository of the file in a goroutine, which sends it
+// back to apply in the GUI thread
 func (s *StatuslineGit) redraw(file string) {
-	if file == "" || strings.HasPrefix(file, "term://") {
-		s.file = file
-		s.hide()
-		s.branch = ""
-		return
-	}
-
 	if s.file == file {
 		return
 	}
-
 	s.file = file
-	dir := filepath.Dir(file)
-	cmd := exec.Command("git", "-C", dir, "branch")
-	osdepend.PrepareRunProc(cmd)
-	out, err := cmd.Output()
-	if err != nil {
-		s.hide()
-		s.branch = ""
+	if file == "" || strings.HasPrefix(file, "term://") {
+		s.apply(&statuslineGitUpdate{file: file})
 		return
 	}
 
-	branch := ""
-	for _, line := range strings.Split(string(out), "\n") {
-		if strings.HasPrefix(line, "* ") {
-			if strings.HasPrefix(line, "* (HEAD detached at ") {
-				branch = line[20 : len(line)-1]
-			} else {
-				branch = line[2:]
+	go func() {
+		update := &statuslineGitUpdate{file: file}
+		update.root, _ = findGitRoot(file)
+		if update.root != "" {
+			update.status = editor.gitStatuses.cached(update.root)
+			if update.status == nil {
+				update.status, update.err = editor.gitStatuses.get(update.root)
 			}
 		}
+		s.updates <- update
+		s.s.ws.signal.GitSignal()
+	}()
+}
+
+// refresh runs git again for the repository of the current file.
+// The new status comes back through setStatus.
+func (s *StatuslineGit) refresh() {
+	if s.root == "" {
+		return
 	}
-	cmd = exec.Command("git", "-C", dir, "diff", "--quiet")
-	osdepend.PrepareRunProc(cmd)
-	_, err = cmd.Output()
-	if err != nil {
-		branch += "*"
-	}
+	file := s.file
+	root := s.root
+	go func() {
+		_, err := editor.gitStatuses.refresh(root)
+		if err != nil {
+			s.updates <- &statuslineGitUpdate{file: file, root: root, err: err}
+			s.s.ws.signal.GitSignal()
+		}
+	}()
+}
 
-	if s.branch != branch {
-		s.branch = branch
+// apply shows the repository found by redraw or refresh in the GUI thread
+func (s *StatuslineGit) apply(update *statuslineGitUpdate) {
+	if update.file != s.file {
+		// The current file has changed since
+		return
+	}
+	s.root = update.root
+	if update.status == nil || update.err != nil {
+		s.branch = ""
+		s.hidden = true
+	} else {
+		s.branch = update.status.String()
 		s.hidden = false
-		s.s.ws.signal.GitSignal()
 	}
+	s.update()
+}
+
+// setStatus is called in the GUI thread when the status of a repository is updated
+func (s *StatuslineGit) setStatus(status *GitStatus) {
+	if status.root != s.root {
+		return
+	}
+	s.branch = status.String()
+	s.hidden = false
+	s.update()
 }
 
 func (s *StatuslineMain) redraw(file string) {
@@ -660,8 +816,22 @@ func (s *StatuslineFiletype) redraw(filetype string) {
 	s.label.Show()
 }
 
+func (s *StatuslineUser) redraw(text string) {
+	text = strings.TrimSpace(text)
+	if text == s.text {
+		return
+	}
+	s.text = text
+	s.label.SetText(text)
+	if text == "" {
+		s.label.Hide()
+	} else {
+		s.label.Show()
+	}
+}
+
 func (s *StatuslineNotify) update() {
-	s.num = len(editor.notifications)
+	s.num = editor.notificationCenter.unread
 	if s.num == 0 {
 		s.label.Hide()
 		return
@@ -674,6 +844,8 @@ func (s *StatuslineNotify) update() {
 func (s *StatuslineLint) update() {
 	s.errorLabel.SetText(strconv.Itoa(s.errors))
 	s.warnLabel.SetText(strconv.Itoa(s.warnings))
+	s.infoLabel.SetText(strconv.Itoa(s.infos))
+	s.hintLabel.SetText(strconv.Itoa(s.hints))
 	if !s.svgLoaded {
 		s.svgLoaded = true
 		//svgContent := editor.getSvg("check", newRGBA(141, 193, 73, 1))
@@ -722,10 +894,14 @@ func (s *StatuslineLint) update() {
 	s.warnIcon.Show()
 	s.warnLabel.Show()
 	//}
+	s.infoIcon.SetVisible(s.infos > 0)
+	s.infoLabel.SetVisible(s.infos > 0)
+	s.hintIcon.SetVisible(s.hints > 0)
+	s.hintLabel.SetVisible(s.hints > 0)
 }
 
-func (s *StatuslineLint) redraw(errors, warnings int) {
-	if errors == s.errors && warnings == s.warnings {
+func (s *StatuslineLint) redraw(errors, warnings, infos, hints int) {
+	if errors == s.errors && warnings == s.warnings && infos == s.infos && hints == s.hints {
 		return
 	}
 	var svgErrContent, svgWrnContent string
@@ -754,7 +930,15 @@ func (s *StatuslineLint) redraw(errors, warnings int) {
 	}
 	s.errorIcon.Load2(core.NewQByteArray2(svgErrContent, len(svgErrContent)))
 	s.warnIcon.Load2(core.NewQByteArray2(svgWrnContent, len(svgWrnContent)))
+	icon, color := diagnosticIcon(SeverityInfo)
+	svgInfContent := editor.getSvg(icon, color)
+	s.infoIcon.Load2(core.NewQByteArray2(svgInfContent, len(svgInfContent)))
+	icon, color = diagnosticIcon(SeverityHint)
+	svgHintContent := editor.getSvg(icon, color)
+	s.hintIcon.Load2(core.NewQByteArray2(svgHintContent, len(svgHintContent)))
 	s.errors = errors
 	s.warnings = warnings
+	s.infos = infos
+	s.hints = hints
 	s.s.ws.signal.LintSignal()
 }
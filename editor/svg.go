@@ -162,6 +162,18 @@ func (e *Editor) initSVGS() {
 		xml:    `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M7 2V13H10V22L17 10H13L17 2H7Z" /></svg>`,
 	}
 
+	e.svgs["activitygit"] = &SvgXML{
+		width:  24,
+		height: 24,
+		xml:    `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M6 3A3 3 0 0 0 5 8.83V15.17A3 3 0 1 0 7 15.17V13.5C7 12.67 7.67 12 8.5 12H13.5A3.5 3.5 0 0 0 17 8.5V8.83A3 3 0 1 0 15 8.83V8.5C15 9.33 14.33 10 13.5 10H8.5C7.97 10 7.46 10.1 7 10.28V8.83A3 3 0 0 0 6 3M6 5A1 1 0 1 1 6 7A1 1 0 0 1 6 5M16 5A1 1 0 1 1 16 7A1 1 0 0 1 16 5M6 17A1 1 0 1 1 6 19A1 1 0 0 1 6 17Z" /></svg>`,
+	}
+
+	e.svgs["activityproblems"] = &SvgXML{
+		width:  24,
+		height: 24,
+		xml:    `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M12 2L1 21H23M12 6L19.53 19H4.47M11 10V14H13V10M11 16V18H13V16" /></svg>`,
+	}
+
 	e.svgs["moredots"] = &SvgXML{
 		width:  24,
 		height: 24,
@@ -247,6 +259,54 @@ func (e *Editor) initSVGS() {
 		color:     e.fgcolor,
 		xml:       `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M13 14H11V10H13M13 18H11V16H13M1 21H23L12 2L1 21Z" /></svg>`,
 	}
+	e.svgs["lintinf"] = &SvgXML{
+		width:     24,
+		height:    24,
+		thickness: 2,
+		color:     e.fgcolor,
+		xml:       `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M13 9H11V7H13M13 17H11V11H13M12 2A10 10 0 0 0 2 12A10 10 0 0 0 12 22A10 10 0 0 0 22 12A10 10 0 0 0 12 2Z" /></svg>`,
+	}
+	e.svgs["linthint"] = &SvgXML{
+		width:     24,
+		height:    24,
+		thickness: 2,
+		color:     e.fgcolor,
+		xml:       `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" d="M12 2A7 7 0 0 0 5 9C5 11.38 6.19 13.47 8 14.74V17A1 1 0 0 0 9 18H15A1 1 0 0 0 16 17V14.74C17.81 13.47 19 11.38 19 9A7 7 0 0 0 12 2M9 21A1 1 0 0 0 10 22H14A1 1 0 0 0 15 21V20H9V21Z" /></svg>`,
+	}
+	// Icons of the completion item kinds of LSP
+	for name, path := range map[string]string{
+		"kindtext":          "M5 4V7H10.5V19H13.5V7H19V4H5Z",
+		"kindmethod":        "M12 2L3 7V17L12 22L21 17V7L12 2M12 4.3L18.6 8L12 11.7L5.4 8L12 4.3M5 9.7L11 13.1V19.4L5 16V9.7M13 19.4V13.1L19 9.7V16L13 19.4Z",
+		"kindfunction":      "M12 2L3 7L12 12L21 7L12 2M3 8.7V17L11 21.5V13.2L3 8.7M21 8.7L13 13.2V21.5L21 17V8.7Z",
+		"kindconstructor":   "M2 19.6L4.4 22L13.6 12.8L11.2 10.4L2 19.6M13 3L10.5 5.5L18.5 13.5L21 11L19.6 9.6L21 8.2L15.8 3L14.4 4.4L13 3Z",
+		"kindfield":         "M5.5 7A1.5 1.5 0 1 1 7 5.5A1.5 1.5 0 0 1 5.5 7M21.4 11.6L12.4 2.6C12 2.2 11.5 2 11 2H4C2.9 2 2 2.9 2 4V11C2 11.5 2.2 12 2.6 12.4L11.6 21.4C12 21.8 12.5 22 13 22S14 21.8 14.4 21.4L21.4 14.4C21.8 14 22 13.5 22 13S21.8 12 21.4 11.6Z",
+		"kindvariable":      "M4 4H8V6H6V18H8V20H4V4M16 4H20V20H16V18H18V6H16V4M8.5 8H11L12 10.1L13 8H15.5L13.3 12L15.5 16H13L12 13.9L11 16H8.5L10.7 12L8.5 8Z",
+		"kindclass":         "M3 3H9V9H3V3M15 3H21V9H15V3M9 5H15V7H9V5M11 7H13V15H11V7M9 15H15V21H9V15Z",
+		"kindinterface":     "M7 7A5 5 0 1 1 7 17A5 5 0 1 1 7 7M7 9A3 3 0 1 0 7 15A3 3 0 1 0 7 9M12 11H22V13H12V11Z",
+		"kindmodule":        "M3 6L12 2L21 6V18L12 22L3 18V6M12 4.2L6.5 6.6L12 9L17.5 6.6L12 4.2M5 8.2V16.7L11 19.4V10.8L5 8.2M19 8.2L13 10.8V19.4L19 16.7V8.2Z",
+		"kindproperty":      "M7 14A2 2 0 1 1 7 10A2 2 0 1 1 7 14M12.6 10A6 6 0 1 0 12.6 14H16V18H20V14H22V10H12.6Z",
+		"kindunit":          "M3 7H21V17H3V7M5 9V15H19V9H17V12H15V9H13V12H11V9H9V12H7V9H5Z",
+		"kindvalue":         "M12 2L22 12L12 22L2 12L12 2M12 6L6 12L12 18L18 12L12 6Z",
+		"kindenum":          "M3 5H7V9H3V5M9 6H21V8H9V6M3 10H7V14H3V10M9 11H21V13H9V11M3 15H7V19H3V15M9 16H21V18H9V16Z",
+		"kindkeyword":       "M4 6H20V8H4V6M4 11H14V13H4V11M4 16H20V18H4V16Z",
+		"kindsnippet":       "M8 3C6.3 3 5 4.3 5 6V9C5 10.1 4.1 11 3 11V13C4.1 13 5 13.9 5 15V18C5 19.7 6.3 21 8 21H10V19H8C7.4 19 7 18.6 7 18V15C7 13.8 6.5 12.7 5.6 12C6.5 11.3 7 10.2 7 9V6C7 5.4 7.4 5 8 5H10V3H8M16 3C17.7 3 19 4.3 19 6V9C19 10.1 19.9 11 21 11V13C19.9 13 19 13.9 19 15V18C19 19.7 17.7 21 16 21H14V19H16C16.6 19 17 18.6 17 18V15C17 13.8 17.5 12.7 18.4 12C17.5 11.3 17 10.2 17 9V6C17 5.4 16.6 5 16 5H14V3H16Z",
+		"kindcolor":         "M12 2A10 10 0 1 0 12 22C13.1 22 14 21.1 14 20C14 19.5 13.8 19 13.5 18.7C13.2 18.3 13 17.9 13 17.4C13 16.3 13.9 15.4 15 15.4H17C20.3 15.4 22 13 22 11C22 6 17.5 2 12 2M6.5 12A1.5 1.5 0 1 1 6.5 9A1.5 1.5 0 1 1 6.5 12M9.5 8A1.5 1.5 0 1 1 9.5 5A1.5 1.5 0 1 1 9.5 8M14.5 8A1.5 1.5 0 1 1 14.5 5A1.5 1.5 0 1 1 14.5 8M17.5 12A1.5 1.5 0 1 1 17.5 9A1.5 1.5 0 1 1 17.5 12Z",
+		"kindfile":          "M14 2H6C4.9 2 4 2.9 4 4V20C4 21.1 4.9 22 6 22H18C19.1 22 20 21.1 20 20V8L14 2M13 9V3.5L18.5 9H13Z",
+		"kindreference":     "M10.6 13.4A1 1 0 0 1 9.2 14.8A4.8 4.8 0 0 1 9.2 8L12.7 4.5A4.8 4.8 0 0 1 19.5 11.3L18 12.8A6.7 6.7 0 0 0 17.6 10.3L18.1 9.9A2.8 2.8 0 0 0 14.1 5.9L10.6 9.4A2.8 2.8 0 0 0 10.6 13.4M13.4 9.2A1 1 0 0 1 14.8 9.2A4.8 4.8 0 0 1 14.8 16L11.3 19.5A4.8 4.8 0 0 1 4.5 12.7L6 11.2A6.7 6.7 0 0 0 6.4 13.7L5.9 14.1A2.8 2.8 0 0 0 9.9 18.1L13.4 14.6A2.8 2.8 0 0 0 13.4 10.6A1 1 0 0 1 13.4 9.2Z",
+		"kindfolder":        "M10 4H4C2.89 4 2 4.89 2 6V18A2 2 0 0 0 4 20H20A2 2 0 0 0 22 18V8C22 6.89 21.1 6 20 6H12L10 4Z",
+		"kindenummember":    "M4 4H20V20H4V4M6 6V18H18V6H6M8 11H16V13H8V11Z",
+		"kindconstant":      "M4 4H20V20H4V4M6 6V18H18V6H6M8 9H16V11H8V9M8 13H16V15H8V13Z",
+		"kindstruct":        "M3 3H11V11H3V3M13 3H21V11H13V3M3 13H11V21H3V13M13 13H21V21H13V13Z",
+		"kindevent":         "M7 2V13H10V22L17 10H13L17 2H7Z",
+		"kindoperator":      "M10 4H14V10H20V14H14V20H10V14H4V10H10V4Z",
+		"kindtypeparameter": "M4 4H20V20H4V4M6 6V18H18V6H6M8 8H16V10H13V16H11V10H8V8Z",
+	} {
+		e.svgs[name] = &SvgXML{
+			width:  24,
+			height: 24,
+			xml:    `<svg width="24" height="24" viewBox="0 0 24 24"><path fill="%s" fill-rule="evenodd" d="` + path + `" /></svg>`,
+		}
+	}
 	e.svgs["hoverclose"] = &SvgXML{
 		width:  1792,
 		height: 1792,
@@ -42,6 +42,7 @@ type Tab struct {
 	file      *widgets.QLabel
 	fileText  string
 	hidden    bool
+	gitStatus string
 }
 
 func (t *Tabline) subscribe() {
@@ -415,6 +416,12 @@ func (t *Tabline) update(args []interface{}) {
 		if text != tab.fileText {
 			tab.fileText = text
 			tab.updateFileText()
+			path := tab.path()
+			if status := editor.gitStatuses.cached(path); status != nil {
+				tab.updateGitStatus(status.fileStatus(path))
+			} else {
+				tab.updateGitStatus("")
+			}
 		}
 
 		tab.setActive(tab.ID == t.CurrentID)
@@ -430,6 +437,40 @@ func (t *Tabline) update(args []interface{}) {
 	}
 }
 
+// path returns the absolute path of the file in the tab
+func (t *Tab) path() string {
+	if t.fileText == "" || strings.HasPrefix(t.fileText, "term://") || filepath.IsAbs(t.fileText) {
+		return t.fileText
+	}
+	return filepath.Join(t.t.ws.cwd, t.fileText)
+}
+
+func (t *Tabline) setGitStatus(status *GitStatus) {
+	for _, tab := range t.Tabs {
+		if tab.hidden || tab.fileText == "" {
+			continue
+		}
+		path := tab.path()
+		if root, _ := findGitRoot(path); root != status.root {
+			continue
+		}
+		tab.updateGitStatus(status.fileStatus(path))
+	}
+}
+
+func (t *Tab) updateGitStatus(fileStatus string) {
+	if t.gitStatus == fileStatus {
+		return
+	}
+	t.gitStatus = fileStatus
+	color := gitFileColor(fileStatus)
+	if color == nil {
+		t.file.SetStyleSheet("")
+		return
+	}
+	t.file.SetStyleSheet(fmt.Sprintf("color: %s;", color.print()))
+}
+
 func getFileType(text string) string {
 	if strings.HasPrefix(text, "term://") {
 		return "terminal"
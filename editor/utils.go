@@ -42,6 +42,8 @@ func isTrue(d interface{}) bool {
 		if a == 1 {
 			return true
 		}
+	case bool:
+		return a
 	}
 	return false
 }
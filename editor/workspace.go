@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
-	"runtime"
 
 	"github.com/akiyosi/gonvim/fuzzy"
 	shortpath "github.com/akiyosi/short_path"
@@ -31,6 +31,11 @@ type workspaceSignal struct {
 	_ func() `signal:"lintSignal"`
 	_ func() `signal:"gitSignal"`
 	_ func() `signal:"messageSignal"`
+	_ func() `signal:"gitStatusSignal"`
+	_ func() `signal:"gitHunkSignal"`
+	_ func() `signal:"diagnosticsSignal"`
+	_ func() `signal:"hoverSignal"`
+	_ func() `signal:"markdownExportSignal"`
 }
 
 // Workspace is an editor workspace
@@ -49,12 +54,12 @@ type Workspace struct {
 	loc        *Locpopup
 	cmdline    *Cmdline
 	signature  *Signature
-	// Need https://github.com/neovim/neovim/pull/7466 to be merged
-	// message    *Message
-	minimap *MiniMap
-	width   int
-	height  int
-	hidden  bool
+	hover      *Hover
+	message    *Message
+	minimap    *MiniMap
+	width      int
+	height     int
+	hidden     bool
 
 	nvim             *nvim.Nvim
 	rows             int
@@ -78,7 +83,11 @@ type Workspace struct {
 	guiUpdates    chan []interface{}
 	doneNvimStart chan bool
 	stopOnce      sync.Once
-	stop          chan struct{}
+
+	gitStatusUpdates chan *GitStatus
+	gitHunkUpdates   chan []*GitHunk
+	gitHunks         []*GitHunk
+	stop             chan struct{}
 
 	drawStatusline bool
 	drawTabline    bool
@@ -94,6 +103,9 @@ func newWorkspace(path string) (*Workspace, error) {
 		redrawUpdates: make(chan [][]interface{}, 1000),
 		guiUpdates:    make(chan []interface{}, 1000),
 		doneNvimStart: make(chan bool, 1000),
+
+		gitStatusUpdates: make(chan *GitStatus, 100),
+		gitHunkUpdates:   make(chan []*GitHunk, 100),
 	}
 	w.signal.ConnectRedrawSignal(func() {
 		updates := <-w.redrawUpdates
@@ -103,7 +115,28 @@ func newWorkspace(path string) (*Workspace, error) {
 		updates := <-w.guiUpdates
 		w.handleRPCGui(updates)
 	})
+	w.signal.ConnectGitStatusSignal(func() {
+		status := <-w.gitStatusUpdates
+		w.updateGitStatus(status)
+	})
+	editor.gitStatuses.subscribe(w)
+	w.signal.ConnectGitHunkSignal(func() {
+		w.gitHunks = <-w.gitHunkUpdates
+		w.scrollBar.widget.Update()
+		if w.minimap != nil {
+			w.minimap.widget.Update()
+		}
+	})
+	w.signal.ConnectDiagnosticsSignal(func() {
+		if editor.problemsSide != nil && w == editor.workspaces[editor.active] {
+			editor.problemsSide.update()
+		}
+	})
+	w.signal.ConnectHoverSignal(func() {
+		w.hover.update()
+	})
 	w.signal.ConnectStopSignal(func() {
+		editor.gitStatuses.unsubscribe(w)
 		workspaces := []*Workspace{}
 		index := 0
 		for i, ws := range editor.workspaces {
@@ -113,6 +146,7 @@ func newWorkspace(path string) (*Workspace, error) {
 				index = i
 			}
 		}
+		editor.gitStatuses.release(workspaces)
 		if len(workspaces) == 0 {
 			editor.close()
 			return
@@ -149,6 +183,7 @@ func newWorkspace(path string) (*Workspace, error) {
 	w.popup = initPopupmenuNew(w.font)
 	w.popup.widget.SetParent(w.screen.widget)
 	w.popup.ws = w
+	w.popup.doc.SetParent(w.screen.widget)
 	w.finder = initFinder()
 	w.finder.ws = w
 	w.palette = initPalette()
@@ -160,10 +195,12 @@ func newWorkspace(path string) (*Workspace, error) {
 	w.signature = initSignature()
 	w.signature.widget.SetParent(w.screen.widget)
 	w.signature.ws = w
-	// Need https://github.com/neovim/neovim/pull/7466 to be merged
-	// w.message = initMessage()
-	// w.message.widget.SetParent(w.screen.widget)
-	// w.message.ws = w
+	w.hover = initHover()
+	w.hover.widget.SetParent(w.screen.widget)
+	w.hover.ws = w
+	w.message = initMessage()
+	w.message.setParent(w.screen.widget)
+	w.message.ws = w
 	w.cmdline = initCmdline()
 	w.cmdline.ws = w
 	w.minimap = newMiniMap()
@@ -204,12 +241,12 @@ func newWorkspace(path string) (*Workspace, error) {
 	w.palette.hide()
 	w.loc.widget.Hide()
 	w.signature.widget.Hide()
+	w.hover.hide()
 
 	w.widget.SetParent(editor.wsWidget)
 	w.widget.Move2(0, 0)
 	w.updateSize()
 
-	go w.minimap.startMinimapProc()
 	go w.startNvim(path)
 
 	if runtime.GOOS == "windows" {
@@ -252,6 +289,10 @@ func (w *Workspace) startNvim(path string) error {
 		w.redrawUpdates <- updates
 		w.signal.RedrawSignal()
 	})
+	// The buffer events of the minimap
+	w.nvim.RegisterHandler("nvim_buf_lines_event", w.minimap.linesEvent)
+	w.nvim.RegisterHandler("nvim_buf_detach_event", w.minimap.detachEvent)
+	w.nvim.RegisterHandler("nvim_buf_changedtick_event", func(...interface{}) {})
 
 	go func() {
 		err := w.nvim.Serve()
@@ -313,8 +354,7 @@ func (w *Workspace) attachUI(path string) error {
 	w.tabline.subscribe()
 	w.statusline.subscribe()
 	w.loc.subscribe()
-	// NOTE: Need https://github.com/neovim/neovim/pull/7466 to be merged
-	// w.message.subscribe()
+	w.message.subscribe()
 	fuzzy.RegisterPlugin(w.nvim)
 
 	w.uiAttached = true
@@ -333,19 +373,25 @@ func (w *Workspace) initGonvim() {
 	aug GonvimAu | au! | aug END
 	au GonvimAu VimEnter * call rpcnotify(1, "Gui", "gonvim_enter", getcwd())
 	au GonvimAu VimLeavePre * call rpcnotify(1, "Gui", "gonvim_exit")
-	au GonvimAu CursorMoved,CursorMovedI * call rpcnotify(0, "Gui", "gonvim_cursormoved", getpos("."))
+	au GonvimAu CursorMoved,CursorMovedI * call rpcnotify(0, "Gui", "gonvim_cursormoved", getpos("."), bufnr("%"))
+	au GonvimAu ColorScheme * call rpcnotify(0, "Gui", "gonvim_colorscheme")
 	aug GonvimAuWorkspace | au! | aug END
 	au GonvimAuWorkspace DirChanged * call rpcnotify(0, "Gui", "gonvim_workspace_cwd", getcwd())
 	aug GonvimAuFileExplorer | au! | aug END
 	au GonvimAuFileExplorer BufEnter,TabEnter,DirChanged,TermOpen,TermClose * call rpcnotify(0, "Gui", "gonvim_workspace_setCurrentFileLabel", expand("%:p"))
 	au GonvimAuFileExplorer TextChanged,TextChangedI,BufEnter,BufWrite,DirChanged * call rpcnotify(0, "Gui", "gonvim_workspace_updateMoifiedbadge")
-	aug GonvimAuMd | au! | aug END
-	au GonvimAuMd TextChanged,TextChangedI *.md call rpcnotify(0, "Gui", "gonvim_markdown_update")
-	au GonvimAuMd BufEnter *.md call rpcnotify(0, "Gui", "gonvim_markdown_new_buffer")
 	aug GonvimAuMinimap | au! | aug END
 	au GonvimAuMinimap BufEnter,BufWrite * call rpcnotify(0, "Gui", "gonvim_minimap_update")
+	aug GonvimAuGitHunk | au! | aug END
+	au GonvimAuGitHunk BufEnter,BufWritePost,TextChanged,InsertLeave,FocusGained * call rpcnotify(0, "Gui", "gonvim_git_hunks")
+	aug GonvimAuSignature | au! | aug END
+	au GonvimAuSignature InsertLeave,BufLeave,WinLeave * if get(g:, ''gonvim_signature_shown'') | let g:gonvim_signature_shown = 0 | call rpcnotify(0, "Gui", "signature_hide") | endif
+	aug GonvimAuHover | au! | aug END
+	au GonvimAuHover CursorMoved,CursorMovedI,InsertEnter,BufLeave,WinLeave * if get(g:, ''gonvim_hover_shown'') | let g:gonvim_hover_shown = 0 | call rpcnotify(0, "Gui", "gonvim_hover_hide") | endif
 	`
 
+	gonvimAutoCmds = gonvimAutoCmds + previewAutoCmds()
+
 	if editor.config.ScrollBar.Visible {
 		gonvimAutoCmds = gonvimAutoCmds + `
 	aug GonvimAuScrollbar | au! | aug END
@@ -362,12 +408,29 @@ func (w *Workspace) initGonvim() {
 		gonvimAutoCmds = gonvimAutoCmds + `
 	aug GonvimAuStatusline | au! | aug END
 	au GonvimAuStatusline BufEnter,OptionSet,TermOpen,TermClose * call rpcnotify(0, "statusline", "bufenter", &filetype, &fileencoding, &fileformat)
+	au GonvimAuStatusline BufWritePost,FocusGained * call rpcnotify(0, "statusline", "gitrefresh")
 	`
+		gonvimAutoCmds = gonvimAutoCmds + statuslineUserAutoCmds()
 	}
 	if editor.config.Lint.Visible {
 		gonvimAutoCmds = gonvimAutoCmds + `
 	aug GonvimAuLint | au! | aug END
-	au GonvimAuLint CursorMoved,CursorHold,InsertEnter,InsertLeave * call rpcnotify(0, "LocPopup", "update")
+	au GonvimAuLint CursorMoved,CursorHold,InsertEnter,InsertLeave * call rpcnotify(0, "LocPopup", "cursor", bufnr(''%''), line(''.''), col(''.''), mode())
+	`
+	}
+	// The diagnostics are also listed in the problems panel, which can be
+	// opened without the lint popup
+	gonvimAutoCmds = gonvimAutoCmds + `
+	aug GonvimAuDiagnostics | au! | aug END
+	au GonvimAuDiagnostics BufEnter,WinEnter,QuickFixCmdPost * call rpcnotify(0, "LocPopup", "diagnostics")
+	au GonvimAuDiagnostics User ALELintPost,NeomakeFinished call rpcnotify(0, "LocPopup", "diagnostics")
+	`
+	// DiagnosticChanged exists since nvim 0.6
+	hasDiagnosticChanged := 0
+	w.nvim.Eval("exists('##DiagnosticChanged')", &hasDiagnosticChanged)
+	if hasDiagnosticChanged == 1 {
+		gonvimAutoCmds = gonvimAutoCmds + `
+	au GonvimAuDiagnostics DiagnosticChanged * call rpcnotify(0, "LocPopup", "diagnostics")
 	`
 	}
 	// registerAutocmds := fmt.Sprintf(`call execute(split('%s', '\n'))`, gonvimAutoCmds)
@@ -382,19 +445,86 @@ func (w *Workspace) initGonvim() {
 	command! -nargs=1 GonvimWorkspaceSwitch call rpcnotify(0, "Gui", "gonvim_workspace_switch", <args>)
 	command! GonvimVersion echo %s
 	command! GonvimMarkdown call rpcnotify(0, "Gui", "%s")
-	`, editor.version, GonvimMarkdownToggleEvent)
+	function! GonvimMarkdownExportFormats(...)
+	return "html\npdf"
+	endfunction
+	command! -nargs=+ -complete=custom,GonvimMarkdownExportFormats GonvimMarkdownExport call rpcnotify(0, "Gui", "%s", <f-args>)
+	command! GonvimSignatureNext call rpcnotify(0, "Gui", "signature_next")
+	command! GonvimSignaturePrevious call rpcnotify(0, "Gui", "signature_prev")
+	command! GonvimNotifications call rpcnotify(0, "Gui", "gonvim_notifications_toggle")
+	function! GonvimFuzzySources(...)
+	return join(%s, "\n")
+	endfunction
+	command! -nargs=1 -complete=custom,GonvimFuzzySources GonvimFuzzy call rpcnotify(0, "GonvimFuzzy", "source", <q-args>)
+	`, editor.version, GonvimMarkdownToggleEvent, GonvimMarkdownExportEvent, fuzzySourceList())
 	// registerCommands := fmt.Sprintf(`call execute(split('%s', '\n'))`, gonvimCommands)
 	registerCommands := fmt.Sprintf(`call execute(%s)`, splitVimscript(gonvimCommands))
 	w.nvim.Command(registerCommands)
 
 	gonvimInitNotify := `
 	call rpcnotify(0, "statusline", "bufenter", expand("%:p"), &filetype, &fileencoding, &fileformat)
-	call rpcnotify(0, "Gui", "gonvim_cursormoved",  getpos("."))
+	call rpcnotify(0, "Gui", "gonvim_cursormoved",  getpos("."), bufnr("%"))
 	call rpcnotify(0, "Gui", "gonvim_workspace_updateMoifiedbadge")
 	call rpcnotify(0, "Gui", "gonvim_minimap_update")`
 	// initialNotify := fmt.Sprintf(`call execute(split('%s', '\n'))`, gonvimInitNotify)
 	initialNotify := fmt.Sprintf(`call execute(%s)`, splitVimscript(gonvimInitNotify))
 	w.nvim.Command(initialNotify)
+
+	w.nvim.Call("luaeval", nil, hoverHandlerLua)
+	w.nvim.Call("luaeval", nil, signatureHelpLua)
+	w.nvim.Call("luaeval", nil, notifyLua, editor.config.Editor.KeepVimNotify)
+	w.popup.updateColors()
+
+	if editor.config.Statusline.Visible {
+		for name, segment := range editor.config.Statusline.Segments {
+			if segment.Expr == "" {
+				continue
+			}
+			w.nvim.Command(statuslineUserNotify(name, segment.Expr))
+		}
+	}
+}
+
+// statuslineUserAutoCmds returns the autocmds updating the user defined statusline segments
+func statuslineUserAutoCmds() string {
+	autoCmds := `
+	aug GonvimAuStatuslineUser | au! | aug END
+	`
+	for name, segment := range editor.config.Statusline.Segments {
+		if segment.Expr == "" {
+			continue
+		}
+		events := segment.Events
+		if len(events) == 0 {
+			events = []string{"BufEnter"}
+		}
+		// Single quotes are doubled since the lines are quoted in splitVimscript
+		command := strings.Replace(statuslineUserNotify(name, segment.Expr), "'", "''", -1)
+		autoCmds = autoCmds + fmt.Sprintf("au GonvimAuStatuslineUser %s * %s\n\t", strings.Join(events, ","), command)
+	}
+	return autoCmds
+}
+
+// statuslineUserNotify returns the command sending the value of the user segment.
+// The name and the expression are passed as string literals, the latter to
+// eval(), so that they can't break out of the command.
+func statuslineUserNotify(name, expr string) string {
+	expr = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(expr)
+	return fmt.Sprintf(`try | call rpcnotify(0, "statusline", "user", %s, eval(%s)) | catch | endtry`, vimStringLiteral(name), vimStringLiteral(expr))
+}
+
+// vimStringLiteral returns the string as a single quoted Vim string literal
+func vimStringLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// fuzzySourceList returns the built-in fuzzy sources as a vim list
+func fuzzySourceList() string {
+	names := []string{}
+	for _, name := range fuzzy.SourceNames() {
+		names = append(names, fmt.Sprintf(`"%s"`, name))
+	}
+	return "[" + strings.Join(names, ", ") + "]"
 }
 
 func splitVimscript(s string) string {
@@ -469,6 +599,33 @@ func (w *Workspace) initCwd() {
 	w.nvim.Command("cd " + w.cwd)
 }
 
+// updateGitStatus updates the widgets showing the git status of the repository
+func (w *Workspace) updateGitStatus(status *GitStatus) {
+	if w.statusline != nil {
+		w.statusline.git.setStatus(status)
+	}
+	if w.filepath != "" {
+		if root, _ := findGitRoot(w.filepath); root == status.root {
+			go w.updateGitHunks()
+		}
+	}
+	if w.tabline != nil {
+		w.tabline.setGitStatus(status)
+	}
+	if editor.gitSide != nil && w == editor.workspaces[editor.active] {
+		editor.gitSide.setStatus(status)
+	}
+	for i, ws := range editor.workspaces {
+		if ws != w || i >= len(editor.wsSide.items) {
+			continue
+		}
+		filelist := editor.wsSide.items[i].Filelist
+		if filelist != nil {
+			filelist.setGitStatus(status)
+		}
+	}
+}
+
 func (w *Workspace) setCwd(cwd string) {
 	w.cwd = cwd
 
@@ -485,6 +642,9 @@ func (w *Workspace) setCwd(cwd string) {
 	}
 	w.cwdlabel = labelpath
 	w.cwdBase = filepath.Base(cwd)
+	if editor.gitSide != nil && w == editor.workspaces[editor.active] {
+		editor.gitSide.update()
+	}
 	for i, ws := range editor.workspaces {
 		if i >= len(editor.wsSide.items) {
 			return
@@ -590,8 +750,10 @@ func (w *Workspace) attachUIOption() map[string]interface{} {
 						o["ext_wildmenu"] = true
 					} else if name == "cmdline_show" {
 						o["ext_cmdline"] = true
-					} else if name == "msg_chunk" {
+					} else if name == "msg_show" {
+						// ext_messages implies ext_linegrid
 						o["ext_messages"] = true
+						o["ext_linegrid"] = true
 					} else if name == "popupmenu_show" {
 						o["ext_popupmenu"] = true
 					} else if name == "tabline_update" {
@@ -643,10 +805,11 @@ func (w *Workspace) updateSize() {
 
 	w.screen.updateSize()
 	w.palette.resize()
-	// w.message.resize() // Need https://github.com/neovim/neovim/pull/7466 to be merged
+	w.message.resize()
 
 	// notification
 	e.updateNotificationPos()
+	e.notificationCenter.move()
 }
 
 func (e *Editor) updateNotificationPos() {
@@ -681,6 +844,24 @@ func (w *Workspace) handleRedraw(updates [][]interface{}) {
 		case "default_colors_set":
 			args := update[1].([]interface{})
 			w.setColor(args)
+			s.resetHighlights()
+		case "hl_attr_define":
+			s.hlAttrDefine(args)
+		case "hl_group_set":
+		case "grid_resize":
+			s.resize(args)
+		case "grid_clear":
+			s.clear(args)
+		case "grid_line":
+			s.gridLine(args)
+		case "grid_cursor_goto":
+			s.gridCursorGoto(args)
+			doMinimapScroll = true
+		case "grid_scroll":
+			s.gridScroll(args)
+			doMinimapScroll = true
+		case "grid_destroy":
+		case "flush":
 		case "cursor_goto":
 			s.cursorGoto(args)
 			doMinimapScroll = true
@@ -723,31 +904,30 @@ func (w *Workspace) handleRedraw(updates [][]interface{}) {
 			w.cmdline.functionShow()
 		case "cmdline_function_hide":
 			w.cmdline.functionHide()
+		case "cmdline_block_show":
+			w.cmdline.blockShow(args)
+		case "cmdline_block_append":
+			w.cmdline.blockAppend(args)
+		case "cmdline_block_hide":
+			w.cmdline.blockHide()
 		case "wildmenu_show":
 			w.cmdline.wildmenuShow(args)
 		case "wildmenu_select":
 			w.cmdline.wildmenuSelect(args)
 		case "wildmenu_hide":
 			w.cmdline.wildmenuHide()
-		case "msg_start_kind":
-			// Need https://github.com/neovim/neovim/pull/7466 to be merged
-			// if len(args) > 0 {
-			// 	kinds, ok := args[len(args)-1].([]interface{})
-			// 	if ok {
-			// 		if len(kinds) > 0 {
-			// 			kind, ok := kinds[len(kinds)-1].(string)
-			// 			if ok {
-			// 				w.message.kind = kind
-			// 			}
-			// 		}
-			// 	}
-			// }
-		case "msg_chunk":
-			// Need https://github.com/neovim/neovim/pull/7466 to be merged
-			// w.message.chunk(args)
-		case "msg_end":
+		case "msg_show":
+			w.message.msgShow(args)
+		case "msg_clear":
+			w.message.msgClear()
+		case "msg_history_show":
+			w.message.msgHistoryShow(args)
+		case "msg_ruler":
+			w.message.msgRuler(args)
+		case "msg_showmode":
+			w.message.msgShowmode(args)
 		case "msg_showcmd":
-		case "messages":
+			w.message.msgShowcmd(args)
 		case "busy_start":
 		case "busy_stop":
 		default:
@@ -762,7 +942,6 @@ func (w *Workspace) handleRedraw(updates [][]interface{}) {
 		w.scrollBar.update()
 	}
 	if doMinimapScroll && w.minimap.visible {
-		go w.updateMinimap()
 		w.minimap.mapScroll()
 	}
 }
@@ -849,21 +1028,6 @@ func (w *Workspace) setOption(update []interface{}) {
 	}
 }
 
-func (w *Workspace) updateMinimap() {
-	var absMapTop int
-	var absMapBottom int
-	w.minimap.nvim.Eval("line('w0')", &absMapTop)
-	w.minimap.nvim.Eval("line('w$')", &absMapBottom)
-	w.minimap.nvim.Command(fmt.Sprintf("call cursor(%d, %d)", w.curLine, 0))
-	switch {
-	case w.curLine >= absMapBottom:
-		w.minimap.nvim.Input("<C-d>")
-	case absMapTop >= w.curLine:
-		w.minimap.nvim.Input("<C-u>")
-	default:
-	}
-}
-
 func (w *Workspace) handleRPCGui(updates []interface{}) {
 	event := updates[0].(string)
 	switch event {
@@ -878,7 +1042,9 @@ func (w *Workspace) handleRPCGui(updates []interface{}) {
 			}
 		}()
 	case "gonvim_exit":
-		editor.workspaces[editor.active].minimap.exit()
+	case "gonvim_colorscheme":
+		go w.popup.updateColors()
+		w.minimap.queueHighlight()
 	// case "gonvim_set_colorscheme":
 	// 	fmt.Println("set_colorscheme")
 	// 	w.isSetGuiColor = false
@@ -897,12 +1063,26 @@ func (w *Workspace) handleRPCGui(updates []interface{}) {
 		w.finder.hide()
 	case "finder_select":
 		w.finder.selectResult(updates[1:])
+	case "finder_status":
+		w.finder.showStatus(updates[1:])
 	case "signature_show":
 		w.signature.showItem(updates[1:])
 	case "signature_pos":
 		w.signature.pos(updates[1:])
 	case "signature_hide":
 		w.signature.hide()
+	case "signature_next":
+		w.signature.cycle(1)
+	case "signature_prev":
+		w.signature.cycle(-1)
+	case "gonvim_hover_show":
+		go w.hover.showItem(updates[1:])
+	case "gonvim_notify":
+		go w.notify(updates[1:])
+	case "gonvim_notifications_toggle":
+		editor.notificationCenter.toggle()
+	case "gonvim_hover_hide":
+		w.hover.hide()
 	case "gonvim_cursormoved":
 		pos := updates[1].([]interface{})
 		ln := reflectToInt(pos[1])
@@ -910,12 +1090,15 @@ func (w *Workspace) handleRPCGui(updates []interface{}) {
 		w.statusline.pos.redraw(ln, col)
 		w.curLine = ln
 		w.curColm = col
+		if len(updates) > 2 {
+			w.markdown.cursorMoved(reflectToInt(updates[2]), ln)
+		}
 	case "gonvim_minimap_update":
 		if w.minimap.visible {
 			w.minimap.bufUpdate()
 		}
 	case "gonvim_minimap_toggle":
-		go w.minimap.toggle()
+		w.minimap.toggle()
 	case "gonvim_copy_clipboard":
 		go editor.copyClipBoard()
 	case "gonvim_get_maxline":
@@ -928,8 +1111,12 @@ func (w *Workspace) handleRPCGui(updates []interface{}) {
 		editor.workspacePrevious()
 	case "gonvim_workspace_switch":
 		editor.workspaceSwitch(reflectToInt(updates[1]))
+	case "gonvim_git_hunks":
+		go w.updateGitHunks()
 	case "gonvim_workspace_cwd":
 		w.setCwd(updates[1].(string))
+		// Stop watching the repository left by the directory change
+		editor.gitStatuses.release(editor.workspaces)
 	case "gonvim_workspace_setCurrentFileLabel":
 		file := updates[1].(string)
 		w.filepath = file
@@ -953,6 +1140,8 @@ func (w *Workspace) handleRPCGui(updates []interface{}) {
 		go w.markdown.update()
 	case GonvimMarkdownToggleEvent:
 		go w.markdown.toggle()
+	case GonvimMarkdownExportEvent:
+		go w.markdown.export(updates[1:])
 	case GonvimMarkdownScrollDownEvent:
 		w.markdown.scrollDown()
 	case GonvimMarkdownScrollUpEvent:
@@ -1286,6 +1475,21 @@ func (i *WorkspaceSideItem) hide() {
 	i.closeIcon.Hide()
 }
 
+// getHighlightColors returns the foreground colors of the highlight groups,
+// like "#rrggbb", keyed by the keys of groups
+func (w *Workspace) getHighlightColors(groups map[string]string) map[string]string {
+	items := []string{}
+	for key, group := range groups {
+		items = append(items, fmt.Sprintf("'%s': '%s'", key, group))
+	}
+	colors := map[string]string{}
+	err := w.nvim.Eval(fmt.Sprintf(`map({%s}, {_, g -> synIDattr(synIDtrans(hlID(g)), 'fg#', 'gui')})`, strings.Join(items, ", ")), &colors)
+	if err != nil {
+		return nil
+	}
+	return colors
+}
+
 func (w *Workspace) setGuiColor(fg *RGBA, bg *RGBA) {
 	if fg == nil || bg == nil {
 		return
@@ -1387,10 +1591,12 @@ func (w *Workspace) setGuiColor(fg *RGBA, bg *RGBA) {
 	w.palette.widget.SetStyleSheet(fmt.Sprintf(" QWidget#palette { border: 1px solid %s; } .QWidget { background-color: %s; } * { color: %s; } ", paletteBorderColor.print(), paletteBgColor.print(), paletteFgColor.print()))
 	w.palette.scrollBar.SetStyleSheet(fmt.Sprintf("background-color: %s;", paletteLightBgColor.print()))
 	w.palette.pattern.SetStyleSheet(fmt.Sprintf("background-color: %s;", paletteLightBgColor.print()))
+	w.palette.footer.SetStyleSheet(fmt.Sprintf("background-color: %s; color: %s;", paletteLightBgColor.print(), gradColor(fg).print()))
 
 	// popup
 	w.popup.scrollBar.SetStyleSheet(fmt.Sprintf("background-color: %s;", popScrollBarColor.print()))
 	w.popup.widget.SetStyleSheet(fmt.Sprintf("* {background-color: %s; color: %s;} #detailpopup { color: %s; }", popBgColor.print(), popFgColor.print(), popFgDetailColor.print()))
+	w.popup.doc.SetStyleSheet(fmt.Sprintf("QTextBrowser { border: 1px solid %s; background-color: %s; color: %s; }", locBorderColor.print(), popBgColor.print(), popFgColor.print()))
 
 	// loc
 	w.loc.widget.SetStyleSheet(fmt.Sprintf(".QWidget { border: 1px solid %s; } * { background-color: %s;  color: %s; }", locBorderColor.print(), locBgColor.print(), locFgColor.print()))
@@ -1398,6 +1604,12 @@ func (w *Workspace) setGuiColor(fg *RGBA, bg *RGBA) {
 	// signature
 	w.signature.widget.SetStyleSheet(fmt.Sprintf(".QWidget { border: 1px solid %s; } QWidget { background-color: %s; } * { color: %s; }", signatureBorderColor.print(), signatureBgColor.print(), signatureFgColor.print()))
 
+	// message
+	w.message.setColor(locFgColor, locBgColor, locBorderColor)
+
+	// hover
+	w.hover.widget.SetStyleSheet(fmt.Sprintf("QTextBrowser { border: 1px solid %s; background-color: %s; color: %s; }", locBorderColor.print(), locBgColor.print(), locFgColor.print()))
+
 	// screan tooltip
 	w.screen.tooltip.SetStyleSheet(fmt.Sprintf(" * {background-color: %s; text-decoration: underline; color: %s; }", tooltipBgColor.print(), tooltipFgColor.print()))
 
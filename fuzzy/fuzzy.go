@@ -1,9 +1,11 @@
 package fuzzy
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
@@ -11,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/akiyosi/gonvim/osdepend"
 	"github.com/junegunn/fzf/src/algo"
@@ -26,26 +29,35 @@ const (
 
 // Fuzzy is
 type Fuzzy struct {
-	nvim          *nvim.Nvim
-	options       map[string]interface{}
-	source        []string
-	sourceNew     chan string
-	max           int
-	selected      int
-	pattern       string
-	cursor        int
-	slab          *util.Slab
-	start         int
-	result        []*Output
-	scoreMutext   *sync.Mutex
-	scoreNew      bool
-	cancelled     bool
-	cancelChan    chan bool
-	lastOutput    []string
-	lastMatch     [][]int
-	resultRWMtext sync.RWMutex
-	running       bool
-	pwd           string
+	nvim        *nvim.Nvim
+	notify      func(event string, args ...interface{}) // sends the event to the Gui
+	slab        *util.Slab
+	scoreMutext *sync.Mutex
+
+	// mutex guards the state of the session below, which is shared by the
+	// handlers of the events, the producers of the source and the ticker of
+	// filter
+	mutex      sync.Mutex
+	options    map[string]interface{}
+	source     []string
+	sourceNew  chan string
+	max        int
+	selected   int
+	pattern    string
+	cursor     int
+	start      int
+	result     []*Output
+	generation int // bumped by every filter and reset to stop the older filter
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+	lastOutput []string
+	lastMatch  [][]int
+	running    bool
+	pwd        string // root directory of the current session
+	loading    bool
+	loaded     int
+	sourceErr  string
+	lastStatus string
 }
 
 // Output is
@@ -59,11 +71,15 @@ type Output struct {
 func RegisterPlugin(nvim *nvim.Nvim) {
 	nvim.Subscribe("GonvimFuzzy")
 	shim := &Fuzzy{
-		nvim:        nvim,
+		nvim: nvim,
+		notify: func(event string, args ...interface{}) {
+			nvim.Call("rpcnotify", nil, append([]interface{}{0, "Gui", event}, args...)...)
+		},
 		slab:        util.MakeSlab(slab16Size, slab32Size),
 		scoreMutext: &sync.Mutex{},
 		max:         20,
 	}
+	shim.reset()
 	nvim.RegisterHandler("GonvimFuzzy", func(args ...interface{}) {
 		go shim.handle(args...)
 	})
@@ -85,6 +101,8 @@ func (s *Fuzzy) handle(args ...interface{}) {
 	switch event {
 	case "run":
 		s.run(args[1:])
+	case "source":
+		s.runSource(args[1:])
 	case "char":
 		s.newChar(args[1:])
 	case "backspace":
@@ -104,36 +122,64 @@ func (s *Fuzzy) handle(args ...interface{}) {
 	case "confirm":
 		s.confirm()
 	case "update_max":
+		s.mutex.Lock()
 		s.max = reflectToInt(args[1])
+		s.mutex.Unlock()
 	default:
 		fmt.Println("unhandleld fzfshim event", event)
 	}
 }
 
 func (s *Fuzzy) run(args []interface{}) {
-	ok := s.parseOptions(args)
+	options, ok := parseOptions(args)
 	if !ok {
 		return
 	}
-	s.running = true
+	// The root may be asked to neovim, so it is resolved without the lock
+	pwd := s.root(options)
 	s.reset()
+	s.mutex.Lock()
+	s.options = options
+	s.running = true
+	s.pwd = pwd
+	s.mutex.Unlock()
 	s.processSource()
 	s.outputPattern()
 	s.filter()
 }
 
 func (s *Fuzzy) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.source = []string{}
 	s.selected = 0
 	s.pattern = ""
 	s.cursor = 0
 	s.start = 0
+	s.result = []*Output{}
 	s.pwd = ""
 	s.lastOutput = []string{}
 	s.lastMatch = [][]int{}
 	s.sourceNew = make(chan string, 1000)
-	s.cancelled = false
-	s.cancelChan = make(chan bool, 1)
+	s.lastStatus = ""
+	s.loading = false
+	s.loaded = 0
+	s.sourceErr = ""
+	s.generation++
+
+	// Stop the producers of the previous session and start a new one
+	if s.cancelFunc != nil {
+		s.cancelFunc()
+	}
+	s.ctx, s.cancelFunc = context.WithCancel(context.Background())
+}
+
+// stale reports whether a newer filter or session has taken over the filter
+// of the generation
+func (s *Fuzzy) stale(generation int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.generation != generation
 }
 
 // ByScore sorts the output by score
@@ -154,37 +200,57 @@ func (a ByScore) Less(i, j int) bool {
 }
 
 func (s *Fuzzy) filter() {
-	if s.cancelled {
+	s.mutex.Lock()
+	ctx := s.ctx
+	s.generation++
+	generation := s.generation
+	s.mutex.Unlock()
+	if ctx.Err() != nil {
 		return
 	}
-	s.scoreNew = true
 	s.scoreMutext.Lock()
 	defer s.scoreMutext.Unlock()
-	s.scoreNew = false
-	s.resultRWMtext.Lock()
+	s.mutex.Lock()
+	if s.generation != generation {
+		// An even newer filter is waiting for the lock
+		s.mutex.Unlock()
+		return
+	}
 	s.result = []*Output{}
-	s.resultRWMtext.Unlock()
+	source := s.source
+	pattern := s.pattern
 	sourceNew := s.sourceNew
+	s.mutex.Unlock()
 
-	stop := make(chan bool, 1)
+	// The ticker is stopped and waited for before the final output, so that
+	// a late tick never overwrites it
+	stop := make(chan bool)
+	done := make(chan bool)
 	go func() {
-		tick := time.Tick(50 * time.Millisecond)
+		defer close(done)
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
 		for {
 			select {
-			case <-tick:
+			case <-ticker.C:
 				s.outputResult()
+				s.outputStatus()
 			case <-stop:
 				return
 			}
 		}
 	}()
-	defer func() {
-		stop <- true
-	}()
+	var stopOnce sync.Once
+	stopTicker := func() {
+		stopOnce.Do(func() {
+			close(stop)
+			<-done
+		})
+	}
+	defer stopTicker()
 
-	for _, source := range s.source {
-		s.scoreSource(source)
-		if s.scoreNew || s.cancelled {
+	for _, item := range source {
+		if !s.scoreSource(generation, item, pattern) {
 			return
 		}
 	}
@@ -192,40 +258,56 @@ func (s *Fuzzy) filter() {
 loop:
 	for {
 		select {
-		case source, ok := <-sourceNew:
+		case item, ok := <-sourceNew:
 			if !ok {
 				break loop
 			}
-			s.source = append(s.source, source)
-			s.scoreSource(source)
-			if s.scoreNew || s.cancelled {
+			s.mutex.Lock()
+			if ctx.Err() != nil {
+				s.mutex.Unlock()
+				return
+			}
+			// The item is kept even if this filter is stale, since the
+			// newer filter scores the source read so far
+			s.source = append(s.source, item)
+			s.loaded = len(s.source)
+			s.mutex.Unlock()
+			if !s.scoreSource(generation, item, pattern) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-time.After(50 * time.Millisecond):
+			// A slow source must not keep a newer filter waiting
+			if s.stale(generation) {
 				return
 			}
-		case <-time.After(1000 * time.Millisecond):
-			fmt.Println("timeout reading sourceNew")
-			break loop
 		}
 	}
-	if s.scoreNew || s.cancelled {
+	stopTicker()
+	if s.stale(generation) {
 		return
 	}
 	s.outputResult()
+	s.outputStatus()
 }
 
-func (s *Fuzzy) scoreSource(source string) {
+// scoreSource adds the source to the result if it matches the pattern. It
+// returns false if the filter of the generation is stale.
+func (s *Fuzzy) scoreSource(generation int, source string, pattern string) bool {
 	r := algo.Result{
 		Score: -1,
 	}
 	n := &[]int{}
 
-	if s.pattern != "" {
-		chars := util.ToChars([]byte(source))
-		// fuzzy match like smart case
-		if strings.ContainsAny(s.pattern, "ABCDEFZHIJKLMNOPQRSTUVWXYZ") {
-			r, n = algo.FuzzyMatchV1(true, true, true, &chars, []rune(s.pattern), true, s.slab)
-		} else {
-			r, n = algo.FuzzyMatchV1(false, true, true, &chars, []rune(s.pattern), true, s.slab)
-		}
+	if pattern != "" {
+		r, n = fuzzyMatch(source, pattern, s.slab)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.generation != generation {
+		return false
 	}
 	if r.Score == -1 || r.Score > 0 {
 		i := 0
@@ -252,29 +334,46 @@ func (s *Fuzzy) scoreSource(source string) {
 		// 	s.outputResult()
 		// }
 	}
+	return true
 }
 
-func (s *Fuzzy) processSource() {
-	source := s.options["source"]
-	pwd, ok := s.options["pwd"]
-	if ok {
-		s.pwd, ok = pwd.(string)
-		if !ok {
-			s.pwd = ""
-		}
-		path, err := expand(s.pwd)
-		if err == nil {
-			s.pwd = path
-		}
+// fuzzyMatch matches the pattern against the text like smart case
+func fuzzyMatch(text, pattern string, slab *util.Slab) (algo.Result, *[]int) {
+	chars := util.ToChars([]byte(text))
+	caseSensitive := strings.IndexFunc(pattern, unicode.IsUpper) >= 0
+	return algo.FuzzyMatchV1(caseSensitive, true, true, &chars, []rune(pattern), true, slab)
+}
+
+// MatchPositions returns the positions of the characters of the text which
+// match the pattern in the rune indices, or nil when the text doesn't match
+func MatchPositions(text, pattern string) []int {
+	if pattern == "" {
+		return nil
 	}
-	if s.pwd != "" {
-		os.Chdir(s.pwd)
+	r, n := fuzzyMatch(text, pattern, nil)
+	if r.Score <= 0 || n == nil {
+		return nil
 	}
+	return *n
+}
+
+func (s *Fuzzy) processSource() {
+	s.mutex.Lock()
+	options := s.options
+	root := s.pwd
+	ctx := s.ctx
 	sourceNew := s.sourceNew
-	cancelChan := s.cancelChan
+	s.loading = true
+	s.mutex.Unlock()
+	source := options["source"]
+	builtin := builtinSource(options)
+	if builtin != nil {
+		go s.processBuiltin(ctx, builtin, root, sourceNew)
+		return
+	}
 	if source == nil {
 		dir := ""
-		dirInterface, ok := s.options["dir"]
+		dirInterface, ok := options["dir"]
 		if ok {
 			dir, ok = dirInterface.(string)
 			if !ok {
@@ -292,64 +391,17 @@ func (s *Fuzzy) processSource() {
 		}
 		go func() {
 			defer close(sourceNew)
-			pwd := "./"
-			if dir != "" {
-				pwd = dir
-			}
-			files, _ := ioutil.ReadDir(pwd)
-			folders := []string{}
-			ignore, _ := gitignore.NewRepository(pwd)
-			for {
-				for _, f := range files {
-					if s.cancelled {
-						return
-					}
-					if f.IsDir() {
-						if f.Name() == ".git" {
-						        continue
-						}
-						folders = append(folders, filepath.Join(pwd, f.Name()))
-						continue
-					}
-					file := filepath.Join(pwd, f.Name())
-					match := ignore.Relative(file, true)
-					if match != nil {
-						continue
-					}
-					if homeDir != "" && strings.HasPrefix(file, homeDir) {
-						file = "~" + file[len(homeDir):]
-					}
-					select {
-					case sourceNew <- file:
-					case <-cancelChan:
-						return
-					}
-				}
-				for {
-					if len(folders) == 0 {
-						return
-					}
-					pwd = folders[0]
-					folders = folders[1:]
-					files, _ = ioutil.ReadDir(pwd)
-					if len(files) == 0 {
-						continue
-					} else {
-						break
-					}
-				}
-			}
+			walkFiles(ctx, root, dir, homeDir, sourceNew)
+			s.finishSource(ctx, "")
 		}()
 		return
 	}
 	switch src := source.(type) {
 	case []interface{}:
 		go func() {
+			defer close(sourceNew)
+			defer s.finishSource(ctx, "")
 			for _, item := range src {
-				if s.cancelled {
-					close(sourceNew)
-					return
-				}
 				str, ok := item.(string)
 				if !ok {
 					continue
@@ -357,62 +409,161 @@ func (s *Fuzzy) processSource() {
 
 				select {
 				case sourceNew <- str:
-				case <-cancelChan:
-					close(sourceNew)
+				case <-ctx.Done():
 					return
 				}
 			}
-			close(sourceNew)
 		}()
 	case string:
-		cmd := exec.Command("bash", "-c", src)
-		osdepend.PrepareRunProc(cmd)
-		stdout, _ := cmd.StdoutPipe()
-		output := ""
-		go func() {
-			buf := make([]byte, 2)
-			for {
-				n, err := stdout.Read(buf)
-				if err != nil || s.cancelled {
-					close(sourceNew)
-					stdout.Close()
-					cmd.Wait()
-					return
-				}
-				output += string(buf[0:n])
-				parts := strings.Split(output, "\n")
-				if len(parts) > 1 {
-					for i := 0; i < len(parts)-1; i++ {
-						// s.source = append(s.source, parts[i])
-						select {
-						case sourceNew <- parts[i]:
-						case <-cancelChan:
-							close(sourceNew)
-							stdout.Close()
-							cmd.Wait()
-							return
-						}
-					}
-					output = parts[len(parts)-1]
-				}
-			}
-		}()
-		cmd.Start()
+		go s.runCommand(ctx, root, src, sourceNew)
 	default:
 		fmt.Println(reflect.TypeOf(source))
+		s.finishSource(ctx, fmt.Sprintf("unsupported source type %s", reflect.TypeOf(source)))
+		close(sourceNew)
+	}
+}
+
+// runCommand streams the stdout of the command line by line to sourceNew.
+// The stderr and the exit status are reported as the error of the source.
+func (s *Fuzzy) runCommand(ctx context.Context, root string, src string, sourceNew chan string) {
+	defer close(sourceNew)
+	cmd := exec.CommandContext(ctx, "bash", "-c", src)
+	cmd.Dir = root
+	osdepend.PrepareRunProc(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		s.finishSource(ctx, err.Error())
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		s.finishSource(ctx, err.Error())
+		return
+	}
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			select {
+			case sourceNew <- line:
+			case <-ctx.Done():
+				cmd.Wait()
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+	errText := strings.TrimSpace(stderr.String())
+	if err != nil {
+		if errText == "" {
+			errText = err.Error()
+		} else {
+			errText = fmt.Sprintf("%s: %s", err.Error(), errText)
+		}
+	}
+	s.finishSource(ctx, errText)
+}
+
+// finishSource marks the source of the session of ctx as completely read
+func (s *Fuzzy) finishSource(ctx context.Context, errText string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	s.loading = false
+	s.sourceErr = errText
+}
+
+// root returns the directory the session works in. It is the "pwd" option
+// if given, otherwise the current directory of neovim. The gonvim process
+// directory is never changed, since it is shared by all workspaces.
+func (s *Fuzzy) root(options map[string]interface{}) string {
+	pwd := ""
+	pwdInterface, ok := options["pwd"]
+	if ok {
+		pwd, ok = pwdInterface.(string)
+		if !ok {
+			pwd = ""
+		}
+		path, err := expand(pwd)
+		if err == nil {
+			pwd = path
+		}
+	}
+	if pwd == "" {
+		s.nvim.Call("getcwd", &pwd)
+	}
+	return pwd
+}
+
+// walkFiles sends the files under dir to sourceNew, breadth first, skipping
+// .git and the gitignored files. A relative dir is resolved against root, and
+// the sent paths are relative to root in that case.
+func walkFiles(ctx context.Context, root, dir, homeDir string, sourceNew chan string) {
+	base := dir
+	if base == "" {
+		base = root
+	} else if !filepath.IsAbs(base) && root != "" {
+		base = filepath.Join(root, base)
+	}
+	if base == "" {
+		base = "."
+	}
+	ignore, _ := gitignore.NewRepository(base)
+	folders := []string{""}
+	for len(folders) > 0 {
+		rel := folders[0]
+		folders = folders[1:]
+		files, _ := ioutil.ReadDir(filepath.Join(base, rel))
+		for _, f := range files {
+			if ctx.Err() != nil {
+				return
+			}
+			name := filepath.Join(rel, f.Name())
+			if f.IsDir() {
+				if f.Name() == ".git" {
+					continue
+				}
+				folders = append(folders, name)
+				continue
+			}
+			if ignore != nil && ignore.Relative(name, false) != nil {
+				continue
+			}
+			file := filepath.Join(dir, name)
+			if homeDir != "" && strings.HasPrefix(file, homeDir) {
+				file = "~" + file[len(homeDir):]
+			}
+			select {
+			case sourceNew <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
 	}
 }
 
-func (s *Fuzzy) parseOptions(args []interface{}) bool {
+func parseOptions(args []interface{}) (map[string]interface{}, bool) {
 	if len(args) == 0 {
-		return false
+		return nil, false
 	}
 	options, ok := args[0].(map[string]interface{})
 	if !ok {
-		return false
+		return nil, false
 	}
-	s.options = options
-	return true
+	return options, true
 }
 
 func (s *Fuzzy) newChar(args []interface{}) {
@@ -426,46 +577,83 @@ func (s *Fuzzy) newChar(args []interface{}) {
 	if len(c) == 0 {
 		return
 	}
+	s.mutex.Lock()
 	s.pattern = insertAtIndex(s.pattern, s.cursor, c)
 	s.cursor++
+	s.mutex.Unlock()
 	s.outputPattern()
 	s.filter()
 }
 
 func (s *Fuzzy) clear() {
+	s.mutex.Lock()
 	s.pattern = ""
 	s.cursor = 0
+	s.mutex.Unlock()
 	s.outputPattern()
 	s.filter()
 }
 
 func (s *Fuzzy) backspace() {
+	s.mutex.Lock()
 	if s.cursor == 0 {
+		s.mutex.Unlock()
 		return
 	}
 	s.cursor--
 	s.pattern = removeAtIndex(s.pattern, s.cursor)
+	s.mutex.Unlock()
 	s.outputPattern()
 	s.filter()
 }
 
 func (s *Fuzzy) left() {
+	s.mutex.Lock()
 	if s.cursor > 0 {
 		s.cursor--
 	}
+	s.mutex.Unlock()
 	s.outputCursor()
 }
 
 func (s *Fuzzy) outputPattern() {
-	s.nvim.Call("rpcnotify", nil, 0, "Gui", "finder_pattern", s.pattern, s.cursor)
+	s.mutex.Lock()
+	pattern := s.pattern
+	cursor := s.cursor
+	s.mutex.Unlock()
+	s.notify("finder_pattern", pattern, cursor)
 }
 
 func (s *Fuzzy) outputHide() {
-	s.nvim.Call("rpcnotify", nil, 0, "Gui", "finder_hide")
+	s.notify("finder_hide")
+}
+
+func (s *Fuzzy) outputStatus() {
+	s.mutex.Lock()
+	if !s.running {
+		s.mutex.Unlock()
+		return
+	}
+	loading := s.loading
+	loaded := s.loaded
+	errText := s.sourceErr
+
+	status := fmt.Sprintf("%t %d %s", loading, loaded, errText)
+	if status == s.lastStatus {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastStatus = status
+	s.mutex.Unlock()
+
+	s.notify("finder_status", loading, loaded, errText)
 }
 
 func (s *Fuzzy) outputCursor() {
-	s.nvim.Call("rpcnotify", nil, 0, "Gui", "finder_pattern_pos", s.cursor)
+	s.mutex.Lock()
+	cursor := s.cursor
+	s.mutex.Unlock()
+	s.notify("finder_pattern_pos", cursor)
 }
 
 func outputEqual(a, b []string) bool {
@@ -506,22 +694,20 @@ func matchEqual(a, b [][]int) bool {
 }
 
 func (s *Fuzzy) outputResult() {
+	s.mutex.Lock()
 	if !s.running {
+		s.mutex.Unlock()
 		return
 	}
-	start := s.start
 	result := s.result
-	max := s.max
-	selected := s.selected
-
-	s.resultRWMtext.RLock()
 	total := len(result)
-	if start >= total {
+	if s.start >= total {
 		s.start = 0
-		start = 0
 		s.selected = 0
 	}
-	end := start + max
+	start := s.start
+	selected := s.selected
+	end := start + s.max
 	if end > total {
 		end = total
 	}
@@ -541,80 +727,109 @@ func (s *Fuzzy) outputResult() {
 			match = append(match, *o.match)
 		}
 	}
-	s.resultRWMtext.RUnlock()
 
 	if outputEqual(output, s.lastOutput) && matchEqual(match, s.lastMatch) {
+		s.mutex.Unlock()
 		return
 	}
 	s.lastOutput = output
 	s.lastMatch = match
+	typ := s.options["type"]
+	s.mutex.Unlock()
 
-	s.nvim.Call("rpcnotify", nil, 0, "Gui", "finder_show_result", output, selected-start, match, s.options["type"], start, total)
+	s.notify("finder_show_result", output, selected-start, match, typ, start, total)
 }
 
 func (s *Fuzzy) right() {
+	s.mutex.Lock()
 	if s.cursor < len(s.pattern) {
 		s.cursor++
 	}
+	s.mutex.Unlock()
 	s.outputCursor()
 }
 
 func (s *Fuzzy) up() {
+	s.mutex.Lock()
 	if s.selected > 0 {
 		s.selected--
 	} else if s.selected == 0 {
 		s.selected = len(s.result) - 1
 	}
+	s.mutex.Unlock()
 	s.processSelected()
 }
 
 func (s *Fuzzy) down() {
+	s.mutex.Lock()
 	if s.selected < len(s.result)-1 {
 		s.selected++
 	} else if s.selected == len(s.result)-1 {
 		s.selected = 0
 	}
+	s.mutex.Unlock()
 	s.processSelected()
 }
 
 func (s *Fuzzy) processSelected() {
+	s.mutex.Lock()
+	scrolled := false
 	if s.selected < s.start {
 		s.start = s.selected
-		s.outputResult()
+		scrolled = true
 	} else if s.selected >= s.start+s.max {
 		s.start = s.selected - s.max + 1
+		scrolled = true
+	}
+	selected := s.selected - s.start
+	s.mutex.Unlock()
+	if scrolled {
 		s.outputResult()
 	}
-	s.nvim.Call("rpcnotify", nil, 0, "Gui", "finder_select", s.selected-s.start)
+	s.notify("finder_select", selected)
 }
 
 func (s *Fuzzy) confirm() {
-	if s.selected >= len(s.result) {
+	s.mutex.Lock()
+	if s.selected < 0 || s.selected >= len(s.result) {
+		s.mutex.Unlock()
 		return
 	}
 	arg := s.result[s.selected].output
+	root := s.pwd
+	options := s.options
+	s.mutex.Unlock()
 	s.cancel()
 
-	sink, ok := s.options["sink"]
+	sink, ok := options["sink"]
 	if ok {
 		s.nvim.Command(fmt.Sprintf("%s %s", sink.(string), arg))
 		return
 	}
 
-	function, ok := s.options["function"]
+	function, ok := options["function"]
 	if ok {
 		options := map[string]string{}
 		options["function"] = function.(string)
 		options["arg"] = arg
 		s.nvim.Call("gonvim_fuzzy#exec", nil, options)
+		return
+	}
+
+	builtin := builtinSource(options)
+	if builtin != nil {
+		command := builtin.open(root, arg)
+		if command != "" {
+			s.nvim.Command(command)
+		}
 	}
 }
 
 func (s *Fuzzy) cancel() {
+	s.mutex.Lock()
 	s.running = false
+	s.mutex.Unlock()
 	s.outputHide()
-	s.cancelled = true
-	s.cancelChan <- true
 	s.reset()
 }
 
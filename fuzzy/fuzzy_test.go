@@ -0,0 +1,121 @@
+package fuzzy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+// testGui records the events sent to the Gui by a session
+type testGui struct {
+	mutex  sync.Mutex
+	result []string
+	status []interface{}
+}
+
+func (g *testGui) notify(event string, args ...interface{}) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	switch event {
+	case "finder_show_result":
+		g.result = args[0].([]string)
+	case "finder_status":
+		g.status = args
+	}
+}
+
+func newTestFuzzy(gui *testGui) *Fuzzy {
+	s := &Fuzzy{
+		notify:      gui.notify,
+		slab:        util.MakeSlab(slab16Size, slab32Size),
+		scoreMutext: &sync.Mutex{},
+		max:         20,
+	}
+	s.reset()
+	return s
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	const files = 50
+	roots := []string{t.TempDir(), t.TempDir()}
+	for i, root := range roots {
+		for j := 0; j < files; j++ {
+			name := filepath.Join(root, fmt.Sprintf("session%d_file%d.txt", i, j))
+			err := ioutil.WriteFile(name, []byte{}, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(1)
+		go func(i int, root string) {
+			defer wg.Done()
+			gui := &testGui{}
+			s := newTestFuzzy(gui)
+			s.handle("update_max", int64(files*2))
+			s.handle("run", map[string]interface{}{"pwd": root})
+
+			// The events are handled concurrently like RegisterPlugin does
+			var events sync.WaitGroup
+			for _, args := range [][]interface{}{
+				{"char", "f"},
+				{"down"},
+				{"char", "i"},
+				{"up"},
+				{"backspace"},
+				{"left"},
+				{"right"},
+			} {
+				events.Add(1)
+				go func(args []interface{}) {
+					defer events.Done()
+					s.handle(args...)
+				}(args)
+			}
+			events.Wait()
+			s.handle("clear")
+
+			gui.mutex.Lock()
+			defer gui.mutex.Unlock()
+			if len(gui.result) != files {
+				t.Errorf("session %d: got %d results, want %d", i, len(gui.result), files)
+			}
+			prefix := fmt.Sprintf("session%d_", i)
+			for _, item := range gui.result {
+				if !strings.HasPrefix(item, prefix) {
+					t.Errorf("session %d: got %q from another root", i, item)
+				}
+			}
+			if len(gui.status) != 3 || gui.status[0] != false || gui.status[1] != files || gui.status[2] != "" {
+				t.Errorf("session %d: got status %v, want [false %d ]", i, gui.status, files)
+			}
+		}(i, root)
+	}
+	wg.Wait()
+}
+
+func TestMatchPositionsSmartCase(t *testing.T) {
+	for _, test := range []struct {
+		text, pattern string
+		match         bool
+	}{
+		{"gonvim.go", "go", true},
+		{"Gonvim.go", "go", true},
+		{"Gonvim.go", "Go", true},
+		{"gonvim.go", "Go", false},
+		{"gitHunk.go", "HG", false},
+	} {
+		got := MatchPositions(test.text, test.pattern) != nil
+		if got != test.match {
+			t.Errorf("MatchPositions(%q, %q) matched %v, want %v", test.text, test.pattern, got, test.match)
+		}
+	}
+}
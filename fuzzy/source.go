@@ -0,0 +1,289 @@
+package fuzzy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source is a built-in source of the fuzzy finder, which can be launched
+// with ":GonvimFuzzy <name>"
+type Source struct {
+	// typ is the result type, which decides the icon of the items in the finder
+	typ string
+	// cmd is a shell command whose output lines are the items
+	cmd string
+	// collect sends the items to sourceNew, used when cmd is empty
+	collect func(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error
+	// open returns the ex command to open the selected item
+	open func(root, item string) string
+}
+
+var sources = map[string]*Source{
+	"buffers": {
+		typ:     "buffer",
+		collect: collectBuffers,
+		open: func(root, item string) string {
+			n := strings.Index(item, "]")
+			if n < 1 {
+				return ""
+			}
+			return fmt.Sprintf("buffer %s", item[1:n])
+		},
+	},
+	"oldfiles": {
+		typ:     "file",
+		collect: collectOldfiles,
+		open:    openFile,
+	},
+	"tags": {
+		typ:     "tag",
+		collect: collectTags,
+		open: func(root, item string) string {
+			return fmt.Sprintf("execute 'tag' fnameescape(%s)", vimString(firstField(item)))
+		},
+	},
+	"git_files": {
+		typ:  "file",
+		cmd:  "git ls-files --cached --others --exclude-standard",
+		open: openFile,
+	},
+	"git_log": {
+		typ: "commit",
+		cmd: "git log --oneline",
+		open: func(root, item string) string {
+			return fmt.Sprintf("new | setlocal buftype=nofile bufhidden=wipe noswapfile filetype=git | execute 'silent 0read !git -C' shellescape(%s, 1) 'show' shellescape(%s, 1)", vimString(root), vimString(firstField(item)))
+		},
+	},
+	"help_tags": {
+		typ:     "help",
+		collect: collectHelpTags,
+		open: func(root, item string) string {
+			return fmt.Sprintf("help %s", item)
+		},
+	},
+	"marks": {
+		typ:     "mark",
+		collect: collectMarks,
+		open: func(root, item string) string {
+			return fmt.Sprintf("normal! `%s", firstField(item))
+		},
+	},
+}
+
+// SourceNames returns the names of the built-in sources
+func SourceNames() []string {
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Fuzzy) runSource(args []interface{}) {
+	if len(args) == 0 {
+		return
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return
+	}
+	name = strings.TrimSpace(name)
+	source, ok := sources[name]
+	if !ok {
+		s.nvim.Command(fmt.Sprintf("echoerr 'GonvimFuzzy: unknown source %s'", strings.Replace(name, "'", "''", -1)))
+		return
+	}
+	options := map[string]interface{}{
+		"type":    source.typ,
+		"builtin": name,
+	}
+	s.run([]interface{}{options})
+}
+
+// builtinSource returns the built-in source of the session options
+func builtinSource(options map[string]interface{}) *Source {
+	name, ok := options["builtin"].(string)
+	if !ok {
+		return nil
+	}
+	return sources[name]
+}
+
+func (s *Fuzzy) processBuiltin(ctx context.Context, source *Source, root string, sourceNew chan string) {
+	if source.cmd != "" {
+		s.runCommand(ctx, root, source.cmd, sourceNew)
+		return
+	}
+	defer close(sourceNew)
+	errText := ""
+	err := source.collect(s, ctx, root, sourceNew)
+	if err != nil {
+		errText = err.Error()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	s.finishSource(ctx, errText)
+}
+
+func sendItems(ctx context.Context, items []string, sourceNew chan string) {
+	for _, item := range items {
+		select {
+		case sourceNew <- item:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func collectBuffers(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error {
+	var buffers []string
+	err := s.nvim.Eval(`map(getbufinfo({'buflisted': 1}), {_, b -> '[' . b.bufnr . '] ' . (empty(b.name) ? '[No Name]' : fnamemodify(b.name, ':~:.'))})`, &buffers)
+	if err != nil {
+		return err
+	}
+	sendItems(ctx, buffers, sourceNew)
+	return nil
+}
+
+func collectOldfiles(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error {
+	var oldfiles []string
+	err := s.nvim.Eval("v:oldfiles", &oldfiles)
+	if err != nil {
+		return err
+	}
+	files := []string{}
+	for _, file := range oldfiles {
+		path, err := expand(file)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, file)
+	}
+	sendItems(ctx, files, sourceNew)
+	return nil
+}
+
+func collectTags(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error {
+	var tagfiles []string
+	err := s.nvim.Eval("tagfiles()", &tagfiles)
+	if err != nil {
+		return err
+	}
+	if len(tagfiles) == 0 {
+		return fmt.Errorf("no tags file found")
+	}
+	for _, tagfile := range tagfiles {
+		path, err := expand(tagfile)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		err = readTagfile(ctx, path, sourceNew, func(fields []string) string {
+			if len(fields) < 2 {
+				return fields[0]
+			}
+			return fmt.Sprintf("%s\t%s", fields[0], fields[1])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collectHelpTags(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error {
+	var tagfiles []string
+	err := s.nvim.Eval(`globpath(&runtimepath, 'doc/tags', 1, 1)`, &tagfiles)
+	if err != nil {
+		return err
+	}
+	for _, tagfile := range tagfiles {
+		err = readTagfile(ctx, tagfile, sourceNew, func(fields []string) string {
+			return fields[0]
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTagfile sends the entries of the ctags format file, formatted by format
+func readTagfile(ctx context.Context, path string, sourceNew chan string, format func(fields []string) string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" && !strings.HasPrefix(line, "!_TAG_") {
+			select {
+			case sourceNew <- format(strings.Split(line, "\t")):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func collectMarks(s *Fuzzy, ctx context.Context, root string, sourceNew chan string) error {
+	var output string
+	err := s.nvim.Eval(`execute('marks')`, &output)
+	if err != nil {
+		return err
+	}
+	marks := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		// Skip the "mark line  col file/text" header
+		if line == "" || strings.HasPrefix(line, "mark ") {
+			continue
+		}
+		marks = append(marks, line)
+	}
+	sendItems(ctx, marks, sourceNew)
+	return nil
+}
+
+// openFile edits the file, which is relative to the root of the session
+// unless it is absolute or in the home directory
+func openFile(root, item string) string {
+	path := item
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") && root != "" {
+		path = filepath.Join(root, path)
+	}
+	return fmt.Sprintf("execute 'edit' fnameescape(%s)", vimString(path))
+}
+
+func firstField(item string) string {
+	fields := strings.Fields(item)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// vimString returns the string as a single quoted Vim string literal
+func vimString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}